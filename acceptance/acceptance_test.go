@@ -112,7 +112,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 
 		it.Before(func() {
 			repo = "some-org/" + h.RandString(10)
-			repoName = "localhost:" + registryPort + "/" + repo
+			repoName = h.Daemon().Addr(registryPort) + "/" + repo
 			containerName = "test-" + h.RandString(10)
 
 			var err error
@@ -140,10 +140,10 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 				launchPort := fetchHostPort(t, containerName)
 
 				waitForPort(t, launchPort, 10*time.Second)
-				h.AssertEq(t, h.HttpGet(t, "http://localhost:"+launchPort), "Buildpacks Worked! - 1000:1000")
+				h.AssertEq(t, h.HttpGet(t, "http://"+h.Daemon().Addr(launchPort)), "Buildpacks Worked! - 1000:1000")
 
 				t.Log("Checking that registry is empty")
-				contents := h.HttpGet(t, fmt.Sprintf("http://localhost:%s/v2/_catalog", registryPort))
+				contents := h.HttpGet(t, "http://"+h.Daemon().Addr(registryPort)+"/v2/_catalog")
 				if strings.Contains(string(contents), repo) {
 					t.Fatalf("Should not have published image without the '--publish' flag: got %s", contents)
 				}
@@ -166,7 +166,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 				}
 
 				t.Log("Checking that registry has contents")
-				contents := h.HttpGet(t, fmt.Sprintf("http://localhost:%s/v2/_catalog", registryPort))
+				contents := h.HttpGet(t, "http://"+h.Daemon().Addr(registryPort)+"/v2/_catalog")
 				if !strings.Contains(string(contents), repo) {
 					t.Fatalf("Expected to see image %s in %s", repo, contents)
 				}
@@ -180,7 +180,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 				launchPort := fetchHostPort(t, containerName)
 
 				waitForPort(t, launchPort, 10*time.Second)
-				h.AssertEq(t, h.HttpGet(t, "http://localhost:"+launchPort), "Buildpacks Worked! - 1000:1000")
+				h.AssertEq(t, h.HttpGet(t, "http://"+h.Daemon().Addr(launchPort)), "Buildpacks Worked! - 1000:1000")
 
 				t.Log("uses the cache on subsequent run")
 				output = runPackBuild()
@@ -228,7 +228,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 				return strings.Contains(buf.String(), "Example app listening on port 3000!")
 			}, time.Second, 2*time.Minute)
 
-			txt := h.HttpGet(t, "http://localhost:3000")
+			txt := h.HttpGet(t, "http://"+h.Daemon().Addr("3000"))
 			h.AssertEq(t, txt, "Buildpacks Worked! - 1000:1000")
 		})
 
@@ -270,8 +270,8 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 
 				launchPort := fetchHostPort(t, containerName)
 				waitForPort(t, launchPort, 10*time.Second)
-				h.AssertEq(t, h.HttpGet(t, "http://localhost:"+launchPort), "Buildpacks Worked! - 1000:1000")
-				txt := h.HttpGet(t, "http://localhost:"+launchPort+"/rootcontents1")
+				h.AssertEq(t, h.HttpGet(t, "http://"+h.Daemon().Addr(launchPort)), "Buildpacks Worked! - 1000:1000")
+				txt := h.HttpGet(t, "http://"+h.Daemon().Addr(launchPort)+"/rootcontents1")
 				h.AssertNil(t, dockerCli.ContainerKill(context.TODO(), containerName, "SIGKILL"))
 				return txt
 			}
@@ -288,7 +288,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 			it.Before(func() {
 				buildAndSetRunImage(runBefore, "contents-before-1", "contents-before-2")
 
-				cmd := packCmd("build", repoName, "-p", "testdata/node_app/", "--no-pull")
+				cmd := packCmd("build", repoName, "-p", "testdata/node_app/", "--pull-policy", "never")
 				h.Run(t, cmd)
 				origID = h.ImageID(t, repoName)
 			})
@@ -303,7 +303,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 
 				buildAndSetRunImage(runAfter, "contents-after-1", "contents-after-2")
 
-				cmd := packCmd("rebase", repoName, "--no-pull")
+				cmd := packCmd("rebase", repoName, "--pull-policy", "never")
 				output := h.Run(t, cmd)
 
 				h.AssertContains(t, output, fmt.Sprintf("Successfully rebased image '%s'", repoName))
@@ -313,9 +313,9 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 
 		when("run on registry", func() {
 			it.Before(func() {
-				repoName = "localhost:" + registryPort + "/" + repoName
-				runBefore = "localhost:" + registryPort + "/" + runBefore
-				runAfter = "localhost:" + registryPort + "/" + runAfter
+				repoName = h.Daemon().Addr(registryPort) + "/" + repoName
+				runBefore = h.Daemon().Addr(registryPort) + "/" + runBefore
+				runAfter = h.Daemon().Addr(registryPort) + "/" + runAfter
 
 				buildAndSetRunImage(runBefore, "contents-before-1", "contents-before-2")
 				h.AssertNil(t, pushImage(dockerCli, runBefore))
@@ -370,8 +370,11 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 			output := h.Run(t, cmd)
 			h.AssertContains(t, output, fmt.Sprintf("Successfully created builder image '%s'", builderRepoName))
 
+			t.Log("trust builder")
+			h.Run(t, packCmd("trust-builder", builderRepoName))
+
 			t.Log("build uses order defined in builder.toml")
-			cmd = packCmd("build", repoName, "--builder", builderRepoName, "--path", sourceCodePath, "--no-pull")
+			cmd = packCmd("build", repoName, "--builder", builderRepoName, "--path", sourceCodePath, "--pull-policy", "never")
 			buildOutput, err := cmd.CombinedOutput()
 			h.AssertNil(t, err)
 			defer func(origID string) { h.AssertNil(t, h.DockerRmi(dockerCli, origID)) }(h.ImageID(t, repoName))
@@ -404,7 +407,7 @@ func testPack(t *testing.T, when spec.G, it spec.S) {
 				"--buildpack", "mock.bp.first",
 				"--buildpack", "mock.bp.third@0.0.3-mock",
 				"--path", sourceCodePath,
-				"--no-pull",
+				"--pull-policy", "never",
 			)
 			buildOutput, err = cmd.CombinedOutput()
 			h.AssertNil(t, err)
@@ -691,7 +694,7 @@ func pushImage(d *docker.Client, ref string) error {
 
 func waitForPort(t *testing.T, port string, duration time.Duration) {
 	h.Eventually(t, func() bool {
-		_, err := h.HttpGetE("http://localhost:" + port)
+		_, err := h.HttpGetE("http://" + h.Daemon().Addr(port))
 		return err == nil
 	}, 500*time.Millisecond, duration)
 }
@@ -0,0 +1,192 @@
+package pack
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/style"
+)
+
+// IgnoreFile lists gitignore-style patterns of files in the app dir that are not copied into the build
+const IgnoreFile = ".packignore"
+
+// StdinAppDir is the --path that reads the app as a tar stream, which may be gzip compressed, from stdin
+const StdinAppDir = "-"
+
+// zipExtensions and tarExtensions are app artifacts and source archives exploded into the app dir
+// rather than copied as a single file
+var (
+	zipExtensions = []string{".jar", ".war", ".zip"}
+	tarExtensions = []string{".tar", ".tgz", ".tar.gz"}
+)
+
+// appSource returns the directory copied into the app dir of the build. When --path is a single file,
+// archives such as jars or source tarballs are exploded into a temporary directory, and other files are
+// copied into one.
+func (b *BuildConfig) appSource() (dir string, cleanup func(), err error) {
+	fi, err := os.Stat(b.AppDir)
+	if err != nil {
+		return "", nil, err
+	}
+	if fi.IsDir() {
+		return b.AppDir, func() {}, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "pack.app.")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(tmpDir) }
+
+	switch {
+	case hasExtension(b.AppDir, zipExtensions):
+		b.Logger.Verbose("Exploding %s into app dir", style.Symbol(filepath.Base(b.AppDir)))
+		err = b.FS.Unzip(b.AppDir, tmpDir)
+	case hasExtension(b.AppDir, tarExtensions):
+		b.Logger.Verbose("Extracting %s into app dir", style.Symbol(filepath.Base(b.AppDir)))
+		err = b.untarFile(b.AppDir, tmpDir)
+	default:
+		b.Logger.Verbose("Copying %s into app dir", style.Symbol(filepath.Base(b.AppDir)))
+		err = copyFile(b.AppDir, filepath.Join(tmpDir, filepath.Base(b.AppDir)), fi.Mode())
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmpDir, cleanup, nil
+}
+
+func hasExtension(path string, extensions []string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// untarFile extracts a tar archive, which may be gzip compressed, into dest
+func (b *BuildConfig) untarFile(path, dest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompressed(f)
+	if err != nil {
+		return errors.Wrapf(err, "decompressing %s", style.Symbol(path))
+	}
+	return b.FS.Untar(r, dest)
+}
+
+// decompressed returns r, or a reader of its decompressed contents when r is gzip compressed
+func decompressed(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// appTarReader returns a tar of the app, with its files beneath tarDir owned by uid and gid. When the app
+// is read from stdin it can only be read once, so later attempts fail.
+func (b *BuildConfig) appTarReader(tarDir string, uid, gid int) (io.ReadCloser, error) {
+	if b.AppReader != nil {
+		if b.appRead {
+			return nil, errors.New("the app was already read from stdin and cannot be read again")
+		}
+		b.appRead = true
+		r, err := decompressed(b.AppReader)
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing stdin")
+		}
+		return b.FS.RelocateTar(r, tarDir, uid, gid), nil
+	}
+
+	appDir, cleanup, err := b.appSource()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := b.appFilter(appDir)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return &cleanupReadCloser{ReadCloser: b.FS.CreateFilteredTarReader(appDir, tarDir, uid, gid, filter), cleanup: cleanup}, nil
+}
+
+// cleanupReadCloser removes the temporary files it reads once it is closed
+type cleanupReadCloser struct {
+	io.ReadCloser
+	cleanup func()
+}
+
+func (c *cleanupReadCloser) Close() error {
+	defer c.cleanup()
+	return c.ReadCloser.Close()
+}
+
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// appFilter returns the files of appDir to copy into the build, or nil to copy everything.
+// Files are excluded by the project descriptor or by patterns in a .packignore file in appDir.
+func (b *BuildConfig) appFilter(appDir string) (fs.IncludeFunc, error) {
+	include, err := fs.NewMatcher(b.Include)
+	if err != nil {
+		return nil, err
+	}
+	ignored, err := readIgnoreFile(filepath.Join(appDir, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(ignored) > 0 {
+		b.Logger.Verbose("Excluding files matching %s", style.Symbol(IgnoreFile))
+	}
+	exclude, err := fs.NewMatcher(append(append([]string{}, b.Exclude...), ignored...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", style.Symbol(IgnoreFile))
+	}
+	if include.Empty() && exclude.Empty() {
+		return nil, nil
+	}
+	return func(relPath string, fi os.FileInfo) bool {
+		if exclude.Matches(relPath, fi.IsDir()) {
+			return false
+		}
+		// directories are walked so that included files beneath them are found
+		return include.Empty() || fi.IsDir() || include.Matches(relPath, false)
+	}, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(contents), "\n"), nil
+}
@@ -0,0 +1,227 @@
+package pack
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/fs"
+)
+
+// appSyncIDPath identifies the app upload last made to the cache volume. It is recorded in the app
+// manifest too, so the manifest is only trusted while it describes the volume's current contents.
+const appSyncIDPath = launchDir + "/.pack-app-sync"
+
+// appManifest records the hash of every file uploaded to the app dir of a cache volume
+type appManifest struct {
+	SyncID string            `json:"syncId"`
+	Files  map[string]string `json:"files"`
+}
+
+// copyApp copies the app into the workspace volume through the created container ctrID. When the volume
+// holds an earlier upload of the app, only changed files are copied and deleted files removed.
+func (b *BuildConfig) copyApp(ctx context.Context, ctrID string) error {
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
+	if b.AppReader != nil || b.AppManifests == "" {
+		tr, err := b.appTarReader(launchDir+"/app", uid, gid)
+		if err != nil {
+			return errors.Wrap(err, "preparing app")
+		}
+		return b.copyAppTar(ctx, ctrID, tr, uid, gid)
+	}
+
+	appDir, cleanup, err := b.appSource()
+	if err != nil {
+		return errors.Wrap(err, "preparing app")
+	}
+	defer cleanup()
+	filter, err := b.appFilter(appDir)
+	if err != nil {
+		return errors.Wrap(err, "preparing app")
+	}
+	files, err := hashAppFiles(appDir, filter)
+	if err != nil {
+		return errors.Wrap(err, "preparing app")
+	}
+
+	manifestPath := filepath.Join(b.AppManifests, b.CacheVolume+".json")
+	include := filter
+	if prev := readAppManifest(manifestPath); prev != nil && !b.ClearLaunch && b.readAppSyncID(ctx, ctrID) == prev.SyncID {
+		changed := map[string]bool{}
+		for relPath, hash := range files {
+			if prev.Files[relPath] != hash {
+				changed[relPath] = true
+			}
+		}
+		var deleted []string
+		for relPath := range prev.Files {
+			if _, ok := files[relPath]; !ok {
+				deleted = append(deleted, path.Join(launchDir, "app", relPath))
+			}
+		}
+		sort.Strings(deleted)
+		b.Logger.Verbose("Uploading %d changed app files and removing %d deleted ones", len(changed), len(deleted))
+
+		if len(deleted) > 0 {
+			if err := b.runInWorkspace(append([]string{"rm", "-f", "--"}, deleted...)...); err != nil {
+				return errors.Wrap(err, "removing deleted app files")
+			}
+		}
+		include = func(relPath string, fi os.FileInfo) bool {
+			if filter != nil && !filter(relPath, fi) {
+				return false
+			}
+			return fi.IsDir() || changed[relPath]
+		}
+	} else {
+		b.Logger.Verbose("Uploading all app files")
+		if err := b.runInWorkspace("rm", "-rf", launchDir+"/app"); err != nil {
+			return errors.Wrap(err, "clearing app dir")
+		}
+	}
+
+	if err := b.copyAppTar(ctx, ctrID, b.FS.CreateFilteredTarReader(appDir, launchDir+"/app", uid, gid, include), uid, gid); err != nil {
+		return err
+	}
+
+	manifest := appManifest{SyncID: uuid.New().String(), Files: files}
+	idTar, err := b.FS.CreateSingleFileTar(appSyncIDPath, manifest.SyncID)
+	if err != nil {
+		return err
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", idTar, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrapf(err, "creating %s", appSyncIDPath)
+	}
+	if err := writeAppManifest(manifestPath, manifest); err != nil {
+		b.Logger.Verbose("Unable to record uploaded app files, the next build uploads all of them: %s", err)
+	}
+	return nil
+}
+
+// copyAppTar copies the app tar tr to the workspace volume, with the app dir and the dirs in it owned by uid and gid.
+// The tar is streamed, so memory use doesn't grow with the size of the app, and the upload of a large app logs its progress.
+func (b *BuildConfig) copyAppTar(ctx context.Context, ctrID string, tr io.ReadCloser, uid, gid int) error {
+	owned := b.FS.WithParentDirs(tr, launchDir+"/app", uid, gid)
+	upload := b.compressed(owned)
+	progress := newProgressReader(upload, b.Logger, "Uploading app")
+	err := b.Cli.CopyToContainer(ctx, ctrID, "/", progress, dockertypes.CopyToContainerOptions{})
+	if closeErr := upload.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := owned.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := tr.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "copy app to workspace volume")
+	}
+	progress.done()
+	return nil
+}
+
+// readAppSyncID returns the ID of the last app upload to the volume, or "" when there is none
+func (b *BuildConfig) readAppSyncID(ctx context.Context, ctrID string) string {
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, appSyncIDPath)
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		return ""
+	}
+	id, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return ""
+	}
+	return string(id)
+}
+
+// hashAppFiles returns the hashes of the files in appDir accepted by include, keyed by slash-separated
+// relative path. Symlinks are hashed by their target.
+func hashAppFiles(appDir string, include fs.IncludeFunc) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(appDir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(appDir, file)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if include != nil && relPath != "." && !include(relPath, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\n", fi.Mode())
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, target)
+		} else {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		files[relPath] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	})
+	return files, err
+}
+
+// readAppManifest returns the manifest at path, or nil when it is missing or unreadable
+func readAppManifest(path string) *appManifest {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var manifest appManifest
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil
+	}
+	return &manifest
+}
+
+func writeAppManifest(path string, manifest appManifest) error {
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0666)
+}
@@ -0,0 +1,295 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// AppsManifest is the build manifest at the root of a repository of several apps, built by 'pack build --all'
+const AppsManifest = "pack-apps.toml"
+
+type BuildManifest struct {
+	Jobs   int             `toml:"jobs"`
+	Builds []ManifestBuild `toml:"builds"`
+}
+
+type ManifestBuild struct {
+	Path       string            `toml:"path"`
+	Image      string            `toml:"image"`
+	Builder    string            `toml:"builder"`
+	RunImage   string            `toml:"run-image"`
+	EnvFile    string            `toml:"env-file"`
+	Env        map[string]string `toml:"env"`
+	Buildpacks []string          `toml:"buildpacks"`
+}
+
+type BatchResult struct {
+	RepoName   string
+	Identifier Identifier
+	Duration   time.Duration
+	Err        error
+}
+
+// ReadBuildManifest reads a manifest describing several builds. Relative paths are interpreted
+// relative to the directory containing the manifest.
+func ReadBuildManifest(path string) (*BuildManifest, error) {
+	manifest := &BuildManifest{}
+	if _, err := toml.DecodeFile(path, manifest); err != nil {
+		return nil, errors.Wrapf(err, "reading build manifest %s", style.Symbol(path))
+	}
+	if len(manifest.Builds) == 0 {
+		return nil, fmt.Errorf("build manifest %s does not contain any builds", style.Symbol(path))
+	}
+
+	dir := filepath.Dir(path)
+	for i := range manifest.Builds {
+		build := &manifest.Builds[i]
+		if build.Image == "" {
+			return nil, fmt.Errorf("build %d in manifest %s is missing required key %s", i+1, style.Symbol(path), style.Symbol("image"))
+		}
+		if build.Path != "" && !filepath.IsAbs(build.Path) {
+			build.Path = filepath.Join(dir, build.Path)
+		}
+		if build.EnvFile != "" && !filepath.IsAbs(build.EnvFile) {
+			build.EnvFile = filepath.Join(dir, build.EnvFile)
+		}
+	}
+	return manifest, nil
+}
+
+// ReadAppsManifest reads the AppsManifest in dir
+func ReadAppsManifest(dir string) (*BuildManifest, error) {
+	path := filepath.Join(dir, AppsManifest)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found in %s, it must list the path and image of each app to build", style.Symbol(AppsManifest), style.Symbol(dir))
+	}
+	return ReadBuildManifest(path)
+}
+
+// BuildFlags returns the flags for a manifest entry, falling back to defaults for unset keys
+func (m ManifestBuild) BuildFlags(defaults BuildFlags) BuildFlags {
+	flags := defaults
+	flags.RepoName = m.Image
+	if m.Path != "" {
+		flags.AppDir = m.Path
+	}
+	if m.Builder != "" {
+		flags.Builder = m.Builder
+	}
+	if m.RunImage != "" {
+		flags.RunImage = m.RunImage
+	}
+	if m.EnvFile != "" {
+		flags.EnvFiles = []string{m.EnvFile}
+	}
+	if len(m.Buildpacks) > 0 {
+		flags.Buildpacks = m.Buildpacks
+	}
+	return flags
+}
+
+// BuildBatch builds every entry of the manifest, running at most manifest.Jobs builds at once (one by default).
+// The output of builds running at once is prefixed with their image.
+// A failing build does not stop the others; the returned results are in manifest order. Builders and run
+// images shared by several builds are only pulled by the first of them. Once ctx is canceled, running
+// builds are stopped and the remaining ones are not started.
+func (bf *BuildFactory) BuildBatch(ctx context.Context, manifest *BuildManifest, defaults BuildFlags) []BatchResult {
+	jobs := manifest.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	shared := *bf
+	shared.ImageFactory = &sharedPullImageFactory{ImageFactory: bf.ImageFactory, pulls: map[string]*sharedPull{}}
+	bf = &shared
+
+	results := make([]BatchResult, len(manifest.Builds))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, build := range manifest.Builds {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, build ManifestBuild) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				results[i] = BatchResult{RepoName: build.Image, Err: ErrInterrupted}
+				return
+			}
+
+			entry := bf
+			if jobs > 1 {
+				// builds running at once share the terminal, so their output is prefixed with their image
+				prefixed := *bf
+				prefixed.Logger = bf.Logger.WithPrefix(build.Image)
+				entry = &prefixed
+			}
+			start := time.Now()
+			id, err := entry.buildManifestEntry(ctx, build, defaults)
+			results[i] = BatchResult{
+				RepoName:   build.Image,
+				Identifier: id,
+				Duration:   time.Since(start),
+				Err:        err,
+			}
+			if err != nil {
+				bf.Logger.Error("Failed to build image %s: %s", style.Symbol(build.Image), err)
+			} else {
+				bf.Logger.Info("Built image %s", style.Symbol(build.Image))
+			}
+		}(i, build)
+	}
+	wg.Wait()
+	return results
+}
+
+// MatrixManifest expands a build of one app against several builders and/or run images into a
+// manifest with one build per combination. Each output image has its tag suffixed with the
+// builder and run image used, e.g. some/app:v1 built with packs/samples:v3 becomes some/app:v1-samples-v3.
+// Images referenced by digest can't be suffixed, and are rejected, as are combinations that would get the
+// same tag, e.g. with the builders a/samples:v3 and b/samples:v3.
+func MatrixManifest(flags BuildFlags, builders, runImages []string) (*BuildManifest, error) {
+	if len(builders) == 0 && len(runImages) == 0 {
+		return nil, fmt.Errorf("a build matrix requires at least one builder or run image")
+	}
+	for _, imageName := range append(append([]string{flags.RepoName}, builders...), runImages...) {
+		if strings.Contains(imageName, "@") {
+			return nil, fmt.Errorf("image %s is referenced by digest, which can't be used in a build matrix", style.Symbol(imageName))
+		}
+	}
+	if len(builders) == 0 {
+		builders = []string{flags.Builder}
+	}
+	if len(runImages) == 0 {
+		runImages = []string{flags.RunImage}
+	}
+
+	manifest := &BuildManifest{}
+	tagged := map[string]ManifestBuild{}
+	for _, builder := range builders {
+		for _, runImage := range runImages {
+			var suffixes []string
+			if builder != flags.Builder {
+				suffixes = append(suffixes, imageSuffix(builder))
+			}
+			if runImage != flags.RunImage {
+				suffixes = append(suffixes, imageSuffix(runImage))
+			}
+			build := ManifestBuild{
+				Path:     flags.AppDir,
+				Image:    suffixTag(flags.RepoName, strings.Join(suffixes, "-")),
+				Builder:  builder,
+				RunImage: runImage,
+			}
+			if other, ok := tagged[build.Image]; ok {
+				return nil, fmt.Errorf(
+					"builds with builder %s and run image %s and with builder %s and run image %s would both be tagged %s",
+					style.Symbol(other.Builder), style.Symbol(other.RunImage), style.Symbol(builder), style.Symbol(runImage), style.Symbol(build.Image),
+				)
+			}
+			tagged[build.Image] = build
+			manifest.Builds = append(manifest.Builds, build)
+		}
+	}
+	return manifest, nil
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// imageSuffix derives a tag-safe suffix from an image name using its repository basename and tag
+func imageSuffix(imageName string) string {
+	repo, tag := splitTag(imageName)
+	suffix := filepath.Base(repo)
+	if tag != "" && tag != "latest" {
+		suffix += "-" + tag
+	}
+	return invalidTagChars.ReplaceAllString(suffix, "-")
+}
+
+func suffixTag(imageName, suffix string) string {
+	if suffix == "" {
+		return imageName
+	}
+	repo, tag := splitTag(imageName)
+	if tag == "" {
+		return repo + ":" + suffix
+	}
+	return repo + ":" + tag + "-" + suffix
+}
+
+func splitTag(imageName string) (repo, tag string) {
+	i := strings.LastIndex(imageName, ":")
+	if i < 0 || strings.Contains(imageName[i:], "/") {
+		return imageName, ""
+	}
+	return imageName[:i], imageName[i+1:]
+}
+
+func (bf *BuildFactory) buildManifestEntry(ctx context.Context, build ManifestBuild, defaults BuildFlags) (Identifier, error) {
+	bf.Logger.Info("Building image %s", style.Symbol(build.Image))
+	flags := build.BuildFlags(defaults)
+	b, err := bf.BuildConfigFromFlags(&flags)
+	if err != nil {
+		return nil, err
+	}
+	if len(build.Env) > 0 {
+		if b.EnvFile == nil {
+			b.EnvFile = map[string]string{}
+		}
+		for k, v := range build.Env {
+			b.EnvFile[k] = v
+		}
+	}
+	if err := b.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	return b.Identifier, nil
+}
+
+// sharedPullImageFactory pulls each image at most once for the builds of a batch. Builds pulling an image
+// another build is pulling wait for it, then use the pulled image.
+type sharedPullImageFactory struct {
+	ImageFactory
+	mu    sync.Mutex
+	pulls map[string]*sharedPull
+}
+
+type sharedPull struct {
+	sync.Mutex
+	pulled bool
+}
+
+func (f *sharedPullImageFactory) NewLocal(repoName string, pull bool) (image.Image, error) {
+	if !pull {
+		return f.ImageFactory.NewLocal(repoName, false)
+	}
+	f.mu.Lock()
+	p, ok := f.pulls[repoName]
+	if !ok {
+		p = &sharedPull{}
+		f.pulls[repoName] = p
+	}
+	f.mu.Unlock()
+
+	p.Lock()
+	defer p.Unlock()
+	if p.pulled {
+		return f.ImageFactory.NewLocal(repoName, false)
+	}
+	img, err := f.ImageFactory.NewLocal(repoName, true)
+	if err == nil {
+		p.pulled = true
+	}
+	return img, err
+}
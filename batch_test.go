@@ -0,0 +1,197 @@
+package pack_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBatch(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "batch", testBatch, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBatch(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.batch.test.")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	when("#ReadBuildManifest", func() {
+		it("resolves relative paths against the manifest directory", func() {
+			manifestPath := filepath.Join(tmpDir, "builds.toml")
+			h.AssertNil(t, ioutil.WriteFile(manifestPath, []byte(`
+jobs = 2
+
+[[builds]]
+path = "apps/api"
+image = "some/api"
+env-file = "api.env"
+
+[[builds]]
+path = "/abs/worker"
+image = "some/worker"
+builder = "some/builder"
+[builds.env]
+KEY = "value"
+`), 0666))
+
+			manifest, err := pack.ReadBuildManifest(manifestPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Jobs, 2)
+			h.AssertEq(t, len(manifest.Builds), 2)
+			h.AssertEq(t, manifest.Builds[0].Path, filepath.Join(tmpDir, "apps", "api"))
+			h.AssertEq(t, manifest.Builds[0].EnvFile, filepath.Join(tmpDir, "api.env"))
+			h.AssertEq(t, manifest.Builds[1].Path, "/abs/worker")
+			h.AssertEq(t, manifest.Builds[1].Builder, "some/builder")
+			h.AssertEq(t, manifest.Builds[1].Env, map[string]string{"KEY": "value"})
+		})
+
+		it("requires an image for every build", func() {
+			manifestPath := filepath.Join(tmpDir, "builds.toml")
+			h.AssertNil(t, ioutil.WriteFile(manifestPath, []byte(`
+[[builds]]
+path = "apps/api"
+`), 0666))
+
+			_, err := pack.ReadBuildManifest(manifestPath)
+			h.AssertError(t, err, "build 1 in manifest '"+manifestPath+"' is missing required key 'image'")
+		})
+	})
+
+	when("#ReadAppsManifest", func() {
+		it("reads the apps manifest in the dir", func() {
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, pack.AppsManifest), []byte(`
+[[builds]]
+path = "apps/api"
+image = "some/api"
+
+[[builds]]
+path = "apps/worker"
+image = "some/worker"
+`), 0666))
+
+			manifest, err := pack.ReadAppsManifest(tmpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Builds), 2)
+			h.AssertEq(t, manifest.Builds[0].Path, filepath.Join(tmpDir, "apps", "api"))
+			h.AssertEq(t, manifest.Builds[1].Image, "some/worker")
+		})
+
+		it("fails when the dir has no apps manifest", func() {
+			_, err := pack.ReadAppsManifest(tmpDir)
+			h.AssertError(t, err, "'pack-apps.toml' not found in '"+tmpDir+"', it must list the path and image of each app to build")
+		})
+	})
+
+	when("ManifestBuild#BuildFlags", func() {
+		it("falls back to the defaults for unset keys", func() {
+			flags := pack.ManifestBuild{
+				Image:    "some/app",
+				RunImage: "some/run",
+			}.BuildFlags(pack.BuildFlags{
+				Builder:  "default/builder",
+				RunImage: "default/run",
+				Publish:  true,
+			})
+			h.AssertEq(t, flags.RepoName, "some/app")
+			h.AssertEq(t, flags.Builder, "default/builder")
+			h.AssertEq(t, flags.RunImage, "some/run")
+			h.AssertEq(t, flags.Publish, true)
+		})
+
+		it("keeps the --gid of the defaults", func() {
+			gid := 2000
+			flags := pack.ManifestBuild{Image: "some/app"}.BuildFlags(pack.BuildFlags{GID: &gid})
+			h.AssertEq(t, *flags.GID, 2000)
+		})
+	})
+	when("#BuildBatch", func() {
+		it("does not start builds once the context is canceled", func() {
+			factory := &pack.BuildFactory{Logger: logging.NewLogger(ioutil.Discard, ioutil.Discard, false, false)}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			results := factory.BuildBatch(ctx, &pack.BuildManifest{
+				Builds: []pack.ManifestBuild{{Image: "some/api"}, {Image: "some/worker"}},
+			}, pack.BuildFlags{})
+			h.AssertEq(t, len(results), 2)
+			for _, result := range results {
+				h.AssertEq(t, result.Err, pack.ErrInterrupted)
+			}
+		})
+	})
+
+	when("#MatrixManifest", func() {
+		it("creates a build per builder and run image combination with suffixed tags", func() {
+			manifest, err := pack.MatrixManifest(pack.BuildFlags{
+				AppDir:   "some/dir",
+				RepoName: "registry.com:5000/some/app:v1",
+			}, []string{"packs/samples:v3alpha2", "other/builder"}, []string{"some/run:bionic", "some/run:alpine"})
+			h.AssertNil(t, err)
+
+			var images []string
+			for _, build := range manifest.Builds {
+				h.AssertEq(t, build.Path, "some/dir")
+				images = append(images, build.Image)
+			}
+			h.AssertEq(t, images, []string{
+				"registry.com:5000/some/app:v1-samples-v3alpha2-run-bionic",
+				"registry.com:5000/some/app:v1-samples-v3alpha2-run-alpine",
+				"registry.com:5000/some/app:v1-builder-run-bionic",
+				"registry.com:5000/some/app:v1-builder-run-alpine",
+			})
+		})
+
+		it("adds a tag to untagged images and only suffixes dimensions that vary", func() {
+			manifest, err := pack.MatrixManifest(pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+			}, nil, []string{"some/run:bionic"})
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Builds), 1)
+			h.AssertEq(t, manifest.Builds[0].Image, "some/app:run-bionic")
+			h.AssertEq(t, manifest.Builds[0].Builder, "some/builder")
+			h.AssertEq(t, manifest.Builds[0].RunImage, "some/run:bionic")
+		})
+
+		it("rejects images referenced by digest", func() {
+			for _, c := range []struct {
+				flags     pack.BuildFlags
+				runImages []string
+				image     string
+			}{
+				{pack.BuildFlags{RepoName: "some/app@sha256:abc"}, []string{"some/run:bionic"}, "some/app@sha256:abc"},
+				{pack.BuildFlags{RepoName: "some/app"}, []string{"some/run@sha256:abc"}, "some/run@sha256:abc"},
+			} {
+				_, err := pack.MatrixManifest(c.flags, nil, c.runImages)
+				h.AssertError(t, err, "image '"+c.image+"' is referenced by digest, which can't be used in a build matrix")
+			}
+		})
+
+		it("rejects builds that would get the same tag", func() {
+			_, err := pack.MatrixManifest(pack.BuildFlags{
+				RepoName: "some/app",
+				RunImage: "some/run",
+			}, []string{"a/samples:v3", "b/samples:v3"}, nil)
+			h.AssertError(t, err, "builds with builder 'a/samples:v3' and run image 'some/run' and with builder 'b/samples:v3' and run image 'some/run' would both be tagged 'some/app:samples-v3'")
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	bindingsDir = platformDir + "/bindings"
+	// serviceBindingRootEnv tells the app where to find the bindings at launch
+	serviceBindingRootEnv = "SERVICE_BINDING_ROOT"
+)
+
+// parseBindings converts --binding directories into read-only binds beneath the bindings dir.
+// Each binding is named after its directory, as buildpacks look bindings up by name.
+func parseBindings(dirs []string) ([]string, error) {
+	var binds []string
+	names := map[string]bool{}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if fi, err := os.Stat(abs); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("invalid binding %s: must be a directory", style.Symbol(dir))
+		}
+		name := filepath.Base(abs)
+		if names[name] {
+			return nil, fmt.Errorf("invalid binding %s: a binding named %s is already provided", style.Symbol(dir), style.Symbol(name))
+		}
+		names[name] = true
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", abs, path.Join(bindingsDir, name)))
+	}
+	return binds, nil
+}
+
+// bindingNames returns the names of the bindings, without the host paths they are mounted from
+func (b *BuildConfig) bindingNames() []string {
+	var names []string
+	for _, bind := range b.Bindings {
+		parts := strings.Split(bind, ":")
+		names = append(names, path.Base(parts[len(parts)-2]))
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// BillOfMaterials lists what went into a built image: the buildpacks of the group chosen by the detector,
+// the layers each of them contributed with the metadata they recorded, such as dependency versions, and
+// the build plan
+type BillOfMaterials struct {
+	Image      string                 `toml:"image" json:"image"`
+	RunImage   string                 `toml:"run-image" json:"runImage"`
+	Buildpacks []BOMBuildpack         `toml:"buildpacks" json:"buildpacks"`
+	Plan       map[string]interface{} `toml:"plan,omitempty" json:"plan,omitempty"`
+}
+
+type BOMBuildpack struct {
+	ID      string     `toml:"id" json:"id"`
+	Version string     `toml:"version" json:"version"`
+	Layers  []BOMLayer `toml:"layers,omitempty" json:"layers,omitempty"`
+}
+
+type BOMLayer struct {
+	Name     string      `toml:"name" json:"name"`
+	Launch   bool        `toml:"launch" json:"launch"`
+	Build    bool        `toml:"build" json:"build"`
+	Metadata interface{} `toml:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+// BOM collects the bill of materials of the image produced by Run from the workspace and the metadata
+// label the export set on the image
+func (b *BuildConfig) BOM() (*BillOfMaterials, error) {
+	result, err := b.ReadDetectResult()
+	if err != nil {
+		return nil, err
+	}
+	bom := &BillOfMaterials{
+		Image:      b.RepoName,
+		RunImage:   b.RunImage,
+		Buildpacks: []BOMBuildpack{},
+	}
+	if _, err := toml.Decode(result.Plan, &bom.Plan); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", style.Symbol(planPath))
+	}
+	if len(bom.Plan) == 0 {
+		bom.Plan = nil
+	}
+
+	metadata, err := b.readExportedMetadata()
+	if err != nil {
+		return nil, err
+	}
+	for _, bp := range result.Group.Buildpacks {
+		entry := BOMBuildpack{ID: bp.ID, Version: bp.Version}
+		if bpMetadata := buildpackMetadata(metadata, bp.ID); bpMetadata != nil {
+			var names []string
+			for name := range bpMetadata.Layers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				layer := bpMetadata.Layers[name]
+				entry.Layers = append(entry.Layers, BOMLayer{Name: name, Launch: layer.Launch, Build: layer.Build, Metadata: layer.Data})
+			}
+		}
+		bom.Buildpacks = append(bom.Buildpacks, entry)
+	}
+	return bom, nil
+}
+
+// readExportedMetadata reads the lifecycle metadata label of the exported image
+func (b *BuildConfig) readExportedMetadata() (*lifecycle.AppImageMetadata, error) {
+	var img image.Image
+	var err error
+	if b.Publish {
+		img, err = b.ImageFactory.NewRemote(b.RepoName)
+	} else {
+		img, err = b.ImageFactory.NewLocal(b.RepoName, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	label, err := img.Label(lifecycleMetadataLabel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading label %s of %s", style.Symbol(lifecycleMetadataLabel), style.Symbol(b.RepoName))
+	}
+	var metadata lifecycle.AppImageMetadata
+	if label == "" {
+		return &metadata, nil
+	}
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return nil, errors.Wrapf(err, "decoding label %s of %s", style.Symbol(lifecycleMetadataLabel), style.Symbol(b.RepoName))
+	}
+	return &metadata, nil
+}
+
+// writeBOM writes the bill of materials of the build to path, as JSON when it has a .json extension and
+// TOML otherwise
+func (b *BuildConfig) writeBOM(path string) error {
+	bom, err := b.BOM()
+	if err != nil {
+		return err
+	}
+	return writeDocument(path, "bill of materials", bom)
+}
+
+// printBOM logs the bill of materials of the build as TOML
+func (b *BuildConfig) printBOM() error {
+	bom, err := b.BOM()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(bom); err != nil {
+		return err
+	}
+	b.Logger.Info("Bill of materials:")
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		b.Logger.Info("  %s", line)
+	}
+	return nil
+}
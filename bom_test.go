@@ -0,0 +1,108 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBillOfMaterials(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "bill-of-materials", testBillOfMaterials, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBillOfMaterials(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject          *pack.BuildConfig
+		mockController   *gomock.Controller
+		mockDocker       *mocks.MockDocker
+		mockImageFactory *mocks.MockImageFactory
+		mockImage        *mocks.MockImage
+		outBuf           bytes.Buffer
+		ctr              container.ContainerCreateCreatedBody
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		mockImageFactory = mocks.NewMockImageFactory(mockController)
+		mockImage = mocks.NewMockImage(mockController)
+		subject = &pack.BuildConfig{
+			RepoName:     "registry.com/some/app",
+			RunImage:     "some/run",
+			Builder:      "some/builder",
+			CacheVolume:  "some-cache-volume",
+			Cli:          mockDocker,
+			ImageFactory: mockImageFactory,
+			Logger:       logging.NewLogger(&outBuf, &outBuf, false, false),
+		}
+		ctr = container.ContainerCreateCreatedBody{ID: "some-container-id"}
+
+		singleFileTar := func(name, contents string) *bytes.Buffer {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}))
+			_, err := tw.Write([]byte(contents))
+			h.AssertNil(t, err)
+			h.AssertNil(t, tw.Close())
+			return &buf
+		}
+		mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").Return(ctr, nil)
+		mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, dockertypes.ContainerRemoveOptions{}).Return(nil)
+		mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/group.toml").
+			Return(ioutil.NopCloser(singleFileTar("group.toml", "[[buildpacks]]\nid = \"some.bp\"\nversion = \"1.2.3\"\n")), dockertypes.ContainerPathStat{}, nil)
+		mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/plan.toml").
+			Return(ioutil.NopCloser(singleFileTar("plan.toml", "[node]\nversion = \"10.x\"\n")), dockertypes.ContainerPathStat{}, nil)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#BOM", func() {
+		it("lists the layers each buildpack contributed to a daemon image and the build plan", func() {
+			mockImageFactory.EXPECT().NewLocal("registry.com/some/app", false).Return(mockImage, nil)
+			mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").
+				Return(`{"buildpacks":[{"key":"some.bp","layers":{"node":{"sha":"sha256:abc","data":{"version":"10.15.0"},"launch":true},"modules":{"sha":"sha256:def","launch":true}}}]}`, nil)
+
+			bom, err := subject.BOM()
+			h.AssertNil(t, err)
+			h.AssertEq(t, bom, &pack.BillOfMaterials{
+				Image:    "registry.com/some/app",
+				RunImage: "some/run",
+				Buildpacks: []pack.BOMBuildpack{{
+					ID:      "some.bp",
+					Version: "1.2.3",
+					Layers: []pack.BOMLayer{
+						{Name: "modules", Launch: true},
+						{Name: "node", Launch: true, Metadata: map[string]interface{}{"version": "10.15.0"}},
+					},
+				}},
+				Plan: map[string]interface{}{"node": map[string]interface{}{"version": "10.x"}},
+			})
+		})
+
+		it("reads the metadata of a published image from the registry", func() {
+			subject.Publish = true
+			mockImageFactory.EXPECT().NewRemote("registry.com/some/app").Return(mockImage, nil)
+			mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").Return("", nil)
+
+			bom, err := subject.BOM()
+			h.AssertNil(t, err)
+			h.AssertEq(t, bom.Buildpacks, []pack.BOMBuildpack{{ID: "some.bp", Version: "1.2.3"}})
+		})
+	})
+}
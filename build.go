@@ -5,14 +5,19 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
-	"runtime"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/buildpack/pack/logging"
@@ -30,6 +35,7 @@ import (
 	"github.com/buildpack/lifecycle"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/pkg/errors"
 )
 
@@ -39,46 +45,188 @@ type BuildFactory struct {
 	FS           FS
 	Config       *config.Config
 	ImageFactory ImageFactory
+	CacheUsage   *config.CacheUsage
+	CacheLocks   *config.CacheLocks
+	// AppManifests is the directory recording the app files uploaded to each cache volume
+	AppManifests string
+	// RemoteDaemon is set when the docker daemon is on another machine, see BuildConfig.compressed
+	RemoteDaemon bool
+	// SelectBuilder chooses a builder from suggestions when none is configured. It is nil when pack
+	// doesn't run on a terminal, and builds without a builder fail.
+	SelectBuilder func(suggestions []string) (string, error)
+	// PullRunImageInBackground defers the pull of the run image of daemon builds to Run, where it overlaps
+	// the upload of the app. BuildConfigFromFlags validates the stack against the run image in the registry.
+	PullRunImageInBackground bool
+	// RegistryImageCreated returns when the image in the registry was created, to warn about stale local
+	// builders. It is nil in tests.
+	RegistryImageCreated func(repoName string) (time.Time, error)
+	// CheckPushAccess fails when images can't be pushed to repoName. Builds check each repository they
+	// push to before building, so credential problems show up in seconds. It is nil in tests.
+	CheckPushAccess func(repoName string) error
 }
 
 type BuildFlags struct {
-	AppDir     string
-	Builder    string
-	RunImage   string
-	EnvFile    string
-	RepoName   string
-	Publish    bool
-	NoPull     bool
-	ClearCache bool
-	Buildpacks []string
+	AppDir         string
+	Builder        string
+	RunImage       string
+	EnvFiles       []string
+	Env            []string
+	LaunchEnvFiles []string
+	LaunchEnv      []string
+	Descriptor     string
+	RepoName       string
+	Tags           []string
+	PreviousImage  string
+	Publish        bool
+	NoPull         bool
+	ClearCache     bool
+	ClearLaunch    bool
+	Buildpacks     []string
+	Order          string
+	LifecycleImage string
+	Retries        int
+	DefaultProcess string
+	Memory         string
+	CPUs           float64
+	Network        string
+	Volumes        []string
+	Bindings       []string
+	Labels         []string
+	PullPolicy     string
+	Cache          string
+	CacheImage     string
+	DetectOnly     bool
+	DryRun         bool
+	ReportPath     string
+	BOMPath        string
+	PrintBOM       bool
+	Timeout        time.Duration
+	ClearOnCancel  bool
+	Debug          bool
+	Creator        bool
+	Platform       string
+	Load           bool
+	CreationTime   string
+	SourceCommit   string
+	SourceBranch   string
+	SourceURL      string
+	Offline        bool
+	Sandbox        bool
+	Output         string
+	Sparse         bool
+	StopAfter      string
+	CACerts        []string
+	// LifecycleVersion selects a lifecycle release to use instead of the builder's lifecycle
+	LifecycleVersion string
+	// GID, when set, overrides the builder's PACK_GROUP_ID
+	GID *int
 }
 
+// Pull policies for the images of daemon builds and rebases
+const (
+	// PullIfChanged pulls unless the local run image is valid and its digest matches the registry.
+	// Other images are always pulled.
+	PullIfChanged = "if-changed"
+	// PullIfNotPresent pulls only when no valid image exists locally
+	PullIfNotPresent = "if-not-present"
+	// PullAlways pulls before every build
+	PullAlways = "always"
+	// PullNever only uses local images
+	PullNever = "never"
+)
+
 type BuildConfig struct {
-	AppDir     string
-	Builder    string
-	RunImage   string
-	EnvFile    map[string]string
-	RepoName   string
-	Publish    bool
-	NoPull     bool
-	ClearCache bool
-	Buildpacks []string
+	AppDir         string
+	Builder        string
+	RunImage       string
+	EnvFile        map[string]string
+	LaunchEnv      map[string]string
+	RepoName       string
+	Tags           []string
+	PreviousImage  string
+	Publish        bool
+	NoPull         bool
+	ClearCache     bool
+	ClearLaunch    bool
+	Buildpacks     []string
+	Order          lifecycle.BuildpackOrder
+	LifecycleImage string
+	Retries        int
+	DefaultProcess string
+	Resources      container.Resources
+	Network        string
+	Volumes        []string
+	Bindings       []string
+	Labels         map[string]string
+	CacheImage     string
+	DetectOnly     bool
+	DryRun         bool
+	ReportPath     string
+	BOMPath        string
+	PrintBOM       bool
+	Timeout        time.Duration
+	ClearOnCancel  bool
+	Debug          bool
+	Creator        bool
+	Platform       string
+	Load           bool
+	CreationTime   *time.Time
+	Include        []string
+	Exclude        []string
+	Offline        bool
+	Sandbox        bool
+	StopAfter      string
+	// ProjectSource is the source of the app, recorded on the image when known
+	ProjectSource *ProjectSource
+	// Output is where the image is written after the build, besides the daemon
+	Output *Output
+	// CACerts are PEM encoded CA certificates trusted by the lifecycle containers besides the builder's
+	CACerts []byte
+	// AppReader provides the app as a tar stream when AppDir is StdinAppDir
+	AppReader io.Reader
+	// StackID is the stack of the builder, resolved by BuildConfigFromFlags
+	StackID string
+	// LifecycleVersion is the lifecycle release used instead of the builder's lifecycle
+	LifecycleVersion string
+	// GID, when set, is the group owning the workspace and the exported layers instead of the builder's PACK_GROUP_ID
+	GID *int
+	// TrustBuilder lets the creator of the builder run with the registry credentials of the build
+	TrustBuilder bool
 	// Above are copied from BuildFlags are set by init
-	Cli    Docker
-	Logger *logging.Logger
-	FS     FS
-	Config *config.Config
+	Cli          Docker
+	Logger       *logging.Logger
+	FS           FS
+	Config       *config.Config
+	ImageFactory ImageFactory
+	CacheUsage   *config.CacheUsage
+	CacheLocks   *config.CacheLocks
+	AppManifests string
+	RemoteDaemon bool
 	// Above are copied from BuildFactory
-	CacheVolume string
+	CacheVolume      string
+	lifecycleVolume  string
+	ephemeralBuilder string
+	appRead          bool
+	platformAPI      platformAPI
+	lifecycleBins    string
+	// runImagePull is the run image Run pulls in the background, see BuildFactory.PullRunImageInBackground
+	runImagePull string
+	// ctx is canceled when the build must stop, see RunContext
+	ctx context.Context
+	// Identifier identifies the image produced by Run
+	Identifier Identifier
 }
 
 const (
 	launchDir     = "/workspace"
+	lifecycleDir  = "/lifecycle"
 	buildpacksDir = "/buildpacks"
 	platformDir   = "/platform"
 	orderPath     = "/buildpacks/order.toml"
 	groupPath     = `/workspace/group.toml`
 	planPath      = "/workspace/plan.toml"
+	// layersCacheDir keeps the layers buildpacks mark as cached, restored before analyzing and saved after exporting
+	layersCacheDir = "/workspace/.cache"
 )
 
 func DefaultBuildFactory(logger *logging.Logger) (*BuildFactory, error) {
@@ -87,11 +235,15 @@ func DefaultBuildFactory(logger *logging.Logger) (*BuildFactory, error) {
 		FS:     &fs.FS{},
 	}
 
-	var err error
-	f.Cli, err = docker.New()
+	cli, err := docker.New()
 	if err != nil {
 		return nil, err
 	}
+	f.Cli = cli
+	f.RemoteDaemon = docker.IsRemote(cli.DaemonHost())
+	if f.RemoteDaemon {
+		logger.Verbose("Docker daemon %s is remote, uploads to it are compressed", style.Symbol(cli.DaemonHost()))
+	}
 
 	f.Config, err = config.NewDefault()
 	if err != nil {
@@ -103,9 +255,28 @@ func DefaultBuildFactory(logger *logging.Logger) (*BuildFactory, error) {
 		return nil, err
 	}
 
+	f.CacheUsage, err = config.NewCacheUsage(config.PackHome())
+	if err != nil {
+		return nil, err
+	}
+	f.CacheLocks = config.NewCacheLocks(config.PackHome())
+	f.AppManifests = filepath.Join(config.PackHome(), "app-manifests")
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		f.SelectBuilder = promptBuilder(os.Stdin, logger)
+	}
+	f.CheckPushAccess = CheckPushAccess
+	f.RegistryImageCreated = RegistryImageCreated
+	f.PullRunImageInBackground = true
+
 	return f, nil
 }
 
+// FlagError is returned by BuildConfigFromFlags for invalid flags or configuration, as opposed to failures
+// pulling images or reaching the daemon
+type FlagError struct {
+	error
+}
+
 func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error) {
 	if f.AppDir == "" {
 		var err error
@@ -115,52 +286,341 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		}
 		bf.Logger.Verbose("Defaulting app directory to current working directory %s (use --path to override)", style.Symbol(f.AppDir))
 	}
-	appDir, err := filepath.Abs(f.AppDir)
-	if err != nil {
+	var err error
+	appDir := f.AppDir
+	if appDir == StdinAppDir {
+		if f.RepoName == "" {
+			return nil, FlagError{fmt.Errorf("an image name is required when reading the app from stdin")}
+		}
+	} else if appDir, err = filepath.Abs(f.AppDir); err != nil {
 		return nil, err
 	}
 
 	if f.RepoName == "" {
-		f.RepoName = fmt.Sprintf("pack.local/run/%x", md5.Sum([]byte(appDir)))
+		f.RepoName, err = bf.defaultRepoName(appDir)
+		if err != nil {
+			return nil, FlagError{err}
+		}
+	}
+	if err := validateImageReference("image name", f.RepoName); err != nil {
+		return nil, FlagError{err}
+	}
+	for _, tag := range f.Tags {
+		if err := validateImageReference("--tag", tag); err != nil {
+			return nil, FlagError{err}
+		}
+	}
+	if err := validateImageReference("--builder", f.Builder); err != nil {
+		return nil, FlagError{err}
+	}
+	if err := validateImageReference("--run-image", f.RunImage); err != nil {
+		return nil, FlagError{err}
+	}
+	if err := validateImageReference("--previous-image", f.PreviousImage); err != nil {
+		return nil, FlagError{err}
+	}
+	if err := validateImageReference("--lifecycle-image", f.LifecycleImage); err != nil {
+		return nil, FlagError{err}
+	}
+	if f.Load && !f.Publish {
+		return nil, FlagError{fmt.Errorf("%s can only be used with %s", style.Symbol("--load"), style.Symbol("--publish"))}
+	}
+	if err := validateStopAfter(f); err != nil {
+		return nil, FlagError{err}
+	}
+	output, err := parseOutput(f.Output)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	caCerts, err := readCACerts(append(append([]string{}, bf.Config.CACerts...), f.CACerts...))
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	if output != nil && f.Publish {
+		return nil, FlagError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--output"), style.Symbol("--publish"))}
+	}
+	if f.Sparse {
+		if output == nil {
+			return nil, FlagError{fmt.Errorf("%s can only be used with %s", style.Symbol("--sparse"), style.Symbol("--output"))}
+		}
+		output.Sparse = true
+	}
+	if f.LifecycleImage != "" && f.LifecycleVersion != "" {
+		return nil, FlagError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--lifecycle-version"), style.Symbol("--lifecycle-image"))}
+	}
+	if f.GID != nil && *f.GID < 0 {
+		return nil, FlagError{fmt.Errorf("invalid %s %d: must not be negative", style.Symbol("--gid"), *f.GID)}
+	}
+	if f.LifecycleVersion != "" && !lifecycleVersionRegexp.MatchString(f.LifecycleVersion) {
+		return nil, FlagError{fmt.Errorf("invalid lifecycle version %s: must be of the form 'major.minor.patch'", style.Symbol(f.LifecycleVersion))}
+	}
+	if err := validatePlatform(f.Platform); err != nil {
+		return nil, FlagError{err}
+	}
+	creationTime, err := parseCreationTime(f.CreationTime)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	if f.Platform != "" {
+		// images are pulled for the platform rather than the daemon's own
+		withPlatform := *bf
+		withPlatform.ImageFactory = &platformImageFactory{ImageFactory: bf.ImageFactory, cli: bf.Cli, platform: f.Platform}
+		bf = &withPlatform
+		if f.Publish {
+			bf.Logger.Warn("%s only applies to images on the daemon, the lifecycle resolves the run image from the registry itself", style.Symbol("--platform"))
+		}
+	}
+
+	memory, cpus := f.Memory, f.CPUs
+	if memory == "" {
+		memory = bf.Config.BuildMemory
+	}
+	if cpus == 0 {
+		cpus = bf.Config.BuildCPUs
+	}
+	resources, err := parseResources(memory, cpus)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	volumes, err := parseVolumes(f.Volumes)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	if f.Sandbox {
+		if err := validateSandbox(f); err != nil {
+			return nil, FlagError{err}
+		}
+	}
+	bindings, err := parseBindings(f.Bindings)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	labels, err := parseLabels(f.Labels)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	var order lifecycle.BuildpackOrder
+	if f.Order != "" {
+		if order, err = readOrder(f.Order); err != nil {
+			return nil, FlagError{err}
+		}
+	}
+	cache := f.Cache
+	if f.CacheImage != "" {
+		if cache != "" {
+			return nil, FlagError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--cache-image"), style.Symbol("--cache"))}
+		}
+		// --cache-image is shorthand for a registry cache
+		cache = fmt.Sprintf("type=%s,ref=%s", CacheTypeRegistry, f.CacheImage)
+	}
+	cacheOpts, err := ParseCacheOptions(cache)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	if f.Offline {
+		if err := validateOffline(f, cacheOpts.Ref); err != nil {
+			return nil, FlagError{err}
+		}
+		offline := *bf
+		offline.ImageFactory = &offlineImageFactory{ImageFactory: bf.ImageFactory}
+		bf = &offline
+	}
+	if _, err := ParseCacheTTL(bf.Config.CacheTTL); err != nil {
+		return nil, FlagError{err}
 	}
 
 	b := &BuildConfig{
-		AppDir:     appDir,
-		RepoName:   f.RepoName,
-		Publish:    f.Publish,
-		NoPull:     f.NoPull,
-		ClearCache: f.ClearCache,
-		Buildpacks: f.Buildpacks,
-		Cli:        bf.Cli,
-		Logger:     bf.Logger,
-		FS:         bf.FS,
-		Config:     bf.Config,
-	}
-
-	if f.EnvFile != "" {
-		b.EnvFile, err = parseEnvFile(f.EnvFile)
-		if err != nil {
+		AppDir:         appDir,
+		RepoName:       f.RepoName,
+		Tags:           f.Tags,
+		PreviousImage:  f.PreviousImage,
+		GID:            f.GID,
+		Publish:        f.Publish,
+		NoPull:         f.NoPull,
+		ClearCache:     f.ClearCache,
+		ClearLaunch:    f.ClearLaunch,
+		Buildpacks:     f.Buildpacks,
+		Order:          order,
+		LifecycleImage: f.LifecycleImage,
+		Retries:        f.Retries,
+		DefaultProcess: f.DefaultProcess,
+		Resources:      resources,
+		Network:        f.Network,
+		Volumes:        volumes,
+		Bindings:       bindings,
+		Labels:         labels,
+		CacheImage:     cacheOpts.Ref,
+		DetectOnly:     f.DetectOnly,
+		DryRun:         f.DryRun,
+		ReportPath:     f.ReportPath,
+		BOMPath:        f.BOMPath,
+		PrintBOM:       f.PrintBOM,
+		Timeout:        f.Timeout,
+		ClearOnCancel:  f.ClearOnCancel,
+		Debug:          f.Debug,
+		Creator:        f.Creator,
+		Platform:       f.Platform,
+		Load:           f.Load,
+		CreationTime:   creationTime,
+		Offline:        f.Offline,
+		Sandbox:        f.Sandbox,
+		StopAfter:      f.StopAfter,
+		ProjectSource:  projectSource(appDir, f.SourceCommit, f.SourceBranch, f.SourceURL),
+		Output:         output,
+		CACerts:        caCerts,
+		Cli:            bf.Cli,
+		Logger:         bf.Logger,
+		FS:             bf.FS,
+		Config:         bf.Config,
+		ImageFactory:   bf.ImageFactory,
+		CacheUsage:     bf.CacheUsage,
+		CacheLocks:     bf.CacheLocks,
+		AppManifests:   bf.AppManifests,
+		RemoteDaemon:   bf.RemoteDaemon,
+	}
+
+	if appDir == StdinAppDir {
+		b.AppReader = os.Stdin
+	}
+	if f.Offline {
+		b.Network = "none"
+	}
+
+	descriptor, err := bf.projectDescriptor(f.Descriptor, appDir)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	if descriptor != nil {
+		if len(b.Buildpacks) == 0 {
+			b.Buildpacks = descriptor.buildpackRefs()
+		}
+		b.Include = descriptor.Build.Include
+		b.Exclude = descriptor.Build.Exclude
+	}
+
+	if len(f.EnvFiles) > 0 || len(f.Env) > 0 || (descriptor != nil && len(descriptor.Build.Env) > 0) {
+		b.EnvFile = map[string]string{}
+		if descriptor != nil {
+			for _, env := range descriptor.Build.Env {
+				b.EnvFile[env.Name] = env.Value
+			}
+		}
+		if err := mergeEnv(b.EnvFile, f.EnvFiles, f.Env); err != nil {
+			return nil, FlagError{err}
+		}
+	}
+	if creationTime != nil {
+		if b.EnvFile == nil {
+			b.EnvFile = map[string]string{}
+		}
+		if _, ok := b.EnvFile[SourceDateEpochEnv]; !ok {
+			b.EnvFile[SourceDateEpochEnv] = strconv.FormatInt(creationTime.Unix(), 10)
+		}
+	}
+	if len(f.LaunchEnvFiles) > 0 || len(f.LaunchEnv) > 0 {
+		b.LaunchEnv = map[string]string{}
+		if err := mergeEnv(b.LaunchEnv, f.LaunchEnvFiles, f.LaunchEnv); err != nil {
+			return nil, FlagError{err}
+		}
+	}
+
+	if bf.CheckPushAccess != nil {
+		var pushed []string
+		if f.Publish {
+			pushed = append([]string{f.RepoName}, f.Tags...)
+		}
+		if err := bf.checkPushAccess(append(pushed, cacheOpts.Ref)...); err != nil {
 			return nil, err
 		}
 	}
 
-	if f.Builder == "" {
+	if f.Builder == "" && bf.Config.DefaultBuilder == "" {
+		if bf.SelectBuilder == nil {
+			return nil, FlagError{noBuilderError()}
+		}
+		if b.Builder, err = bf.SelectBuilder(bf.Config.SuggestedBuilders()); err != nil {
+			return nil, err
+		}
+		bf.Logger.Verbose("Using selected builder image %s", style.Symbol(b.Builder))
+	} else if f.Builder == "" {
 		bf.Logger.Verbose("Using default builder image %s", style.Symbol(bf.Config.DefaultBuilder))
 		b.Builder = bf.Config.DefaultBuilder
+		if err := validateImageReference("default builder", b.Builder); err != nil {
+			return nil, FlagError{err}
+		}
 	} else {
 		bf.Logger.Verbose("Using user-provided builder image %s", style.Symbol(f.Builder))
 		b.Builder = f.Builder
 	}
-	if !f.NoPull {
-		bf.Logger.Verbose("Pulling builder image %s (use --no-pull flag to skip this step)", style.Symbol(b.Builder))
+	b.TrustBuilder = bf.Config.IsTrustedBuilder(b.Builder)
+	defaultPullPolicy := PullIfChanged
+	if f.Offline {
+		// images on the daemon are used as they are, without checking the registry for newer ones
+		defaultPullPolicy = PullIfNotPresent
 	}
-
-	builderImage, err := bf.ImageFactory.NewLocal(b.Builder, !f.NoPull)
+	pullPolicy, err := resolvePullPolicy(f.PullPolicy, f.NoPull, defaultPullPolicy)
 	if err != nil {
-		return nil, err
+		return nil, FlagError{err}
 	}
+	b.NoPull = pullPolicy == PullNever
 
-	builderStackID, err := builderImage.Label("io.buildpacks.stack.id")
+	newRunImage := func(name string) (image.Image, error) {
+		if f.Publish {
+			return bf.ImageFactory.NewRemote(name)
+		}
+		if pullPolicy == PullNever {
+			return bf.ImageFactory.NewLocal(name, false)
+		}
+		if pullPolicy != PullAlways {
+			if local, ok := bf.reusableRunImage(name, pullPolicy); ok {
+				return local, nil
+			}
+		}
+		if bf.PullRunImageInBackground && !f.DryRun && !f.DetectOnly && !f.Offline && f.StopAfter == "" {
+			// the image in the registry tells the stack of the run image, it is pulled while the app is uploaded
+			bf.Logger.Verbose("Pulling run image %s while the app is uploaded (use --pull-policy never to skip this step)", style.Symbol(name))
+			b.runImagePull = name
+			return bf.ImageFactory.NewRemote(name)
+		}
+		bf.Logger.Verbose("Pulling run image %s (use --pull-policy never to skip this step)", style.Symbol(name))
+		return bf.ImageFactory.NewLocal(name, true)
+	}
+
+	// Images that are known up front are resolved concurrently; the run image can only be
+	// resolved early when provided, otherwise it is selected from the builder's stack.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	builderImageCh := resolveImage(&wg, func() (image.Image, error) {
+		img, err := localImage(bf.ImageFactory, bf.Logger, "builder", b.Builder, pullPolicy)
+		if err == nil && (pullPolicy == PullNever || pullPolicy == PullIfNotPresent) && !f.Offline {
+			bf.warnIfStaleBuilder(b.Builder, img)
+		}
+		return img, err
+	})
+
+	var runImageCh <-chan resolvedImage
+	if f.RunImage != "" {
+		bf.Logger.Verbose("Using user-provided run image %s", style.Symbol(f.RunImage))
+		b.RunImage = f.RunImage
+		runImageCh = resolveImage(&wg, func() (image.Image, error) {
+			return newRunImage(b.RunImage)
+		})
+	}
+
+	var lifecycleImageCh <-chan resolvedImage
+	if f.LifecycleImage != "" {
+		lifecycleImageCh = resolveImage(&wg, func() (image.Image, error) {
+			return localImage(bf.ImageFactory, bf.Logger, "lifecycle", f.LifecycleImage, pullPolicy)
+		})
+	}
+
+	builder := <-builderImageCh
+	if builder.err != nil {
+		return nil, builder.err
+	}
+
+	builderStackID, err := builder.image.Label("io.buildpacks.stack.id")
 	if err != nil {
 		return nil, fmt.Errorf("invalid builder image %s: %s", style.Symbol(b.Builder), err)
 	}
@@ -171,11 +631,9 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 	if err != nil {
 		return nil, err
 	}
+	b.StackID = builderStackID
 
-	if f.RunImage != "" {
-		bf.Logger.Verbose("Using user-provided run image %s", style.Symbol(f.RunImage))
-		b.RunImage = f.RunImage
-	} else {
+	if runImageCh == nil {
 		reg, err := config.Registry(f.RepoName)
 		if err != nil {
 			return nil, err
@@ -185,41 +643,228 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 			return nil, err
 		}
 		b.Logger.Verbose("Selected run image %s from stack %s", style.Symbol(b.RunImage), style.Symbol(builderStackID))
+		runImageCh = resolveImage(&wg, func() (image.Image, error) {
+			return newRunImage(b.RunImage)
+		})
+	}
+
+	run := <-runImageCh
+	if run.err != nil {
+		return nil, run.err
 	}
 
-	var runImage image.Image
-	if f.Publish {
-		runImage, err = bf.ImageFactory.NewRemote(b.RunImage)
+	if runStackID, err := run.image.Label("io.buildpacks.stack.id"); err != nil {
+		return nil, fmt.Errorf("invalid run image %s: %s", style.Symbol(b.RunImage), err)
+	} else if runStackID == "" {
+		return nil, fmt.Errorf("invalid run image %s: missing required label %s", style.Symbol(b.RunImage), style.Symbol("io.buildpacks.stack.id"))
+	} else if builderStackID != runStackID {
+		compatible, err := stacksCompatible(builder.image, builderStackID, run.image, runStackID)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		if !f.NoPull {
-			bf.Logger.Verbose("Pulling run image %s (use --no-pull flag to skip this step)", style.Symbol(b.RunImage))
+		if !compatible {
+			return nil, FlagError{fmt.Errorf("invalid stack: stack %s from run image %s does not match stack %s from builder image %s", style.Symbol(runStackID), style.Symbol(b.RunImage), style.Symbol(builderStackID), style.Symbol(b.Builder))}
 		}
-		runImage, err = bf.ImageFactory.NewLocal(b.RunImage, !f.NoPull)
+		bf.Logger.Warn("stack %s from run image %s does not match stack %s from builder image %s, but they are declared compatible", style.Symbol(runStackID), style.Symbol(b.RunImage), style.Symbol(builderStackID), style.Symbol(b.Builder))
+	}
+
+	// the lifecycle comes from the builder unless a lifecycle image is given
+	lifecycleImage, lifecycleSource := builder.image, b.Builder
+	if lifecycleImageCh != nil {
+		lifecycleImg := <-lifecycleImageCh
+		if lifecycleImg.err != nil {
+			return nil, lifecycleImg.err
+		}
+		if found, err := lifecycleImg.image.Found(); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, fmt.Errorf("lifecycle image %s does not exist on the daemon", style.Symbol(f.LifecycleImage))
+		}
+		bf.Logger.Verbose("Using lifecycle from image %s", style.Symbol(f.LifecycleImage))
+		lifecycleImage, lifecycleSource = lifecycleImg.image, f.LifecycleImage
+	}
+	var apiVersion, lifecycleDesc string
+	if f.LifecycleVersion != "" {
+		b.LifecycleVersion = f.LifecycleVersion
+		b.lifecycleBins, apiVersion, err = downloadLifecycle(bf.Logger, bf.FS, filepath.Join(bf.Config.Path(), "dl-cache"), f.LifecycleVersion)
 		if err != nil {
 			return nil, err
 		}
+		bf.Logger.Verbose("Using lifecycle %s", style.Symbol(f.LifecycleVersion))
+		lifecycleDesc = "lifecycle " + style.Symbol(f.LifecycleVersion)
+	} else {
+		if apiVersion, err = imagePlatformAPI(lifecycleImage, lifecycleSource); err != nil {
+			return nil, err
+		}
+		lifecycleDesc = "the lifecycle in " + style.Symbol(lifecycleSource)
 	}
-
-	if runStackID, err := runImage.Label("io.buildpacks.stack.id"); err != nil {
-		return nil, fmt.Errorf("invalid run image %s: %s", style.Symbol(b.RunImage), err)
-	} else if runStackID == "" {
-		return nil, fmt.Errorf("invalid run image %s: missing required label %s", style.Symbol(b.RunImage), style.Symbol("io.buildpacks.stack.id"))
-	} else if builderStackID != runStackID {
-		return nil, fmt.Errorf("invalid stack: stack %s from run image %s does not match stack %s from builder image %s", style.Symbol(runStackID), style.Symbol(b.RunImage), style.Symbol(builderStackID), style.Symbol(b.Builder))
+	if b.platformAPI, err = negotiatePlatformAPI(apiVersion, lifecycleDesc); err != nil {
+		return nil, err
 	}
+	bf.Logger.Verbose("Using platform API %s", style.Symbol(b.platformAPI.Version))
 
-	b.CacheVolume, err = CacheVolume(f.RepoName)
-	if err != nil {
+	if cacheOpts.Name != "" {
+		b.CacheVolume = cacheOpts.Name
+	} else if b.CacheVolume, err = CacheVolume(f.RepoName); err != nil {
 		return nil, err
 	}
 	bf.Logger.Verbose(fmt.Sprintf("Using cache volume %s", style.Symbol(b.CacheVolume)))
 
+	if b.DryRun {
+		b.logResolvedConfig()
+	}
 	return b, nil
 }
 
+// compatibleStacksLabel lists, comma-separated, the other stack IDs an image can be used with
+const compatibleStacksLabel = "io.buildpacks.stack.compatible-ids"
+
+// stacksCompatible reports whether either image declares the other's stack compatible
+func stacksCompatible(builderImage image.Image, builderStackID string, runImage image.Image, runStackID string) (bool, error) {
+	for _, c := range []struct {
+		image   image.Image
+		stackID string
+	}{
+		{builderImage, runStackID},
+		{runImage, builderStackID},
+	} {
+		ids, err := c.image.Label(compatibleStacksLabel)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range strings.Split(ids, ",") {
+			if strings.TrimSpace(id) == c.stackID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// resolvePullPolicy validates the pull policy, defaulting to defaultPolicy. noPull is the deprecated
+// spelling of the never policy.
+func resolvePullPolicy(pullPolicy string, noPull bool, defaultPolicy string) (string, error) {
+	if noPull {
+		return PullNever, nil
+	}
+	switch pullPolicy {
+	case "":
+		return defaultPolicy, nil
+	case PullIfChanged, PullIfNotPresent, PullAlways, PullNever:
+		return pullPolicy, nil
+	}
+	return "", fmt.Errorf("invalid pull policy %s: must be one of %s, %s, %s or %s", style.Symbol(pullPolicy), style.Symbol(PullIfChanged), style.Symbol(PullIfNotPresent), style.Symbol(PullAlways), style.Symbol(PullNever))
+}
+
+// localImage returns the local image name, pulling it first unless the pull policy allows using a local copy
+func localImage(imageFactory ImageFactory, logger *logging.Logger, kind, name, pullPolicy string) (image.Image, error) {
+	switch pullPolicy {
+	case PullNever:
+		return imageFactory.NewLocal(name, false)
+	case PullIfNotPresent:
+		if local, err := imageFactory.NewLocal(name, false); err == nil {
+			if found, err := local.Found(); err == nil && found {
+				logger.Verbose("Using local %s image %s (pull policy is %s)", kind, style.Symbol(name), style.Symbol(pullPolicy))
+				return local, nil
+			}
+		}
+	}
+	logger.Verbose("Pulling %s image %s (use --pull-policy never to skip this step)", kind, style.Symbol(name))
+	return imageFactory.NewLocal(name, true)
+}
+
+// reusableRunImage returns the local run image when the pull policy allows skipping the pull.
+// Any error while checking is treated as a reason to pull.
+func (bf *BuildFactory) reusableRunImage(name, pullPolicy string) (image.Image, bool) {
+	local, err := bf.ImageFactory.NewLocal(name, false)
+	if err != nil {
+		return nil, false
+	}
+	if found, err := local.Found(); err != nil || !found {
+		return nil, false
+	}
+	if stackID, err := local.Label("io.buildpacks.stack.id"); err != nil || stackID == "" {
+		return nil, false
+	}
+
+	if pullPolicy == PullIfNotPresent {
+		bf.Logger.Verbose("Using local run image %s (pull policy is %s)", style.Symbol(name), style.Symbol(pullPolicy))
+		return local, true
+	}
+
+	localDigest, err := local.Digest()
+	if err != nil {
+		return nil, false
+	}
+	remote, err := bf.ImageFactory.NewRemote(name)
+	if err != nil {
+		return nil, false
+	}
+	remoteDigest, err := remote.Digest()
+	if err != nil || remoteDigest != localDigest {
+		return nil, false
+	}
+	bf.Logger.Verbose("Local run image %s is up to date, skipping pull", style.Symbol(name))
+	return local, true
+}
+
+type resolvedImage struct {
+	image image.Image
+	err   error
+}
+
+// resolveImage pulls or looks up an image in the background
+func resolveImage(wg *sync.WaitGroup, resolve func() (image.Image, error)) <-chan resolvedImage {
+	ch := make(chan resolvedImage, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		img, err := resolve()
+		ch <- resolvedImage{image: img, err: err}
+	}()
+	return ch
+}
+
+var invalidRepoChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// defaultRepoName renders the configured image name template for an app dir
+func (bf *BuildFactory) defaultRepoName(appDir string) (string, error) {
+	tmplText := bf.Config.ImageNameTemplate
+	if tmplText == "" {
+		tmplText = config.DefaultImageNameTemplate
+	}
+	tmpl, err := template.New("image-name-template").Parse(tmplText)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing image name template %s", style.Symbol(tmplText))
+	}
+
+	sanitize := func(s string) string {
+		return strings.Trim(invalidRepoChars.ReplaceAllString(strings.ToLower(s), "-"), "-.")
+	}
+	data := struct {
+		Basename string
+		Hash     string
+		Branch   string
+	}{
+		Basename: sanitize(filepath.Base(appDir)),
+		Hash:     fmt.Sprintf("%x", md5.Sum([]byte(appDir))),
+	}
+	if strings.Contains(tmplText, ".Branch") {
+		out, err := exec.Command("git", "-C", appDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+		if err != nil {
+			return "", fmt.Errorf("image name template %s requires %s to be a git repository", style.Symbol(tmplText), style.Symbol(appDir))
+		}
+		data.Branch = sanitize(strings.TrimSpace(string(out)))
+	}
+
+	var repoName strings.Builder
+	if err := tmpl.Execute(&repoName, data); err != nil {
+		return "", errors.Wrapf(err, "rendering image name template %s", style.Symbol(tmplText))
+	}
+	bf.Logger.Verbose("Defaulting image name to %s", style.Symbol(repoName.String()))
+	return repoName.String(), nil
+}
+
 // TODO: This function has no tests! Also, should it take a `BuildFlags` object instead of all these args?
 func Build(logger *logging.Logger, appDir, buildImage, runImage, repoName string, publish, clearCache bool) error {
 	bf, err := DefaultBuildFactory(logger)
@@ -240,30 +885,377 @@ func Build(logger *logging.Logger, appDir, buildImage, runImage, repoName string
 	return b.Run()
 }
 
+// TimeoutError is returned when a build does not finish within its --timeout
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("build timed out after %s", e.Timeout)
+}
+
 func (b *BuildConfig) Run() error {
-	if err := b.Detect(); err != nil {
+	return b.RunContext(context.Background())
+}
+
+// ErrInterrupted is returned when the context of a build is canceled, e.g. on SIGINT
+var ErrInterrupted = errors.New("build interrupted")
+
+// RunContext runs the build, stopping and removing the lifecycle container of the running phase when ctx
+// is canceled or the build's timeout passes. The cache volume, which may hold a partial build, is removed
+// too when ClearOnCancel is set.
+func (b *BuildConfig) RunContext(ctx context.Context) error {
+	if b.DryRun {
+		return nil
+	}
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	b.ctx = ctx
+	defer func() { b.ctx = nil }()
+	defer b.removeEphemeralBuilder()
+
+	unlock, err := b.lockCacheVolume(ctx)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	b.Logger.Verbose(style.Step("ANALYZING"))
-	b.Logger.Verbose("Reading information from previous image for possible re-use")
-	if err := b.Analyze(); err != nil {
+	err = b.run()
+	if err == nil || ctx.Err() == nil {
 		return err
 	}
+	if b.ClearOnCancel {
+		for _, volume := range []string{b.CacheVolume, LayersCacheVolume(b.CacheVolume)} {
+			if err := b.Cli.VolumeRemove(context.Background(), volume, true); err != nil {
+				b.Logger.Error("Unable to remove cache volume %s: %s", style.Symbol(volume), err)
+			} else {
+				b.Logger.Verbose("Removed cache volume %s", style.Symbol(volume))
+			}
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Timeout: b.Timeout}
+	}
+	return ErrInterrupted
+}
+
+// startRunImagePull pulls the run image in the background when BuildConfigFromFlags left its pull to Run.
+// The returned func waits for the pull and checks the pulled image as prepareVolumes does.
+func (b *BuildConfig) startRunImagePull() func() error {
+	if b.runImagePull == "" {
+		return func() error { return nil }
+	}
+	name := b.runImagePull
+	pulled := make(chan error, 1)
+	go func() {
+		_, err := b.ImageFactory.NewLocal(name, true)
+		pulled <- err
+	}()
+	return func() error {
+		if err := <-pulled; err != nil {
+			return errors.Wrapf(err, "pulling run image %s", style.Symbol(name))
+		}
+		b.runImagePull = ""
+		return b.validateStackOS(b.context())
+	}
+}
+
+// cacheLockPollInterval is how often a build waiting for the cache volume checks whether it is free
+const cacheLockPollInterval = time.Second
+
+// lockCacheVolume waits until no other build uses the cache volume and takes its lock, so concurrent
+// builds of the same app don't corrupt each other's cache
+func (b *BuildConfig) lockCacheVolume(ctx context.Context) (func(), error) {
+	if b.CacheLocks == nil {
+		return func() {}, nil
+	}
+	waiting := false
+	for {
+		unlock, err := b.CacheLocks.Lock(b.CacheVolume)
+		if err == nil {
+			return func() {
+				if err := unlock(); err != nil {
+					b.Logger.Verbose("Unable to unlock cache volume %s: %s", style.Symbol(b.CacheVolume), err)
+				}
+			}, nil
+		}
+		if _, ok := err.(*config.CacheLockedError); !ok {
+			return nil, errors.Wrapf(err, "locking cache volume %s", style.Symbol(b.CacheVolume))
+		}
+		if !waiting {
+			b.Logger.Info("Cache volume %s is in use by another build, waiting for it to finish", style.Symbol(b.CacheVolume))
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, &TimeoutError{Timeout: b.Timeout}
+			}
+			return nil, ErrInterrupted
+		case <-time.After(cacheLockPollInterval):
+		}
+	}
+}
+
+func (b *BuildConfig) run() error {
+	if b.CacheImage != "" && !b.ClearCache {
+		if err := b.withRetries("restore cache", b.RestoreRegistryCache); err != nil {
+			return err
+		}
+	}
 
-	b.Logger.Verbose(style.Step("BUILDING"))
-	if err := b.Build(); err != nil {
+	runImagePulled := b.startRunImagePull()
+	creator, err := b.useCreator()
+	if err != nil {
 		return err
 	}
+	if creator {
+		if err := runImagePulled(); err != nil {
+			return err
+		}
+		b.Logger.Verbose(style.Step("CREATING"))
+		if err := b.withRetries("create", b.Create); err != nil {
+			return err
+		}
+		if b.DefaultProcess != "" {
+			if err := b.validateDefaultProcess(); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := b.withRetries("detect", b.Detect); err != nil {
+			return err
+		}
+		if b.DetectOnly {
+			return b.printDetectResult()
+		}
+		if err := b.logDetectSummary(); err != nil {
+			return err
+		}
+		if b.stopsAfter(StopAfterDetect) {
+			return nil
+		}
+		if err := runImagePulled(); err != nil {
+			return err
+		}
+
+		b.Logger.Verbose(style.Step("RESTORING"))
+		if err := b.withRetries("restore", b.Restore); err != nil {
+			return err
+		}
+
+		b.Logger.Verbose(style.Step("ANALYZING"))
+		b.Logger.Verbose("Reading information from previous image for possible re-use")
+		if err := b.withRetries("analyze", b.Analyze); err != nil {
+			return err
+		}
+		if b.stopsAfter(StopAfterAnalyze) {
+			return nil
+		}
+
+		b.Logger.Verbose(style.Step("BUILDING"))
+		if err := b.withRetries("build", b.Build); err != nil {
+			return err
+		}
+		if b.stopsAfter(StopAfterBuild) {
+			return nil
+		}
+
+		if b.DefaultProcess != "" {
+			if err := b.validateDefaultProcess(); err != nil {
+				return err
+			}
+		}
+
+		b.Logger.Verbose(style.Step("EXPORTING"))
+		if err := b.withRetries("export", b.Export); err != nil {
+			return err
+		}
+
+		b.Logger.Verbose(style.Step("CACHING"))
+		if err := b.withRetries("cache", b.Cache); err != nil {
+			return err
+		}
+	}
+
+	if b.CacheImage != "" {
+		if err := b.withRetries("publish cache", b.PublishRegistryCache); err != nil {
+			return err
+		}
+	}
 
-	b.Logger.Verbose(style.Step("EXPORTING"))
-	if err := b.Export(); err != nil {
+	if err := b.withRetries("label", b.SetBuildMetadata); err != nil {
 		return err
 	}
 
+	if b.CreationTime != nil {
+		if err := b.withRetries("creation time", b.SetCreationTime); err != nil {
+			return err
+		}
+	}
+
+	if len(b.Tags) > 0 {
+		if err := b.withRetries("tag", b.Tag); err != nil {
+			return err
+		}
+	}
+
+	if b.Load {
+		if err := b.withRetries("load", b.LoadPublished); err != nil {
+			return err
+		}
+	}
+
+	if b.Output != nil {
+		if err := b.WriteOutput(); err != nil {
+			return err
+		}
+	}
+
+	if b.ReportPath != "" {
+		if err := b.writeReport(b.ReportPath); err != nil {
+			return err
+		}
+		b.Logger.Verbose("Wrote build report to %s", style.Symbol(b.ReportPath))
+	}
+	if b.BOMPath != "" {
+		if err := b.writeBOM(b.BOMPath); err != nil {
+			return err
+		}
+		b.Logger.Verbose("Wrote bill of materials to %s", style.Symbol(b.BOMPath))
+	}
+	if b.PrintBOM {
+		if err := b.printBOM(); err != nil {
+			return err
+		}
+	}
+
+	b.recordCacheUse()
+	return nil
+}
+
+// context returns the context of the running build, see RunContext
+func (b *BuildConfig) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+// removeContainer force removes a lifecycle container, stopping it if a canceled build left it running.
+// It does not use the build's context, which may already be done.
+func (b *BuildConfig) removeContainer(ctrID string) {
+	// the anonymous volumes of sandboxed phases go with their container, named volumes are kept
+	b.Cli.ContainerRemove(context.Background(), ctrID, dockertypes.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+}
+
+// createCacheVolume creates the cache volume and its layers cache volume, labelled with the image they cache,
+// if they don't exist yet. The workspace is owned by the builder's user, so the lifecycle phases don't need
+// to run as root. The files pack copies to the workspace are owned by that user already, so its owner is
+// only set when the volumes are new or were last used by another user.
+func (b *BuildConfig) createCacheVolume(ctx context.Context) error {
+	var created []string
+	for _, name := range []string{b.CacheVolume, LayersCacheVolume(b.CacheVolume)} {
+		vol, err := b.Cli.VolumeCreate(ctx, volume.VolumeCreateBody{
+			Name:   name,
+			Labels: map[string]string{CacheImageLabel: b.RepoName},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "creating cache volume %s", style.Symbol(name))
+		}
+		created = append(created, vol.CreatedAt)
+	}
+
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
+	// volumes recreated under the same name have another creation time
+	owner := fmt.Sprintf("%d:%d %s", uid, gid, strings.Join(created, " "))
+	if b.CacheUsage != nil && created[0] != "" && created[1] != "" && b.CacheUsage.Owner(b.CacheVolume) == owner {
+		b.Logger.Verbose("Cache volume %s is owned by %d:%d already", style.Symbol(b.CacheVolume), uid, gid)
+		return nil
+	}
+	ctrID, err := createVolumesContainer(ctx, b.Cli, b.Builder, cacheBinds(b.CacheVolume))
+	if err != nil {
+		return err
+	}
+	defer b.removeContainer(ctrID)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeTarDir(tw, launchDir, uid, gid); err != nil {
+		return err
+	}
+	if err := writeTarDir(tw, layersCacheDir, uid, gid); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", &buf, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrapf(err, "setting the owner of cache volume %s", style.Symbol(b.CacheVolume))
+	}
+	if b.CacheUsage != nil {
+		if err := b.CacheUsage.SetOwner(b.CacheVolume, owner); err != nil {
+			b.Logger.Verbose("Unable to record the owner of cache volume %s: %s", style.Symbol(b.CacheVolume), err)
+		}
+	}
 	return nil
 }
 
+// recordCacheUse notes that the cache volume was just used and, when a cache-ttl is configured,
+// points out cache volumes that have gone unused for longer
+func (b *BuildConfig) recordCacheUse() {
+	if b.CacheUsage == nil {
+		return
+	}
+	if err := b.CacheUsage.Touch(b.CacheVolume, time.Now()); err != nil {
+		b.Logger.Verbose("Unable to record use of cache volume %s: %s", style.Symbol(b.CacheVolume), err)
+		return
+	}
+	if b.Config == nil || b.Config.CacheTTL == "" {
+		return
+	}
+	ttl, err := ParseCacheTTL(b.Config.CacheTTL)
+	if err != nil {
+		return
+	}
+	pruner := &CachePruner{Cli: b.Cli, Logger: b.Logger, Usage: b.CacheUsage}
+	if stale, err := pruner.Stale(ttl, time.Now()); err == nil && len(stale) > 0 {
+		b.Logger.Tip("%d cache volume(s) have not been used for more than %s, run %s to remove them", len(stale), b.Config.CacheTTL, style.Symbol("pack cache prune"))
+	}
+}
+
+// readOrder reads the groups of an order.toml, defaulting the version of their buildpacks to latest
+func readOrder(path string) (lifecycle.BuildpackOrder, error) {
+	var order struct {
+		Groups lifecycle.BuildpackOrder `toml:"groups"`
+	}
+	if _, err := toml.DecodeFile(path, &order); err != nil {
+		return nil, errors.Wrapf(err, "reading order %s", style.Symbol(path))
+	}
+	if len(order.Groups) == 0 {
+		return nil, fmt.Errorf("order %s has no groups", style.Symbol(path))
+	}
+	for i, group := range order.Groups {
+		if len(group.Buildpacks) == 0 {
+			return nil, fmt.Errorf("group %d of order %s has no buildpacks", i+1, style.Symbol(path))
+		}
+		for _, bp := range group.Buildpacks {
+			if bp.ID == "" {
+				return nil, fmt.Errorf("group %d of order %s has a buildpack without an id", i+1, style.Symbol(path))
+			}
+			if bp.Version == "" {
+				bp.Version = "latest"
+			}
+		}
+	}
+	return order.Groups, nil
+}
+
 func (b *BuildConfig) parseBuildpack(ref string) (string, string) {
 	parts := strings.Split(ref, "@")
 	if len(parts) == 2 {
@@ -277,30 +1269,43 @@ func (b *BuildConfig) copyBuildpacksToContainer(ctx context.Context, ctrID strin
 	var buildpacks []*lifecycle.Buildpack
 	for _, bp := range b.Buildpacks {
 		var id, version string
-		if _, err := os.Stat(filepath.Join(bp, "buildpack.toml")); !os.IsNotExist(err) {
-			if runtime.GOOS == "windows" {
-				return nil, fmt.Errorf("directory buildpacks are not implemented on windows")
+		if isBuildpackURL(bp) {
+			if b.Offline {
+				return nil, fmt.Errorf("buildpack %s cannot be downloaded with %s", style.Symbol(bp), style.Symbol("--offline"))
 			}
-			var buildpackTOML struct {
-				Buildpack Buildpack
+			dir, err := downloadAndExtract(b.Logger, b.FS, filepath.Join(b.Config.Path(), "dl-cache"), bp)
+			if err != nil {
+				return nil, err
 			}
-
-			_, err = toml.DecodeFile(filepath.Join(bp, "buildpack.toml"), &buildpackTOML)
+			if id, version, err = b.copyExtractedBuildpack(ctx, ctrID, dir, bp); err != nil {
+				return nil, err
+			}
+		} else if isBuildpackArchive(bp) {
+			dir, cleanup, err := extractBuildpackArchive(b.FS, bp)
 			if err != nil {
-				return nil, fmt.Errorf(`failed to decode buildpack.toml from "%s": %s`, bp, err)
+				return nil, err
 			}
-			id = buildpackTOML.Buildpack.ID
-			version = buildpackTOML.Buildpack.Version
-			bpDir := filepath.Join(buildpacksDir, buildpackTOML.Buildpack.escapedID(), version)
-			ftr, errChan := b.FS.CreateTarReader(bp, bpDir, 0, 0)
-			if err := b.Cli.CopyToContainer(ctx, ctrID, "/", ftr, dockertypes.CopyToContainerOptions{}); err != nil {
-				return nil, errors.Wrapf(err, "copying buildpack '%s' to container", bp)
+			id, version, err = b.copyExtractedBuildpack(ctx, ctrID, dir, bp)
+			cleanup()
+			if err != nil {
+				return nil, err
 			}
-			if err := <-errChan; err != nil {
-				return nil, errors.Wrapf(err, "copying buildpack '%s' to container", bp)
+		} else if _, err := os.Stat(filepath.Join(bp, "buildpack.toml")); !os.IsNotExist(err) {
+			if id, version, err = b.copyBuildpackDir(ctx, ctrID, bp); err != nil {
+				return nil, err
 			}
 		} else {
 			id, version = b.parseBuildpack(bp)
+			if strings.Contains(id, "/") && !b.builderHasBuildpack(ctx, ctrID, id, version) {
+				if b.Offline {
+					return nil, fmt.Errorf("buildpack %s is not in the builder and %s forbids looking it up in the buildpack registry", style.Symbol(id+"@"+version), style.Symbol("--offline"))
+				}
+				b.Logger.Verbose("Buildpack %s is not in the builder, looking it up in the buildpack registry", style.Symbol(id+"@"+version))
+				var err error
+				if version, err = b.copyRegistryBuildpack(ctx, ctrID, id, version); err != nil {
+					return nil, err
+				}
+			}
 		}
 		buildpacks = append(
 			buildpacks,
@@ -310,18 +1315,57 @@ func (b *BuildConfig) copyBuildpacksToContainer(ctx context.Context, ctrID strin
 	return buildpacks, nil
 }
 
-func (b *BuildConfig) Detect() error {
-	ctx := context.Background()
+// copyExtractedBuildpack copies a buildpack extracted from source into dir to the container
+func (b *BuildConfig) copyExtractedBuildpack(ctx context.Context, ctrID, dir, source string) (string, string, error) {
+	root, err := buildpackRoot(dir, source)
+	if err != nil {
+		return "", "", err
+	}
+	return b.copyBuildpackDir(ctx, ctrID, root)
+}
 
-	if b.ClearCache {
-		if err := b.Cli.VolumeRemove(ctx, b.CacheVolume, true); err != nil {
-			return errors.Wrap(err, "clearing cache")
-		}
-		b.Logger.Verbose("Cache volume %s cleared", style.Symbol(b.CacheVolume))
+// copyBuildpackDir copies the buildpack in dir to the buildpacks dir of the container, returning its ID and version
+func (b *BuildConfig) copyBuildpackDir(ctx context.Context, ctrID, dir string) (string, string, error) {
+	var buildpackTOML struct {
+		Buildpack Buildpack
+	}
+
+	_, err := toml.DecodeFile(filepath.Join(dir, "buildpack.toml"), &buildpackTOML)
+	if err != nil {
+		return "", "", fmt.Errorf(`failed to decode buildpack.toml from "%s": %s`, dir, err)
+	}
+	if buildpackTOML.Buildpack.ID == "" || buildpackTOML.Buildpack.Version == "" {
+		return "", "", fmt.Errorf(`buildpack.toml from "%s" must provide an id and version`, dir)
+	}
+	version := buildpackTOML.Buildpack.Version
+	// bpDir is a path in the container, which is POSIX even when pack runs on Windows
+	bpDir := path.Join(buildpacksDir, buildpackTOML.Buildpack.escapedID(), version)
+	ftr := b.FS.CreateTarReader(dir, bpDir, 0, 0)
+	upload := b.compressed(ftr)
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", upload, dockertypes.CopyToContainerOptions{}); err != nil {
+		upload.Close()
+		ftr.Close()
+		return "", "", errors.Wrapf(err, "copying buildpack '%s' to container", dir)
+	}
+	if err := upload.Close(); err != nil {
+		ftr.Close()
+		return "", "", errors.Wrapf(err, "copying buildpack '%s' to container", dir)
+	}
+	if err := ftr.Close(); err != nil {
+		return "", "", errors.Wrapf(err, "copying buildpack '%s' to container", dir)
+	}
+	return buildpackTOML.Buildpack.ID, version, nil
+}
+
+func (b *BuildConfig) Detect() error {
+	ctx := b.context()
+	if err := b.prepareVolumes(ctx); err != nil {
+		return err
 	}
 
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
+		Image: b.phaseImage(),
+		Env:   append(b.proxyEnv(), b.caCertsEnv()...),
 		Cmd: []string{
 			"/lifecycle/detector",
 			"-buildpacks", buildpacksDir,
@@ -329,144 +1373,179 @@ func (b *BuildConfig) Detect() error {
 			"-group", groupPath,
 			"-plan", planPath,
 		},
-	}, &container.HostConfig{
-		Binds: []string{
-			fmt.Sprintf("%s:%s:", b.CacheVolume, launchDir),
-		},
-	}, nil, "")
+	}, b.phaseHostConfig(), nil, "")
 	if err != nil {
 		return errors.Wrap(err, "container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
-	var orderToml string
 	b.Logger.Verbose(style.Step("DETECTING"))
-	if len(b.Buildpacks) == 0 {
-		orderToml = "" // use order.toml already in image
-	} else {
-		b.Logger.Verbose("Using manually-provided group")
+	if err := b.copyBuildInputs(ctx, ctr.ID); err != nil {
+		return err
+	}
 
-		buildpacks, err := b.copyBuildpacksToContainer(ctx, ctr.ID)
-		if err != nil {
-			return errors.Wrap(err, "copy buildpacks to container")
-		}
+	if err := b.runPhase(ctx, ctr.ID, "detector"); err != nil {
+		return errors.Wrap(err, "run detect container")
+	}
+	return nil
+}
 
-		groups := lifecycle.BuildpackOrder{
-			lifecycle.BuildpackGroup{
-				Buildpacks: buildpacks,
-			},
+// prepareVolumes prepares the cache volumes, the lifecycle volume and the ephemeral builder used by the phases
+func (b *BuildConfig) prepareVolumes(ctx context.Context) error {
+	if b.ClearCache {
+		if err := b.Cli.VolumeRemove(ctx, LayersCacheVolume(b.CacheVolume), true); err != nil {
+			return errors.Wrap(err, "clearing cache")
 		}
-
-		var tomlBuilder strings.Builder
-		if err := toml.NewEncoder(&tomlBuilder).Encode(map[string]interface{}{"groups": groups}); err != nil {
-			return errors.Wrapf(err, "encoding order.toml: %#v", groups)
+		b.Logger.Verbose("Layers cache volume %s cleared", style.Symbol(LayersCacheVolume(b.CacheVolume)))
+	}
+	if b.ClearLaunch {
+		if err := b.Cli.VolumeRemove(ctx, b.CacheVolume, true); err != nil {
+			return errors.Wrap(err, "clearing launch layers")
 		}
-
-		orderToml = tomlBuilder.String()
+		b.Logger.Verbose("Cache volume %s cleared", style.Symbol(b.CacheVolume))
 	}
 
-	tr, errChan := b.FS.CreateTarReader(b.AppDir, launchDir+"/app", 0, 0)
-	if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
-		return errors.Wrap(err, "copy app to workspace volume")
+	if err := b.createCacheVolume(ctx); err != nil {
+		return err
 	}
 
-	if err := <-errChan; err != nil {
-		return errors.Wrap(err, "copy app to workspace volume")
+	if err := b.validateStackOS(ctx); err != nil {
+		return err
 	}
 
-	uid, gid, err := b.packUidGid(b.Builder)
-	if err != nil {
-		return errors.Wrap(err, "get pack uid gid")
-	}
-	if err := b.chownDir(launchDir+"/app", uid, gid); err != nil {
-		return errors.Wrap(err, "chown app to workspace volume")
+	if err := b.prepareLifecycleVolume(ctx); err != nil {
+		return err
 	}
 
-	if orderToml != "" {
-		ftr, err := b.FS.CreateSingleFileTar(orderPath, orderToml)
-		if err != nil {
-			return errors.Wrap(err, "converting order TOML to tar reader")
-		}
-		if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", ftr, dockertypes.CopyToContainerOptions{}); err != nil {
-			return errors.Wrap(err, fmt.Sprintf("creating %s", orderPath))
+	return b.createEphemeralBuilder(ctx)
+}
+
+// copyBuildInputs copies the order, the app and the build-time environment variables to the
+// container that runs detection
+func (b *BuildConfig) copyBuildInputs(ctx context.Context, ctrID string) error {
+	// order.toml is already in the builder, or in the ephemeral builder when buildpacks are provided
+	if len(b.Order) > 0 && b.ephemeralBuilder == "" {
+		b.Logger.Verbose("Using manually-provided order")
+		if err := b.copyOrderToContainer(ctx, ctrID, b.Order); err != nil {
+			return err
 		}
 	}
 
-	if err := b.copyEnvsToContainer(ctx, ctr.ID); err != nil {
+	if err := b.copyApp(ctx, ctrID); err != nil {
 		return err
 	}
 
-	if err := b.Cli.RunContainer(
-		ctx,
-		ctr.ID,
-		b.Logger.VerboseWriter().WithPrefix("detector"),
-		b.Logger.VerboseErrorWriter().WithPrefix("detector"),
-	); err != nil {
-		return errors.Wrap(err, "run detect container")
+	if err := b.copyCACerts(ctx, ctrID); err != nil {
+		return err
 	}
-	return nil
+	return b.copyEnvsToContainer(ctx, ctrID)
 }
 
-func (b *BuildConfig) Analyze() error {
-	ctx := context.Background()
-	ctrConf := &container.Config{
-		Image: b.Builder,
-	}
-	hostConfig := &container.HostConfig{
-		Binds: []string{
-			fmt.Sprintf("%s:%s:", b.CacheVolume, launchDir),
-		},
-	}
+// Restore copies the layers saved by Cache into the layers dir, so buildpacks find them as cached by cache.toml
+func (b *BuildConfig) Restore() error {
+	return b.runLayersCachePhase("restorer")
+}
 
-	if b.Publish {
-		authHeader, err := authHeader(b.RepoName)
-		if err != nil {
-			return err
-		}
+// Cache saves the layers that buildpacks mark as cached so the next build can restore them
+func (b *BuildConfig) Cache() error {
+	return b.runLayersCachePhase("cacher")
+}
 
-		ctrConf.Env = []string{fmt.Sprintf(`PACK_REGISTRY_AUTH=%s`, authHeader)}
-		ctrConf.Cmd = []string{
-			"/lifecycle/analyzer",
+func (b *BuildConfig) runLayersCachePhase(phase string) error {
+	ctx := b.context()
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image: b.phaseImage(),
+		Cmd: []string{
+			"/lifecycle/" + phase,
+			b.platform().CacheDirFlag, layersCacheDir,
 			"-layers", launchDir,
 			"-group", groupPath,
-			b.RepoName,
-		}
-		hostConfig.NetworkMode = "host"
-	} else {
-		ctrConf.Cmd = []string{
+		},
+	}, &container.HostConfig{
+		Binds:     b.phaseBinds(),
+		Resources: b.Resources,
+	}, nil, "")
+	if err != nil {
+		return errors.Wrapf(err, "%s container create", phase)
+	}
+	defer b.removeContainer(ctr.ID)
+
+	if err := b.runPhase(ctx, ctr.ID, phase); err != nil {
+		return errors.Wrapf(err, "run %s container", phase)
+	}
+	return nil
+}
+
+// registryNetworkMode is the network of the containers that read and write images: the --network when
+// given, else the host's, so registries reachable from the host are reachable from the lifecycle
+func (b *BuildConfig) registryNetworkMode() container.NetworkMode {
+	if b.Network == "" {
+		return "host"
+	}
+	return container.NetworkMode(b.Network)
+}
+
+func (b *BuildConfig) Analyze() error {
+	ctx := b.context()
+	if !b.Publish {
+		// pack reads and writes images on the daemon, so the lifecycle never needs the docker socket
+		return b.analyzeFromDaemon(ctx)
+	}
+
+	authHeader, err := authHeader(b.analyzedImage())
+	if err != nil {
+		return err
+	}
+	ctrConf := &container.Config{
+		Image: b.phaseImage(),
+		Env:   append([]string{fmt.Sprintf(`PACK_REGISTRY_AUTH=%s`, authHeader)}, b.caCertsEnv()...),
+		Cmd: []string{
 			"/lifecycle/analyzer",
 			"-layers", launchDir,
 			"-group", groupPath,
-			"-daemon",
-			b.RepoName,
-		}
-		ctrConf.User = "root"
-		hostConfig.Binds = append(hostConfig.Binds, "/var/run/docker.sock:/var/run/docker.sock")
+			b.analyzedImage(),
+		},
+	}
+	hostConfig := &container.HostConfig{
+		Binds:       b.phaseBinds(),
+		Resources:   b.Resources,
+		NetworkMode: b.registryNetworkMode(),
 	}
 
 	ctr, err := b.Cli.ContainerCreate(ctx, ctrConf, hostConfig, nil, "")
 	if err != nil {
 		return errors.Wrap(err, "analyze container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
-	if err := b.Cli.RunContainer(
-		ctx,
-		ctr.ID,
-		b.Logger.VerboseWriter().WithPrefix("analyzer"),
-		b.Logger.VerboseErrorWriter().WithPrefix("analyzer"),
-	); err != nil {
-		return errors.Wrap(err, "analyze run container")
+	if err := b.copyCACerts(ctx, ctr.ID); err != nil {
+		return err
 	}
 
-	uid, gid, err := b.packUidGid(b.Builder)
-	if err != nil {
-		return errors.Wrap(err, "get pack uid and gid")
+	if err := b.runPhase(ctx, ctr.ID, "analyzer"); err != nil {
+		return errors.Wrap(err, "analyze run container")
 	}
-	if err := b.chownDir(launchDir, uid, gid); err != nil {
-		return errors.Wrap(err, "chown launch dir")
+	return nil
+}
+
+// analyzedImage returns the image whose layer metadata is reused: the previous image when provided,
+// otherwise the image being built
+func (b *BuildConfig) analyzedImage() string {
+	if b.PreviousImage != "" {
+		return b.PreviousImage
 	}
+	return b.RepoName
+}
 
+// validateImageReference fails fast on malformed image names, naming the flag the value came from.
+// Empty values are valid and mean the flag was not provided.
+func validateImageReference(source, imageName string) error {
+	if imageName == "" {
+		return nil
+	}
+	if _, err := name.ParseReference(imageName, name.WeakValidation); err != nil {
+		return fmt.Errorf("invalid %s %s: %s", source, style.Symbol(imageName), err)
+	}
 	return nil
 }
 
@@ -475,6 +1554,11 @@ func authHeader(repoName string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if header, ok, err := cnbRegistryAuth(r.Context().RegistryStr()); err != nil {
+		return "", err
+	} else if ok {
+		return header, nil
+	}
 	auth, err := authn.DefaultKeychain.Resolve(r.Context().Registry)
 	if err != nil {
 		return "", err
@@ -482,10 +1566,26 @@ func authHeader(repoName string) (string, error) {
 	return auth.Authorization()
 }
 
+// cnbRegistryAuth looks up the authorization header for a registry in CNB_REGISTRY_AUTH,
+// a JSON object mapping registries to headers, e.g. {"registry.com": "Basic dXNlcjpwYXNz"}
+func cnbRegistryAuth(registry string) (string, bool, error) {
+	env := os.Getenv("CNB_REGISTRY_AUTH")
+	if env == "" {
+		return "", false, nil
+	}
+	var auths map[string]string
+	if err := json.Unmarshal([]byte(env), &auths); err != nil {
+		return "", false, errors.Wrapf(err, "parsing %s", style.Symbol("CNB_REGISTRY_AUTH"))
+	}
+	header, ok := auths[registry]
+	return header, ok, nil
+}
+
 func (b *BuildConfig) Build() error {
-	ctx := context.Background()
+	ctx := b.context()
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
+		Image: b.phaseImage(),
+		Env:   append(b.proxyEnv(), b.caCertsEnv()...),
 		Cmd: []string{
 			"/lifecycle/builder",
 			"-buildpacks", buildpacksDir,
@@ -494,38 +1594,44 @@ func (b *BuildConfig) Build() error {
 			"-plan", planPath,
 			"-platform", platformDir,
 		},
-	}, &container.HostConfig{
-		Binds: []string{
-			fmt.Sprintf("%s:%s:", b.CacheVolume, launchDir),
-		},
-	}, nil, "")
+	}, b.phaseHostConfig(), nil, "")
 	if err != nil {
 		return errors.Wrap(err, "build container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
-	if len(b.Buildpacks) > 0 {
-		_, err = b.copyBuildpacksToContainer(ctx, ctr.ID)
-		if err != nil {
-			return errors.Wrap(err, "copy buildpacks to container")
-		}
+	if err := b.copyCACerts(ctx, ctr.ID); err != nil {
+		return err
 	}
-
 	if err := b.copyEnvsToContainer(ctx, ctr.ID); err != nil {
 		return err
 	}
 
-	if err = b.Cli.RunContainer(
-		ctx,
-		ctr.ID,
-		b.Logger.VerboseWriter().WithPrefix("builder"),
-		b.Logger.VerboseErrorWriter().WithPrefix("builder"),
-	); err != nil {
+	if err = b.runPhase(ctx, ctr.ID, "builder"); err != nil {
 		return errors.Wrap(err, "running builder in container")
 	}
 	return nil
 }
 
+// mergeEnv adds the variables of envFiles and then vars to env. Later files take precedence over earlier
+// ones, and vars over all files.
+func mergeEnv(env map[string]string, envFiles, vars []string) error {
+	for _, envFile := range envFiles {
+		fileVars, err := parseEnvFile(envFile)
+		if err != nil {
+			return err
+		}
+		for k, v := range fileVars {
+			env[k] = v
+		}
+	}
+	for _, kv := range vars {
+		k, v := parseEnvVar(kv)
+		env[k] = v
+	}
+	return nil
+}
+
 func parseEnvFile(envFile string) (map[string]string, error) {
 	out := make(map[string]string, 0)
 	f, err := ioutil.ReadFile(envFile)
@@ -537,16 +1643,21 @@ func parseEnvFile(envFile string) (map[string]string, error) {
 		if line == "" {
 			continue
 		}
-		arr := strings.SplitN(line, "=", 2)
-		if len(arr) > 1 {
-			out[arr[0]] = arr[1]
-		} else {
-			out[arr[0]] = os.Getenv(arr[0])
-		}
+		k, v := parseEnvVar(line)
+		out[k] = v
 	}
 	return out, nil
 }
 
+// parseEnvVar parses a variable of the form 'VAR=VALUE', or 'VAR' to take the value from the current environment
+func parseEnvVar(kv string) (string, string) {
+	arr := strings.SplitN(kv, "=", 2)
+	if len(arr) > 1 {
+		return arr[0], arr[1]
+	}
+	return arr[0], os.Getenv(arr[0])
+}
+
 func (b *BuildConfig) tarEnvFile() (io.Reader, error) {
 	now := time.Now()
 	var buf bytes.Buffer
@@ -582,65 +1693,298 @@ func (b *BuildConfig) copyEnvsToContainer(ctx context.Context, containerID strin
 }
 
 func (b *BuildConfig) Export() error {
-	ctx := context.Background()
+	ctx := b.context()
+	if !b.Publish {
+		// pack reads and writes images on the daemon, so the lifecycle never needs the docker socket
+		if err := b.exportToDaemon(ctx); err != nil {
+			return &phaseError{error: err, phase: "exporter"}
+		}
+		return nil
+	}
+
+	authHeader, err := authHeader(b.RepoName)
+	if err != nil {
+		return err
+	}
 	ctrConf := &container.Config{
-		Image: b.Builder,
+		Image: b.phaseImage(),
+		Env:   append([]string{fmt.Sprintf(`PACK_REGISTRY_AUTH=%s`, authHeader)}, b.caCertsEnv()...),
+		Cmd: []string{
+			"/lifecycle/exporter",
+			b.platform().RunImageFlag, b.RunImage,
+			"-layers", launchDir,
+			"-group", groupPath,
+			b.RepoName,
+		},
 	}
 	hostConfig := &container.HostConfig{
-		Binds: []string{
-			fmt.Sprintf("%s:%s:", b.CacheVolume, launchDir),
-		},
+		Binds:       b.phaseBinds(),
+		Resources:   b.Resources,
+		NetworkMode: b.registryNetworkMode(),
+	}
+	if b.GID != nil {
+		// the exporter owns the layers it adds by the builder's PACK_GROUP_ID
+		ctrConf.Env = append(ctrConf.Env, fmt.Sprintf("PACK_GROUP_ID=%d", *b.GID))
+	}
+
+	ctr, err := b.Cli.ContainerCreate(ctx, ctrConf, hostConfig, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create export container")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	if err := b.copyCACerts(ctx, ctr.ID); err != nil {
+		return err
+	}
+
+	if err := b.runPhase(ctx, ctr.ID, "exporter"); err != nil {
+		return errors.Wrap(err, "run lifecycle/exporter")
 	}
+	return nil
+}
 
-	if b.Publish {
-		authHeader, err := authHeader(b.RepoName)
+// Tag tags the exported image with each of the build's additional tags. Published images are pushed
+// under every tag; as the layers are already in the registry only the manifest is uploaded again.
+func (b *BuildConfig) Tag() error {
+	for _, tag := range b.Tags {
+		var img image.Image
+		var err error
+		if b.Publish {
+			img, err = b.ImageFactory.NewRemote(b.RepoName)
+		} else {
+			img, err = b.ImageFactory.NewLocal(b.RepoName, false)
+		}
 		if err != nil {
 			return err
 		}
+		img.Rename(tag)
+		if _, err := img.Save(); err != nil {
+			return &phaseError{error: errors.Wrapf(err, "tagging image %s", style.Symbol(tag)), phase: "exporter"}
+		}
+		b.Logger.Verbose("Tagged image %s as %s", style.Symbol(b.RepoName), style.Symbol(tag))
+	}
+	return nil
+}
 
-		ctrConf.Env = []string{fmt.Sprintf(`PACK_REGISTRY_AUTH=%s`, authHeader)}
-		ctrConf.Cmd = []string{
-			"/lifecycle/exporter",
-			"-image", b.RunImage,
-			"-layers", launchDir,
-			"-group", groupPath,
-			b.RepoName,
+// LoadPublished pulls the published image and its tags into the daemon, so they can be run without a second build
+func (b *BuildConfig) LoadPublished() error {
+	for _, name := range append([]string{b.RepoName}, b.Tags...) {
+		if err := pullImage(b.context(), b.Cli, name, b.Platform); err != nil {
+			return errors.Wrapf(err, "loading image %s into the daemon", style.Symbol(name))
 		}
-		hostConfig.NetworkMode = "host"
-	} else {
-		ctrConf.Cmd = []string{
-			"/lifecycle/exporter",
-			"-image", b.RunImage,
-			"-layers", launchDir,
-			"-group", groupPath,
-			"-daemon",
-			b.RepoName,
+		b.Logger.Verbose("Loaded image %s into the daemon", style.Symbol(name))
+	}
+	return nil
+}
+
+// runPhase runs a lifecycle phase container, streaming its output to the logger.
+// The tail of the output is kept with any failure so transient errors can be recognized.
+func (b *BuildConfig) runPhase(ctx context.Context, ctrID, phase string) error {
+	tail := &tailWriter{max: phaseOutputTailSize}
+	stdout := b.Logger.VerboseWriter().WithPrefix(phase)
+	stderr := b.Logger.VerboseErrorWriter().WithPrefix(phase)
+	defer stdout.Flush()
+	defer stderr.Flush()
+	if err := b.Cli.RunContainer(
+		ctx,
+		ctrID,
+		io.MultiWriter(stdout, tail),
+		io.MultiWriter(stderr, tail),
+	); err != nil {
+		if b.Debug && ctx.Err() == nil {
+			if debugErr := b.debugShell(ctx, ctrID, phase); debugErr != nil {
+				b.Logger.Error("Debug shell for %s failed: %s", phase, debugErr)
+			}
 		}
-		ctrConf.User = "root"
-		hostConfig.Binds = append(hostConfig.Binds, "/var/run/docker.sock:/var/run/docker.sock")
+		return &phaseError{error: err, phase: phase, output: tail.String()}
 	}
+	return nil
+}
 
-	ctr, err := b.Cli.ContainerCreate(ctx, ctrConf, hostConfig, nil, "")
+// debugShell starts an interactive shell in a copy of a failed phase container,
+// with the same environment, user and volumes, including the workspace as the phase left it
+func (b *BuildConfig) debugShell(ctx context.Context, ctrID, phase string) error {
+	failed, err := b.Cli.ContainerInspect(ctx, ctrID)
 	if err != nil {
-		return errors.Wrap(err, "create export container")
+		return err
+	}
+	if failed.ContainerJSONBase == nil || failed.Config == nil || failed.HostConfig == nil {
+		return fmt.Errorf("incomplete details for container %s", style.Symbol(ctrID))
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
 
-	uid, gid, err := b.packUidGid(b.Builder)
+	snapshot, err := b.Cli.ContainerCommit(ctx, ctrID, dockertypes.ContainerCommitOptions{})
 	if err != nil {
-		return errors.Wrap(err, "get pack uid and gid")
+		return errors.Wrap(err, "snapshot failed container")
+	}
+	defer b.Cli.ImageRemove(ctx, snapshot.ID, dockertypes.ImageRemoveOptions{Force: true, PruneChildren: true})
+
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image:        snapshot.ID,
+		Env:          failed.Config.Env,
+		User:         failed.Config.User,
+		WorkingDir:   failed.Config.WorkingDir,
+		Entrypoint:   []string{"/bin/sh"},
+		Tty:          true,
+		OpenStdin:    true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	}, &container.HostConfig{
+		Binds:       failed.HostConfig.Binds,
+		NetworkMode: failed.HostConfig.NetworkMode,
+	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create debug container")
 	}
-	if err := b.chownDir(launchDir, uid, gid); err != nil {
-		return errors.Wrap(err, "chown launch dir")
+	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{Force: true})
+
+	b.Logger.Info("Phase %s failed, opening a shell in its container (exit the shell to continue)", style.Symbol(phase))
+	b.Logger.Tip("The workspace is at %s and the phase command was %s", style.Symbol(launchDir), style.Symbol(strings.Join(failed.Config.Cmd, " ")))
+	if err := b.Cli.RunInteractive(ctx, ctr.ID, os.Stdin, os.Stdout); err != nil {
+		b.Logger.Verbose("Debug shell exited: %s", err)
 	}
+	return nil
+}
 
-	if err := b.Cli.RunContainer(
-		ctx,
-		ctr.ID,
-		b.Logger.VerboseWriter().WithPrefix("exporter"),
-		b.Logger.VerboseErrorWriter().WithPrefix("exporter"),
-	); err != nil {
-		return errors.Wrap(err, "run lifecycle/exporter")
+// phaseBinds returns the volume binds shared by all lifecycle phase containers
+func (b *BuildConfig) phaseBinds() []string {
+	binds := cacheBinds(b.CacheVolume)
+	if b.lifecycleVolume != "" {
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", b.lifecycleVolume, lifecycleDir))
+	}
+	return binds
+}
+
+// buildpackBinds returns the binds of the phase containers that run buildpacks, which include the --volume
+// and --binding mounts
+func (b *BuildConfig) buildpackBinds() []string {
+	binds := append(b.phaseBinds(), b.Volumes...)
+	return append(binds, b.Bindings...)
+}
+
+// parseLabels parses labels of the form key=value. Labels in the io.buildpacks namespace are reserved for
+// the metadata recorded by the lifecycle and pack.
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	parsed := map[string]string{}
+	for _, l := range labels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %s: must be of the form 'key=value'", style.Symbol(l))
+		}
+		if strings.HasPrefix(kv[0], "io.buildpacks.") {
+			return nil, fmt.Errorf("invalid label %s: the %s namespace is reserved", style.Symbol(l), style.Symbol("io.buildpacks"))
+		}
+		parsed[kv[0]] = kv[1]
+	}
+	return parsed, nil
+}
+
+// parseVolumes converts --volume mounts of the form 'host:container[:ro|rw]' into binds
+func parseVolumes(volumes []string) ([]string, error) {
+	var binds []string
+	for _, v := range volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid volume %s: must be of the form 'host-path:container-path[:ro|rw]'", style.Symbol(v))
+		}
+		hostPath, ctrPath, mode := parts[0], parts[1], "rw"
+		if len(parts) == 3 {
+			mode = parts[2]
+		}
+		if mode != "ro" && mode != "rw" {
+			return nil, fmt.Errorf("invalid volume %s: mode must be 'ro' or 'rw'", style.Symbol(v))
+		}
+		if !path.IsAbs(ctrPath) {
+			return nil, fmt.Errorf("invalid volume %s: container path must be absolute", style.Symbol(v))
+		}
+		for _, reserved := range []string{launchDir, lifecycleDir, buildpacksDir} {
+			if ctrPath == reserved || strings.HasPrefix(ctrPath, reserved+"/") {
+				return nil, fmt.Errorf("invalid volume %s: cannot mount over %s", style.Symbol(v), style.Symbol(reserved))
+			}
+		}
+		hostPath, err := filepath.Abs(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, fmt.Sprintf("%s:%s:%s", hostPath, path.Clean(ctrPath), mode))
+	}
+	return binds, nil
+}
+
+// prepareLifecycleVolume populates a volume with the /lifecycle directory of the
+// lifecycle image so it can be mounted over the binaries baked into the builder.
+// Docker copies image content into an empty named volume when a container is created,
+// so no container needs to be started. The volume is keyed by image ID so updated
+// lifecycle images are never shadowed by a stale volume.
+func (b *BuildConfig) prepareLifecycleVolume(ctx context.Context) error {
+	if b.lifecycleVolume != "" {
+		return nil
+	}
+	if b.lifecycleBins != "" {
+		return b.prepareDownloadedLifecycleVolume(ctx)
+	}
+	if b.LifecycleImage == "" {
+		return nil
+	}
+	i, _, err := b.Cli.ImageInspectWithRaw(ctx, b.LifecycleImage)
+	if err != nil {
+		return errors.Wrapf(err, "inspecting lifecycle image %s", style.Symbol(b.LifecycleImage))
+	}
+	volume := fmt.Sprintf("pack-lifecycle-%x", md5.Sum([]byte(i.ID)))
+
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image: b.LifecycleImage,
+		Cmd:   []string{lifecycleDir + "/detector"},
+	}, &container.HostConfig{
+		Binds: []string{
+			fmt.Sprintf("%s:%s:", volume, lifecycleDir),
+		},
+	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create lifecycle container")
+	}
+	if err := b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{}); err != nil {
+		return errors.Wrap(err, "remove lifecycle container")
+	}
+
+	b.lifecycleVolume = volume
+	b.Logger.Verbose("Using lifecycle volume %s", style.Symbol(b.lifecycleVolume))
+	return nil
+}
+
+// validateStackOS checks that the builder and, when it is on the daemon, the run image are images of the
+// same OS, and of the build's platform when there is one, and that the run image has the builder's
+// mixins. Windows images are rejected: the lifecycle only runs in Linux containers so far.
+func (b *BuildConfig) validateStackOS(ctx context.Context) error {
+	builder, _, err := b.Cli.ImageInspectWithRaw(ctx, b.Builder)
+	if err != nil {
+		return errors.Wrapf(err, "inspecting builder %s", style.Symbol(b.Builder))
+	}
+	if b.Platform != "" {
+		if err := validatePlatformImage(builder, "builder", b.Builder, b.Platform); err != nil {
+			return err
+		}
+	}
+	if !b.Publish {
+		if run, _, err := b.Cli.ImageInspectWithRaw(ctx, b.RunImage); err == nil {
+			if run.Os != builder.Os {
+				return fmt.Errorf("invalid stack: run image %s is a %s image, but builder %s is a %s image", style.Symbol(b.RunImage), run.Os, style.Symbol(b.Builder), builder.Os)
+			}
+			if b.Platform != "" {
+				if err := validatePlatformImage(run, "run", b.RunImage, b.Platform); err != nil {
+					return err
+				}
+			}
+			if err := b.validateMixins(builder, run); err != nil {
+				return err
+			}
+		}
+	}
+	if builder.Os == "windows" {
+		return fmt.Errorf("builder %s is a windows image: the lifecycle does not run in Windows containers yet", style.Symbol(b.Builder))
 	}
 	return nil
 }
@@ -671,15 +2015,23 @@ func (b *BuildConfig) packUidGid(builder string) (int, int, error) {
 	if err != nil {
 		return 0, 0, errors.Wrapf(err, "parsing pack gid: %s", sGID)
 	}
+	if b.GID != nil {
+		gid = *b.GID
+	}
 	return uid, gid, nil
 }
 
-func (b *BuildConfig) chownDir(path string, uid, gid int) error {
-	ctx := context.Background()
+// runInWorkspace runs a command as the builder's user in a container with the workspace volume mounted
+func (b *BuildConfig) runInWorkspace(cmd ...string) error {
+	ctx := b.context()
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
 		Image: b.Builder,
-		Cmd:   []string{"chown", "-R", fmt.Sprintf("%d:%d", uid, gid), path},
-		User:  "root",
+		Cmd:   cmd,
+		User:  fmt.Sprintf("%d:%d", uid, gid),
 	}, &container.HostConfig{
 		Binds: []string{
 			fmt.Sprintf("%s:%s:", b.CacheVolume, launchDir),
@@ -688,7 +2040,7 @@ func (b *BuildConfig) chownDir(path string, uid, gid int) error {
 	if err != nil {
 		return err
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 	if err := b.Cli.RunContainer(ctx, ctr.ID, b.Logger.VerboseWriter(), b.Logger.VerboseErrorWriter()); err != nil {
 		return err
 	}
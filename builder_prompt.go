@@ -0,0 +1,60 @@
+package pack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+func noBuilderError() error {
+	return fmt.Errorf("no builder is configured: use %s or run %s to set a default builder", style.Symbol("--builder"), style.Symbol("pack set-default-builder <builder>"))
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptBuilder returns a BuildFactory.SelectBuilder that asks for one of the suggested builders, or any
+// other builder image, on in
+func promptBuilder(in io.Reader, logger *logging.Logger) func(suggestions []string) (string, error) {
+	r := bufio.NewReader(in)
+	return func(suggestions []string) (string, error) {
+		logger.Info("No builder is configured, choose one of:")
+		for i, builder := range suggestions {
+			logger.Info("  %d) %s", i+1, style.Symbol(builder))
+		}
+		for {
+			logger.Info("Enter a number or another builder image [1]:")
+			line, err := r.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return "", errors.Wrap(err, "reading builder")
+			}
+			choice := strings.TrimSpace(line)
+			if choice == "" {
+				choice = "1"
+			}
+			if n, err := strconv.Atoi(choice); err == nil {
+				if n < 1 || n > len(suggestions) {
+					logger.Warn("%d is not one of the builders", n)
+					continue
+				}
+				choice = suggestions[n-1]
+			} else if err := validateImageReference("builder", choice); err != nil {
+				logger.Warn("%s", err)
+				continue
+			}
+			logger.Tip("Run %s to skip this question next time", style.Symbol("pack set-default-builder "+choice))
+			return choice, nil
+		}
+	}
+}
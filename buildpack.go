@@ -1,7 +1,19 @@
 package pack
 
 import (
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/logging"
 )
 
 type Buildpack struct {
@@ -15,3 +27,138 @@ type Buildpack struct {
 func (b *Buildpack) escapedID() string {
 	return strings.Replace(b.ID, "/", "_", -1)
 }
+
+// isBuildpackURL reports whether a buildpack reference is an http(s) URL to a .tgz buildpack
+func isBuildpackURL(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
+
+// isBuildpackArchive reports whether a buildpack reference is a path to a .tgz or .tar buildpack
+func isBuildpackArchive(ref string) bool {
+	for _, ext := range []string{".tgz", ".tar.gz", ".tar"} {
+		if strings.HasSuffix(ref, ext) {
+			if fi, err := os.Stat(ref); err == nil && !fi.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// extractBuildpackArchive extracts the .tgz or .tar buildpack at path into a temporary directory,
+// returned with a function removing it
+func extractBuildpackArchive(fs FS, path string) (string, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	r, err := decompressed(file)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "decompressing buildpack %q", path)
+	}
+	tmpDir, err := ioutil.TempDir("", "pack.buildpack.")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+	if err := fs.Untar(r, tmpDir); err != nil {
+		cleanup()
+		return "", nil, errors.Wrapf(err, "extracting buildpack %q", path)
+	}
+	return tmpDir, cleanup, nil
+}
+
+// buildpackRoot returns the directory holding the buildpack.toml of an extracted buildpack: dir itself,
+// or its only subdirectory for archives with a top-level directory
+func buildpackRoot(dir, source string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "buildpack.toml")); err == nil {
+		return dir, nil
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		sub := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, "buildpack.toml")); err == nil {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("buildpack %q has no buildpack.toml", source)
+}
+
+// downloadAndExtract extracts the .tgz at uri, such as a buildpack, into a directory of cacheDir named after
+// the uri's digest, and returns that directory. The server's ETag is kept next to it so the download is
+// skipped while the cached copy is current.
+func downloadAndExtract(logger *logging.Logger, fs FS, cacheDir, uri string) (string, error) {
+	uriDigest := fmt.Sprintf("%x", sha256.Sum256([]byte(uri)))
+	cachedDir := filepath.Join(cacheDir, uriDigest)
+	etagFile := cachedDir + ".etag"
+	etag := ""
+	if _, err := os.Stat(cachedDir); err == nil {
+		if bytes, err := ioutil.ReadFile(etagFile); err == nil {
+			etag = string(bytes)
+		}
+	}
+
+	reader, etag, err := downloadAsStream(logger, uri, etag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download from %q", uri)
+	}
+	if reader == nil {
+		// can use cached content
+		return cachedDir, nil
+	}
+	defer reader.Close()
+
+	// a download interrupted earlier may have left files behind
+	os.Remove(etagFile)
+	if err := os.RemoveAll(cachedDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(cachedDir, 0744); err != nil {
+		return "", err
+	}
+	if err := untarZ(fs, reader, cachedDir); err != nil {
+		return "", errors.Wrapf(err, "extracting %q", uri)
+	}
+	if err := ioutil.WriteFile(etagFile, []byte(etag), 0744); err != nil {
+		return "", err
+	}
+	return cachedDir, nil
+}
+
+func downloadAsStream(logger *logging.Logger, uri string, etag string) (io.ReadCloser, string, error) {
+	c := http.Client{}
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		logger.Verbose("Downloading from %q\n", uri)
+		return resp.Body, resp.Header.Get("Etag"), nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == 304 {
+		logger.Verbose("Using cached version of %q\n", uri)
+		return nil, etag, nil
+	}
+	return nil, "", fmt.Errorf("could not download from %q, code http status %d", uri, resp.StatusCode)
+}
+
+func untarZ(fs FS, r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrapf(err, "could not unzip")
+	}
+	defer gzr.Close()
+	return fs.Untar(gzr, dir)
+}
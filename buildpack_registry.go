@@ -0,0 +1,197 @@
+package pack
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// DefaultBuildpackRegistry is the index of the Buildpack Registry, used unless 'buildpack-registry' is set in config.toml
+const DefaultBuildpackRegistry = "https://raw.githubusercontent.com/buildpacks/registry-index/main"
+
+// buildpackageDir is where buildpackage images keep their buildpacks
+const buildpackageDir = "/cnb/buildpacks"
+
+// RegistryBuildpack is a version of a buildpack published to a buildpack registry, with the address of its buildpackage
+type RegistryBuildpack struct {
+	Namespace string `json:"ns"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Yanked    bool   `json:"yanked"`
+	Address   string `json:"addr"`
+}
+
+// LookupBuildpack finds a version of the buildpack id, of the form namespace/name, in the registry index at
+// indexURL. For "latest", the most recently published version that was not yanked is returned.
+func LookupBuildpack(indexURL, id, version string) (*RegistryBuildpack, error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("buildpack %s must be of the form namespace/name to be looked up in a registry", style.Symbol(id))
+	}
+	uri := strings.TrimSuffix(indexURL, "/") + "/" + registryIndexPath(parts[0], parts[1])
+	resp, err := http.Get(uri)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading buildpack registry index %q", uri)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("buildpack %s was not found in the buildpack registry", style.Symbol(id))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("could not read buildpack registry index %q, code http status %d", uri, resp.StatusCode)
+	}
+
+	var found *RegistryBuildpack
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry RegistryBuildpack
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, errors.Wrapf(err, "parsing buildpack registry index %q", uri)
+		}
+		if entry.Namespace != parts[0] || entry.Name != parts[1] || entry.Yanked {
+			continue
+		}
+		if version == "latest" || entry.Version == version {
+			found = &entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "reading buildpack registry index %q", uri)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("version %s of buildpack %s was not found in the buildpack registry", style.Symbol(version), style.Symbol(id))
+	}
+	return found, nil
+}
+
+// registryIndexPath is the path of the index file of a buildpack. Indexes shard files by the length and
+// leading characters of the buildpack's name.
+func registryIndexPath(ns, name string) string {
+	file := ns + "_" + name
+	switch len(name) {
+	case 1, 2:
+		return path.Join(fmt.Sprint(len(name)), file)
+	case 3:
+		return path.Join("3", name[:1], file)
+	default:
+		return path.Join(name[:2], name[2:4], file)
+	}
+}
+
+func (b *BuildConfig) buildpackRegistry() string {
+	if b.Config != nil && b.Config.BuildpackRegistry != "" {
+		return b.Config.BuildpackRegistry
+	}
+	return DefaultBuildpackRegistry
+}
+
+// builderHasBuildpack reports whether the buildpacks dir of the container holds a version of the buildpack id
+func (b *BuildConfig) builderHasBuildpack(ctx context.Context, ctrID, id, version string) bool {
+	bp := Buildpack{ID: id}
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, path.Join(buildpacksDir, bp.escapedID(), version))
+	if err != nil {
+		return false
+	}
+	rc.Close()
+	return true
+}
+
+// copyRegistryBuildpack copies the buildpacks of the buildpackage published to the buildpack registry for a
+// version of the buildpack id to the buildpacks dir of the container, and returns the version copied
+func (b *BuildConfig) copyRegistryBuildpack(ctx context.Context, ctrID, id, version string) (string, error) {
+	entry, err := LookupBuildpack(b.buildpackRegistry(), id, version)
+	if err != nil {
+		return "", err
+	}
+	ref, err := name.ParseReference(entry.Address, name.WeakValidation)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing address of buildpack %s", style.Symbol(id+"@"+entry.Version))
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", errors.Wrapf(err, "fetching buildpackage %s", style.Symbol(entry.Address))
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return "", errors.Wrapf(err, "reading buildpackage %s", style.Symbol(entry.Address))
+	}
+	for _, layer := range layers {
+		open := func() (io.ReadCloser, error) {
+			rc, err := layer.Uncompressed()
+			if err != nil {
+				return nil, err
+			}
+			return buildpackageLayer(rc), nil
+		}
+		if err := copyLayerToContainer(ctx, b.Cli, ctrID, open); err != nil {
+			return "", errors.Wrapf(err, "copying buildpackage %s to container", style.Symbol(entry.Address))
+		}
+	}
+	b.Logger.Verbose("Using buildpack %s from %s", style.Symbol(id+"@"+entry.Version), style.Symbol(entry.Address))
+	return entry.Version, nil
+}
+
+// buildpackageLayer streams the entries of a buildpackage layer beneath its buildpackageDir, moved to buildpacksDir
+func buildpackageLayer(rc io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer rc.Close()
+		tr := tar.NewReader(rc)
+		tw := tar.NewWriter(pw)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				pw.CloseWithError(tw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			name, ok := relocateBuildpackagePath(hdr.Name)
+			if !ok {
+				continue
+			}
+			hdr.Name = name
+			if hdr.Linkname != "" {
+				if linkname, ok := relocateBuildpackagePath(hdr.Linkname); ok {
+					hdr.Linkname = linkname
+				}
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+func relocateBuildpackagePath(p string) (string, bool) {
+	clean := path.Clean("/" + p)
+	if clean != buildpackageDir && !strings.HasPrefix(clean, buildpackageDir+"/") {
+		return "", false
+	}
+	return buildpacksDir + strings.TrimPrefix(clean, buildpackageDir), true
+}
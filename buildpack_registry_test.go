@@ -0,0 +1,64 @@
+package pack_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBuildpackRegistry(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "buildpack-registry", testBuildpackRegistry, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuildpackRegistry(t *testing.T, when spec.G, it spec.S) {
+	var server *httptest.Server
+
+	it.Before(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/ja/va/some-ns_java" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(`{"ns":"some-ns","name":"java","version":"1.2.3","yanked":false,"addr":"registry.com/java@sha256:123"}
+{"ns":"some-ns","name":"java","version":"1.3.0","yanked":false,"addr":"registry.com/java@sha256:130"}
+{"ns":"some-ns","name":"java","version":"1.4.0","yanked":true,"addr":"registry.com/java@sha256:140"}
+`))
+		}))
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	when("#LookupBuildpack", func() {
+		it("returns the address of the requested version", func() {
+			bp, err := pack.LookupBuildpack(server.URL, "some-ns/java", "1.2.3")
+			h.AssertNil(t, err)
+			h.AssertEq(t, bp.Address, "registry.com/java@sha256:123")
+		})
+
+		it("returns the latest version that was not yanked", func() {
+			bp, err := pack.LookupBuildpack(server.URL, "some-ns/java", "latest")
+			h.AssertNil(t, err)
+			h.AssertEq(t, bp.Version, "1.3.0")
+		})
+
+		it("returns an error for yanked versions", func() {
+			_, err := pack.LookupBuildpack(server.URL, "some-ns/java", "1.4.0")
+			h.AssertError(t, err, "version '1.4.0' of buildpack 'some-ns/java' was not found in the buildpack registry")
+		})
+
+		it("returns an error for buildpacks missing from the index", func() {
+			_, err := pack.LookupBuildpack(server.URL, "some-ns/ruby", "latest")
+			h.AssertError(t, err, "buildpack 'some-ns/ruby' was not found in the buildpack registry")
+		})
+	})
+}
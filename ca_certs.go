@@ -0,0 +1,103 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	// stackCACertsPath is the trust store of the bionic stack
+	stackCACertsPath = "/etc/ssl/certs/ca-certificates.crt"
+	// caCertsPath is the trust store of lifecycle containers given CA certificates: the builder's with the
+	// certificates appended. It is outside the root filesystem's /etc, which sandboxed containers can't write.
+	caCertsPath = "/platform/ca-certificates.crt"
+)
+
+// readCACerts reads the PEM encoded CA certificates in the files at paths into a single bundle
+func readCACerts(paths []string) ([]byte, error) {
+	var bundle bytes.Buffer
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading CA certificate %s", style.Symbol(path))
+		}
+		found := false
+		for rest := contents; ; {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, errors.Wrapf(err, "parsing CA certificate %s", style.Symbol(path))
+			}
+			if err := pem.Encode(&bundle, block); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("CA certificate file %s contains no PEM encoded certificate", style.Symbol(path))
+		}
+	}
+	return bundle.Bytes(), nil
+}
+
+// caCertsEnv points the tools of buildpacks and the lifecycle at the trust store written by copyCACerts.
+// OpenSSL and Go read SSL_CERT_FILE, Node.js only adds NODE_EXTRA_CA_CERTS to its own certificates.
+func (b *BuildConfig) caCertsEnv() []string {
+	if len(b.CACerts) == 0 {
+		return nil
+	}
+	return []string{"SSL_CERT_FILE=" + caCertsPath, "NODE_EXTRA_CA_CERTS=" + caCertsPath}
+}
+
+// copyCACerts writes the trust store of the container, with the CA certificates of the build, to caCertsPath
+func (b *BuildConfig) copyCACerts(ctx context.Context, ctrID string) error {
+	if len(b.CACerts) == 0 {
+		return nil
+	}
+	bundle := append(b.stackCACerts(ctx, ctrID), b.CACerts...)
+	tr, err := b.FS.CreateSingleFileTar(caCertsPath, string(bundle))
+	if err != nil {
+		return err
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrap(err, "copying CA certificates to container")
+	}
+	return nil
+}
+
+// stackCACerts returns the trust store of the container's image, which is empty when it has none
+func (b *BuildConfig) stackCACerts(ctx context.Context, ctrID string) []byte {
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, stackCACertsPath)
+	if err != nil {
+		b.Logger.Verbose("Builder has no trust store at %s, only trusting the given CA certificates", style.Symbol(stackCACertsPath))
+		return nil
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		return nil
+	}
+	certs, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil
+	}
+	if len(certs) > 0 && certs[len(certs)-1] != '\n' {
+		certs = append(certs, '\n')
+	}
+	return certs
+}
@@ -15,4 +15,22 @@ func CacheVolume(repoName string) (string, error) {
 	}
 	cacheVolume := fmt.Sprintf("pack-cache-%x", md5.Sum([]byte(ref.String())))
 	return cacheVolume, nil
+}
+
+const layersCacheVolumeSuffix = "-layers"
+
+// LayersCacheVolume returns the volume holding the layers buildpacks cache for builds using cacheVolume, which
+// holds the workspace with the app and the launch layers of the previous build. Keeping them apart lets
+// either be cleared without the other.
+func LayersCacheVolume(cacheVolume string) string {
+	return cacheVolume + layersCacheVolumeSuffix
+}
+
+// cacheBinds mounts cacheVolume at the workspace and its layers cache volume within it, where the restorer
+// and cacher expect the cached layers
+func cacheBinds(cacheVolume string) []string {
+	return []string{
+		fmt.Sprintf("%s:%s:", cacheVolume, launchDir),
+		fmt.Sprintf("%s:%s:", LayersCacheVolume(cacheVolume), layersCacheDir),
+	}
 }
\ No newline at end of file
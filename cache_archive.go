@@ -0,0 +1,103 @@
+package pack
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+// CacheArchiver moves the contents of an image's cache volume, with its layers cache volume, in and out of
+// gzipped tarballs, so caches can be restored on hosts without persistent volumes.
+type CacheArchiver struct {
+	Cli    Docker
+	Logger *logging.Logger
+	Config *config.Config
+}
+
+type CacheArchiveFlags struct {
+	RepoName string
+	Builder  string
+}
+
+// Export writes the cache volume for flags.RepoName to w as a gzipped tarball
+func (c *CacheArchiver) Export(flags CacheArchiveFlags, w io.Writer) error {
+	ctx := context.Background()
+	volume, ctrID, err := c.createVolumeContainer(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer c.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	rc, _, err := c.Cli.CopyFromContainer(ctx, ctrID, launchDir)
+	if err != nil {
+		return errors.Wrapf(err, "reading cache volume %s", style.Symbol(volume))
+	}
+	defer rc.Close()
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, rc); err != nil {
+		return errors.Wrap(err, "writing cache archive")
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "writing cache archive")
+	}
+	return nil
+}
+
+// Import replaces the cache volume for flags.RepoName with the gzipped tarball read from r
+func (c *CacheArchiver) Import(flags CacheArchiveFlags, r io.Reader) error {
+	ctx := context.Background()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "reading cache archive")
+	}
+	defer gz.Close()
+
+	volume, err := CacheVolume(flags.RepoName)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{volume, LayersCacheVolume(volume)} {
+		if err := c.Cli.VolumeRemove(ctx, name, true); err != nil {
+			return errors.Wrap(err, "clearing cache")
+		}
+	}
+
+	_, ctrID, err := c.createVolumeContainer(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer c.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	if err := c.Cli.CopyToContainer(ctx, ctrID, "/", gz, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrapf(err, "restoring cache volume %s", style.Symbol(volume))
+	}
+	c.Logger.Verbose("Imported cache volume %s", style.Symbol(volume))
+	return nil
+}
+
+func (c *CacheArchiver) createVolumeContainer(ctx context.Context, flags CacheArchiveFlags) (string, string, error) {
+	volume, err := CacheVolume(flags.RepoName)
+	if err != nil {
+		return "", "", err
+	}
+	builder := flags.Builder
+	if builder == "" {
+		builder = c.Config.DefaultBuilder
+	}
+	if builder == "" {
+		return "", "", noBuilderError()
+	}
+	ctrID, err := createVolumesContainer(ctx, c.Cli, builder, cacheBinds(volume))
+	if err != nil {
+		return "", "", err
+	}
+	return volume, ctrID, nil
+}
@@ -0,0 +1,113 @@
+package pack_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestCacheArchive(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "cache-archive", testCacheArchive, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testCacheArchive(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject        pack.CacheArchiver
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		outBuf         bytes.Buffer
+		errBuf         bytes.Buffer
+		volume         string
+		ctr            container.ContainerCreateCreatedBody
+	)
+
+	it.Before(func() {
+		var err error
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		subject = pack.CacheArchiver{
+			Cli:    mockDocker,
+			Logger: logging.NewLogger(&outBuf, &errBuf, true, false),
+			Config: &config.Config{DefaultBuilder: "some/builder"},
+		}
+		volume, err = pack.CacheVolume("some/app")
+		h.AssertNil(t, err)
+		ctr = container.ContainerCreateCreatedBody{ID: "some-container-id"}
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	expectVolumeContainer := func() {
+		mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+			Image: "some/builder",
+			Cmd:   []string{"true"},
+		}, &container.HostConfig{
+			Binds: []string{volume + ":/workspace:", volume + "-layers:/workspace/.cache:"},
+		}, nil, "").Return(ctr, nil)
+		mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, dockertypes.ContainerRemoveOptions{}).Return(nil)
+	}
+
+	when("#Export", func() {
+		it("writes the cache volume contents as a gzipped tarball", func() {
+			expectVolumeContainer()
+			mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace").
+				Return(ioutil.NopCloser(bytes.NewBufferString("some-tar-contents")), dockertypes.ContainerPathStat{}, nil)
+
+			var archive bytes.Buffer
+			h.AssertNil(t, subject.Export(pack.CacheArchiveFlags{RepoName: "some/app"}, &archive))
+
+			gz, err := gzip.NewReader(&archive)
+			h.AssertNil(t, err)
+			contents, err := ioutil.ReadAll(gz)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "some-tar-contents")
+		})
+	})
+
+	when("#Import", func() {
+		it("replaces the cache volume with the tarball contents", func() {
+			var archive bytes.Buffer
+			gz := gzip.NewWriter(&archive)
+			_, err := gz.Write([]byte("some-tar-contents"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, gz.Close())
+
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), volume, true).Return(nil)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), volume+"-layers", true).Return(nil)
+			expectVolumeContainer()
+			mockDocker.EXPECT().CopyToContainer(gomock.Any(), ctr.ID, "/", gomock.Any(), dockertypes.CopyToContainerOptions{}).
+				DoAndReturn(func(_ context.Context, _, _ string, r io.Reader, _ dockertypes.CopyToContainerOptions) error {
+					contents, err := ioutil.ReadAll(r)
+					h.AssertNil(t, err)
+					h.AssertEq(t, string(contents), "some-tar-contents")
+					return nil
+				})
+
+			h.AssertNil(t, subject.Import(pack.CacheArchiveFlags{RepoName: "some/app"}, &archive))
+		})
+
+		it("fails when the input is not gzipped", func() {
+			err := subject.Import(pack.CacheArchiveFlags{RepoName: "some/app"}, bytes.NewBufferString("not gzip"))
+			h.AssertContains(t, err.Error(), "reading cache archive")
+		})
+	})
+}
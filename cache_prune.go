@@ -0,0 +1,122 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	dockercli "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+// CacheImageLabel is set on cache volumes created by pack to the name of the image they cache
+const CacheImageLabel = "io.buildpacks.pack.cache.image"
+
+const (
+	cacheVolumePrefix = "pack-cache-"
+	DefaultCacheTTL   = 30 * 24 * time.Hour
+)
+
+type CachePruner struct {
+	Cli    Docker
+	Logger *logging.Logger
+	Usage  *config.CacheUsage
+}
+
+type StaleCache struct {
+	Volume   string
+	Image    string
+	LastUsed time.Time
+}
+
+// ParseCacheTTL parses durations such as "30d" or "12h"
+func ParseCacheTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultCacheTTL, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if ttl, err := time.ParseDuration(s); err == nil && ttl > 0 {
+		return ttl, nil
+	}
+	return 0, fmt.Errorf("invalid cache TTL %s: expected a positive number of days (e.g. '30d') or a duration (e.g. '12h')", style.Symbol(s))
+}
+
+// Stale lists cache volumes not used by a build within ttl, oldest first.
+// Volumes used before their use was tracked fall back to their creation time.
+func (p *CachePruner) Stale(ttl time.Duration, now time.Time) ([]StaleCache, error) {
+	list, err := p.Cli.VolumeList(context.Background(), filters.NewArgs(filters.Arg("name", cacheVolumePrefix)))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing cache volumes")
+	}
+
+	var stale []StaleCache
+	for _, v := range list.Volumes {
+		// layers cache volumes are removed with the cache volume they belong to
+		if !strings.HasPrefix(v.Name, cacheVolumePrefix) || strings.HasSuffix(v.Name, layersCacheVolumeSuffix) {
+			continue
+		}
+		lastUsed, ok := p.Usage.LastUsed[v.Name]
+		if !ok {
+			lastUsed, err = time.Parse(time.RFC3339, v.CreatedAt)
+			if err != nil {
+				p.Logger.Verbose("Skipping cache volume %s with unknown age", style.Symbol(v.Name))
+				continue
+			}
+		}
+		if now.Sub(lastUsed) > ttl {
+			stale = append(stale, StaleCache{Volume: v.Name, Image: v.Labels[CacheImageLabel], LastUsed: lastUsed})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].LastUsed.Before(stale[j].LastUsed) })
+	return stale, nil
+}
+
+// Prune removes cache volumes not used by a build within ttl
+func (p *CachePruner) Prune(ttl time.Duration, dryRun bool) error {
+	now := time.Now()
+	stale, err := p.Stale(ttl, now)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		p.Logger.Info("No cache volumes unused for more than %s", ttl)
+		return nil
+	}
+
+	ctx := context.Background()
+	for _, c := range stale {
+		desc := style.Symbol(c.Volume)
+		if c.Image != "" {
+			desc = fmt.Sprintf("%s (%s)", style.Symbol(c.Volume), c.Image)
+		}
+		age := now.Sub(c.LastUsed).Truncate(time.Hour)
+		if dryRun {
+			p.Logger.Info("Would remove cache volume %s, last used %s ago", desc, age)
+			continue
+		}
+		if err := p.Cli.VolumeRemove(ctx, c.Volume, false); err != nil {
+			return errors.Wrapf(err, "removing cache volume %s", style.Symbol(c.Volume))
+		}
+		// caches of builds before the layers cache was split out have no layers cache volume
+		if err := p.Cli.VolumeRemove(ctx, LayersCacheVolume(c.Volume), false); err != nil && !dockercli.IsErrNotFound(err) {
+			return errors.Wrapf(err, "removing cache volume %s", style.Symbol(LayersCacheVolume(c.Volume)))
+		}
+		if err := p.Usage.Forget(c.Volume); err != nil {
+			return err
+		}
+		p.Logger.Info("Removed cache volume %s, last used %s ago", desc, age)
+	}
+	return nil
+}
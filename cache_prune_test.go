@@ -0,0 +1,119 @@
+package pack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestCachePrune(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "cache-prune", testCachePrune, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testCachePrune(t *testing.T, when spec.G, it spec.S) {
+	when("#ParseCacheTTL", func() {
+		it("defaults to 30 days", func() {
+			ttl, err := pack.ParseCacheTTL("")
+			h.AssertNil(t, err)
+			h.AssertEq(t, ttl, 30*24*time.Hour)
+		})
+
+		it("parses days and durations", func() {
+			ttl, err := pack.ParseCacheTTL("7d")
+			h.AssertNil(t, err)
+			h.AssertEq(t, ttl, 7*24*time.Hour)
+
+			ttl, err = pack.ParseCacheTTL("12h")
+			h.AssertNil(t, err)
+			h.AssertEq(t, ttl, 12*time.Hour)
+		})
+
+		it("rejects invalid values", func() {
+			_, err := pack.ParseCacheTTL("-1d")
+			h.AssertError(t, err, "invalid cache TTL '-1d': expected a positive number of days (e.g. '30d') or a duration (e.g. '12h')")
+		})
+	})
+
+	when("#Prune", func() {
+		var (
+			subject        pack.CachePruner
+			mockController *gomock.Controller
+			mockDocker     *mocks.MockDocker
+			outBuf         bytes.Buffer
+			errBuf         bytes.Buffer
+			packHome       string
+		)
+
+		it.Before(func() {
+			var err error
+			mockController = gomock.NewController(t)
+			mockDocker = mocks.NewMockDocker(mockController)
+			packHome, err = ioutil.TempDir("", "pack-home")
+			h.AssertNil(t, err)
+			usage, err := config.NewCacheUsage(packHome)
+			h.AssertNil(t, err)
+			h.AssertNil(t, usage.Touch("pack-cache-recent", time.Now().Add(-time.Hour)))
+			h.AssertNil(t, usage.Touch("pack-cache-stale", time.Now().Add(-40*24*time.Hour)))
+
+			subject = pack.CachePruner{
+				Cli:    mockDocker,
+				Logger: logging.NewLogger(&outBuf, &errBuf, true, false),
+				Usage:  usage,
+			}
+
+			mockDocker.EXPECT().VolumeList(gomock.Any(), gomock.Any()).Return(volume.VolumeListOKBody{
+				Volumes: []*dockertypes.Volume{
+					{Name: "pack-cache-recent"},
+					{Name: "pack-cache-stale", Labels: map[string]string{pack.CacheImageLabel: "some/app"}},
+					{Name: "pack-cache-untracked", CreatedAt: time.Now().Add(-60 * 24 * time.Hour).Format(time.RFC3339)},
+					{Name: "pack-cache-untracked-layers", CreatedAt: time.Now().Add(-60 * 24 * time.Hour).Format(time.RFC3339)},
+				},
+			}, nil)
+		})
+
+		it.After(func() {
+			mockController.Finish()
+			os.RemoveAll(packHome)
+		})
+
+		it("removes volumes unused for longer than the ttl", func() {
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-untracked", false).Return(nil)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-untracked-layers", false).Return(nil)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-stale", false).Return(nil)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-stale-layers", false).Return(nil)
+
+			h.AssertNil(t, subject.Prune(30*24*time.Hour, false))
+			h.AssertContains(t, outBuf.String(), "Removed cache volume 'pack-cache-stale' (some/app)")
+
+			usage, err := config.NewCacheUsage(packHome)
+			h.AssertNil(t, err)
+			_, ok := usage.LastUsed["pack-cache-stale"]
+			h.AssertEq(t, ok, false)
+			_, ok = usage.LastUsed["pack-cache-recent"]
+			h.AssertEq(t, ok, true)
+		})
+
+		it("only lists volumes on a dry run", func() {
+			h.AssertNil(t, subject.Prune(30*24*time.Hour, true))
+			h.AssertContains(t, outBuf.String(), "Would remove cache volume 'pack-cache-untracked'")
+			h.AssertContains(t, outBuf.String(), "Would remove cache volume 'pack-cache-stale' (some/app)")
+			h.AssertNotContains(t, outBuf.String(), "pack-cache-untracked-layers")
+		})
+	})
+}
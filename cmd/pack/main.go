@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
@@ -12,36 +13,86 @@ import (
 	"strings"
 	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/buildpack/pack"
 	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/docker"
 	"github.com/buildpack/pack/fs"
 
 	"github.com/buildpack/lifecycle/image"
 	"github.com/spf13/cobra"
 )
 
+// condensedLinesPerSecond limits lifecycle output when --condense-output is set
+const condensedLinesPerSecond = 50
+
+// watchInterval is how often --watch checks the app dir for changes
+const watchInterval = 500 * time.Millisecond
+
+// Exit statuses of pack, which tell CI pipelines why a build failed. Any other failure exits with 1.
+const (
+	// configErrorExitCode is the exit status of invalid flags or configuration
+	configErrorExitCode = 2
+	// detectFailedExitCode is the exit status of builds where no buildpack group passed detection
+	detectFailedExitCode = 3
+	// buildFailedExitCode is the exit status of builds where a buildpack failed to build
+	buildFailedExitCode = 4
+	// exportFailedExitCode is the exit status of builds whose image could not be exported or pushed
+	exportFailedExitCode = 5
+	// timeoutExitCode is the exit status of builds that exceed --timeout, matching coreutils timeout
+	timeoutExitCode = 124
+)
+
 var (
 	Version           = "0.0.0"
 	timestamps, quiet bool
+	condenseOutput    bool
+	logFile           string
+	dockerTLS         docker.TLSOptions
 	logger            *logging.Logger
 )
 
 func main() {
+	pack.Version = strings.TrimSpace(Version)
 	rootCmd := &cobra.Command{
 		Use: "pack",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			logger = logging.NewLogger(os.Stdout, os.Stderr, !quiet, timestamps)
+			if condenseOutput {
+				logger.Condense(condensedLinesPerSecond)
+			}
+			return logError(func(cmd *cobra.Command, args []string) error {
+				if logFile != "" {
+					f, err := os.Create(logFile)
+					if err != nil {
+						return fmt.Errorf("failed to create log file %s: %s", style.Symbol(logFile), err)
+					}
+					logger.SetLogFile(f)
+				}
+				if err := docker.SetTLSEnv(dockerTLS); err != nil {
+					return err
+				}
+				return applyTheme(cmd, args)
+			})(cmd, args)
 		},
 	}
 	rootCmd.PersistentFlags().BoolVar(&color.NoColor, "no-color", false, "Disable color output")
 	rootCmd.PersistentFlags().BoolVar(&timestamps, "timestamps", false, "Enable timestamps in output")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Show less output")
+	rootCmd.PersistentFlags().BoolVar(&condenseOutput, "condense-output", false, "Collapse repeated lifecycle output lines and limit how many are shown each second")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Also write the full, unfiltered output to `file`")
+	rootCmd.PersistentFlags().BoolVar(&dockerTLS.Verify, "docker-tls-verify", false, "Use TLS and verify the remote daemon (defaults to $DOCKER_TLS_VERIFY)")
+	rootCmd.PersistentFlags().StringVar(&dockerTLS.CertPath, "docker-tls-cert-path", "", "Directory containing ca.pem, cert.pem and key.pem for the remote daemon (defaults to $DOCKER_CERT_PATH or ~/.docker)")
 	addHelpFlag(rootCmd, "pack")
 	for _, f := range []func() *cobra.Command{
 		buildCommand,
 		runCommand,
+		rebuildCommand,
 		rebaseCommand,
+		inspectImageCommand,
+		imageCommand,
+		cacheCommand,
 		createBuilderCommand,
 		addStackCommand,
 		updateStackCommand,
@@ -49,44 +100,196 @@ func main() {
 		showStacksCommand,
 		setDefaultStackCommand,
 		setDefaultBuilderCommand,
+		trustBuilderCommand,
+		untrustBuilderCommand,
 		versionCommand,
 	} {
 		rootCmd.AddCommand(f())
 	}
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return configError{err}
+	})
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
+	}
+}
+
+// configError marks failures caused by invalid flags or configuration
+type configError struct {
+	error
+}
+
+func exitCode(err error) int {
+	if _, ok := err.(*pack.TimeoutError); ok {
+		return timeoutExitCode
+	}
+	if _, ok := err.(configError); ok {
+		return configErrorExitCode
+	}
+	if _, ok := err.(pack.FlagError); ok {
+		return configErrorExitCode
+	}
+	switch pack.FailedPhase(err) {
+	case "detector":
+		return detectFailedExitCode
+	case "builder":
+		return buildFailedExitCode
+	case "exporter":
+		return exportFailedExitCode
 	}
+	return 1
 }
 
 func buildCommand() *cobra.Command {
 	var buildFlags pack.BuildFlags
+	var manifestPath string
+	var matrixBuilders, matrixRunImages []string
+	var watch, all bool
+	var gid, jobs int
 	cmd := &cobra.Command{
-		Use:   "build <image-name>",
-		Args:  cobra.ExactArgs(1),
+		Use: "build <image-name>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if manifestPath != "" || all {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		Short: "Generate app image from source code",
 		RunE: logError(func(cmd *cobra.Command, args []string) error {
-			buildFlags.RepoName = args[0]
 			bf, err := pack.DefaultBuildFactory(logger)
 			if err != nil {
 				return err
 			}
+			batch := manifestPath != "" || all
+			if all && manifestPath != "" {
+				return configError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--all"), style.Symbol("--file"))}
+			}
+			multiFlag := multiBuildFlag(all, manifestPath, matrixBuilders, matrixRunImages)
+			if watch && multiFlag != "" {
+				return configError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--watch"), style.Symbol(multiFlag))}
+			}
+			if len(buildFlags.Tags) > 0 && multiFlag != "" {
+				return configError{fmt.Errorf("%s cannot be used with %s", style.Symbol("--tag"), style.Symbol(multiFlag))}
+			}
+			if cmd.Flags().Changed("jobs") {
+				if jobs < 1 {
+					return configError{fmt.Errorf("%s must be at least 1", style.Symbol("--jobs"))}
+				}
+				if !batch && len(matrixBuilders) == 0 && len(matrixRunImages) == 0 {
+					return configError{fmt.Errorf("%s can only be used with %s, %s or matrix builds", style.Symbol("--jobs"), style.Symbol("--file"), style.Symbol("--all"))}
+				}
+			}
+			if cmd.Flags().Changed("gid") {
+				buildFlags.GID = &gid
+			}
+			ctx, stop := contextForSignals()
+			defer stop()
+			if batch {
+				var manifest *pack.BuildManifest
+				if all {
+					manifest, err = pack.ReadAppsManifest(".")
+				} else {
+					manifest, err = pack.ReadBuildManifest(manifestPath)
+				}
+				if err != nil {
+					return configError{err}
+				}
+				if jobs > 0 {
+					manifest.Jobs = jobs
+				}
+				return logBatchSummary(bf.BuildBatch(ctx, manifest, buildFlags))
+			}
+			buildFlags.RepoName = args[0]
+			if len(matrixBuilders) > 0 || len(matrixRunImages) > 0 {
+				manifest, err := pack.MatrixManifest(buildFlags, matrixBuilders, matrixRunImages)
+				if err != nil {
+					return configError{err}
+				}
+				manifest.Jobs = jobs
+				return logBatchSummary(bf.BuildBatch(ctx, manifest, buildFlags))
+			}
 			b, err := bf.BuildConfigFromFlags(&buildFlags)
 			if err != nil {
 				return err
 			}
-			if err := b.Run(); err != nil {
+			if watch {
+				return b.Watch(ctx, watchInterval)
+			}
+			if err := b.RunContext(ctx); err != nil {
 				return err
 			}
+			if b.DetectOnly || b.DryRun || b.StopAfter != "" {
+				return nil
+			}
 			logger.Info("Successfully built image %s", style.Symbol(b.RepoName))
+			for _, tag := range b.Tags {
+				logger.Info("Tagged as %s", style.Symbol(tag))
+			}
+			logIdentifier(b.Identifier)
 			return nil
 		}),
 	}
 	buildCommandFlags(cmd, &buildFlags)
 	cmd.Flags().BoolVar(&buildFlags.Publish, "publish", false, "Publish to registry")
+	cmd.Flags().StringVar(&buildFlags.Output, "output", "", "Also write the built image to 'oci:<dir>', an OCI image layout directory for tools such as skopeo and crane,\nor to 'docker-archive:<file>', a tarball for 'docker load'")
+	cmd.Flags().BoolVar(&buildFlags.Sparse, "sparse", false, "With --output, leave the layers of the run image out of the written image, for pipelines that already have the run image")
+	cmd.Flags().IntVar(&gid, "gid", 0, "Group ID owning the app and the layers of the built image (defaults to the builder's PACK_GROUP_ID)")
+	cmd.Flags().StringArrayVar(&buildFlags.Labels, "label", nil, "Label to add to the built image, of the form 'key=value'\nRepeat for each label")
+	cmd.Flags().StringArrayVarP(&buildFlags.Tags, "tag", "t", nil, "Additional tag for the built image, also pushed with --publish\nRepeat for each tag")
+	cmd.Flags().StringVar(&buildFlags.ReportPath, "report", "", "Write a report of the built image, its digest or ID and its buildpacks to a file, as JSON for a .json file and TOML otherwise")
+	cmd.Flags().StringVar(&buildFlags.BOMPath, "bom-file", "", "Write a bill of materials of the built image, its buildpacks and the layers they contributed, to a file, as JSON for a .json file and TOML otherwise")
+	cmd.Flags().BoolVar(&buildFlags.PrintBOM, "bom", false, "Print a bill of materials of the built image, its buildpacks and the layers they contributed")
+	cmd.Flags().BoolVar(&buildFlags.DryRun, "dry-run", false, "Print the resolved builder, run image, stack, cache, buildpacks and env without building")
+	cmd.Flags().BoolVar(&buildFlags.DetectOnly, "detect-only", false, "Only run detection, and print the buildpack group and build plan that were chosen")
+	cmd.Flags().StringVar(&buildFlags.StopAfter, "stop-after", "", "Stop after the 'detect', 'analyze' or 'build' phase, keeping the workspace volume to inspect what the phase left")
+	cmd.Flags().StringVarP(&manifestPath, "file", "f", "", "Build every app described in a build manifest TOML file instead of a single image")
+	cmd.Flags().BoolVar(&all, "all", false, "Build every app listed in "+pack.AppsManifest+" in the current directory, pulling the images they share once")
+	cmd.Flags().IntVar(&jobs, "jobs", 0, "Number of builds of --file, --all or a build matrix to run at once, each with its own cache volume (defaults to 'jobs' in the manifest, or 1)")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Rebuild whenever files in the app dir change, until interrupted")
+	cmd.Flags().StringSliceVar(&matrixBuilders, "matrix-builder", nil, "Also build with this builder, suffixing the image tag with its name"+multiValueHelp("builder"))
+	cmd.Flags().StringSliceVar(&matrixRunImages, "matrix-run-image", nil, "Also build with this run image, suffixing the image tag with its name"+multiValueHelp("run image"))
 	addHelpFlag(cmd, "build")
 	return cmd
 }
 
+// logIdentifier prints the image ID of images saved to the daemon, or the digest reference of published images
+func logIdentifier(id pack.Identifier) {
+	switch id.Kind() {
+	case pack.LocalImageIDKind:
+		logger.Info("Image ID: %s", id)
+	case pack.RemoteDigestReferenceKind:
+		logger.Info("Digest reference: %s", id)
+	}
+}
+
+func logBatchSummary(results []pack.BatchResult) error {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Noop("Image"), style.Noop("Identifier"), style.Noop("Status"), style.Noop("Duration"))
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Noop("-----"), style.Noop("----------"), style.Noop("------"), style.Noop("--------"))
+	failed := 0
+	for _, result := range results {
+		status := "succeeded"
+		if result.Err != nil {
+			status = "failed: " + result.Err.Error()
+			failed++
+		}
+		id := "-"
+		if result.Identifier != nil {
+			id = result.Identifier.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", style.Key(result.RepoName), style.Noop(id), style.Noop(status), style.Noop(result.Duration.Round(time.Second).String()))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	logger.Info(buf.String())
+	if failed > 0 {
+		return fmt.Errorf("%d of %d builds failed", failed, len(results))
+	}
+	return nil
+}
+
 func runCommand() *cobra.Command {
 	var runFlags pack.RunFlags
 	cmd := &cobra.Command{
@@ -107,20 +310,94 @@ func runCommand() *cobra.Command {
 	}
 
 	buildCommandFlags(cmd, &runFlags.BuildFlags)
+	cmd.Flags().Lookup("env").Usage = "Environment variable for the build and the app container, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed\nTakes precedence over --env-file\nRepeat for each environment variable"
+	cmd.Flags().Lookup("env-file").Usage = "Environment variables file for the build and the app container\nOne variable per line, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed\nRepeat for each file, variables in later files take precedence"
+	cmd.Flags().Lookup("volume").Usage = "Mount a host directory into the detect and build containers and the app container, of the form 'host-path:container-path[:ro|rw]'\nRepeat for each volume"
 	cmd.Flags().StringSliceVar(&runFlags.Ports, "port", nil, "Port to publish (defaults to port(s) exposed by container)"+multiValueHelp("port"))
+	cmd.Flags().StringVar(&runFlags.Memory, "memory", "", "Memory limit for the app container, e.g. '512m' or '2g'")
+	cmd.Flags().Float64Var(&runFlags.CPUs, "cpus", 0, "Number of CPUs available to the app container, e.g. '1.5'")
+	cmd.Flags().BoolVar(&runFlags.PublishAll, "publish-all", false, "Publish all exposed ports to random host ports and print the mapping once the container is running")
+	cmd.Flags().BoolVarP(&runFlags.Detach, "detach", "d", false, "Run the app container in the background, printing its name and ports, instead of streaming its logs until interrupted")
 	addHelpFlag(cmd, "run")
 	return cmd
 }
 
 func buildCommandFlags(cmd *cobra.Command, buildFlags *pack.BuildFlags) {
-	cmd.Flags().StringVarP(&buildFlags.AppDir, "path", "p", "", "Path to app dir (defaults to current working directory)")
-	cmd.Flags().StringVar(&buildFlags.Builder, "builder", "", "Builder (defaults to builder configured by 'set-default-builder')")
-	cmd.Flags().StringVar(&buildFlags.RunImage, "run-image", "", "Run image (defaults to default stack's run image)")
-	cmd.Flags().StringVar(&buildFlags.EnvFile, "env-file", "", "Build-time environment variables file\nOne variable per line, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed")
+	cmd.Flags().StringVarP(&buildFlags.AppDir, "path", "p", os.Getenv("CNB_APP_DIR"), "Path to app dir, to a single app file such as a .jar or source .tgz, or - to read a tar from stdin (defaults to $CNB_APP_DIR or current working directory)")
+	cmd.Flags().StringVar(&buildFlags.Builder, "builder", os.Getenv("CNB_BUILDER"), "Builder (defaults to $CNB_BUILDER or builder configured by 'set-default-builder')")
+	cmd.Flags().StringVar(&buildFlags.RunImage, "run-image", os.Getenv("CNB_RUN_IMAGE"), "Run image (defaults to $CNB_RUN_IMAGE or default stack's run image)")
+	cmd.Flags().StringArrayVarP(&buildFlags.Env, "env", "e", nil, "Build-time environment variable, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed\nTakes precedence over --env-file\nRepeat for each environment variable")
+	cmd.Flags().StringVar(&buildFlags.Descriptor, "descriptor", "", "Path to a project descriptor declaring buildpacks, env vars and files to include (defaults to project.toml in the app dir)")
+	cmd.Flags().StringArrayVar(&buildFlags.EnvFiles, "env-file", nil, "Build-time environment variables file\nOne variable per line, of the form 'VAR=VALUE' or 'VAR'\nWhen using latter value-less form, value will be taken from current\n  environment at the time this command is executed\nRepeat for each file, variables in later files take precedence")
+	cmd.Flags().StringArrayVar(&buildFlags.LaunchEnv, "launch-env", nil, "Launch-time environment variable set on the app image, of the form 'VAR=VALUE' or 'VAR'\nBuildpacks don't see it, use --env as well for a variable needed at build and launch\nTakes precedence over --launch-env-file\nRepeat for each environment variable")
+	cmd.Flags().StringArrayVar(&buildFlags.LaunchEnvFiles, "launch-env-file", nil, "Launch-time environment variables file, in the format of --env-file\nRepeat for each file, variables in later files take precedence")
 	cmd.Flags().BoolVar(&buildFlags.NoPull, "no-pull", false, "Skip pulling images before use")
-	cmd.Flags().BoolVar(&buildFlags.ClearCache, "clear-cache", false, "Clear image's associated cache before building")
-	_ = cmd.Flags().MarkHidden("clear-cache")
-	cmd.Flags().StringSliceVar(&buildFlags.Buildpacks, "buildpack", nil, "Buildpack ID, path to directory, or path/URL to .tgz file"+multiValueHelp("buildpack"))
+	cmd.Flags().MarkDeprecated("no-pull", "use --pull-policy never")
+	cmd.Flags().BoolVar(&buildFlags.Creator, "creator", false, "Run all lifecycle phases in a single container, which is faster, when publishing with a trusted builder whose lifecycle provides the creator")
+	cmd.Flags().StringVar(&buildFlags.Platform, "platform", "", "Pull the builder and run images for another platform, e.g. 'linux/arm64', when the daemon can run it")
+	cmd.Flags().BoolVar(&buildFlags.Load, "load", false, "With --publish, also pull the published image into the daemon")
+	cmd.Flags().StringVar(&buildFlags.CreationTime, "creation-time", os.Getenv(pack.SourceDateEpochEnv), "Created time of the image, in seconds since the Unix epoch or RFC 3339 format, so builds of the same source produce the same image (defaults to $SOURCE_DATE_EPOCH)")
+	cmd.Flags().StringVar(&buildFlags.SourceCommit, "source-commit", "", "Commit of the app source recorded on the image (defaults to the git commit of the app dir)")
+	cmd.Flags().StringVar(&buildFlags.SourceBranch, "source-branch", "", "Branch of the app source recorded on the image (defaults to the git branch of the app dir)")
+	cmd.Flags().StringVar(&buildFlags.SourceURL, "source-url", "", "Repository URL of the app source recorded on the image (defaults to the git remote 'origin' of the app dir)")
+	cmd.Flags().BoolVar(&buildFlags.Debug, "debug", false, "Open a shell in the container of a failed lifecycle phase before cleaning up")
+	cmd.Flags().StringVar(&buildFlags.CacheImage, "cache-image", "", "Restore and publish the build cache as an image in a registry, shorthand for --cache 'type=registry,ref=<image>'")
+	cmd.Flags().StringVar(&buildFlags.Cache, "cache", "", "Where to keep the build cache, e.g. 'type=registry,ref=registry.com/some/app-cache', or the name of a cache volume to share between images (defaults to a local volume for the image)")
+	cmd.Flags().StringVar(&buildFlags.PullPolicy, "pull-policy", "", "When to pull images for daemon builds: 'if-changed' (skips the run image when its digest is unchanged), 'if-not-present', 'always' or 'never' (defaults to 'if-changed', or 'if-not-present' with --offline)")
+	cmd.Flags().BoolVar(&buildFlags.ClearCache, "clear-cache", false, "Clear the layers buildpacks cached for the image before building, and skip restoring a registry cache")
+	cmd.Flags().BoolVar(&buildFlags.ClearLaunch, "clear-launch", false, "Clear the app and launch layers kept from the image's previous build before building")
+	cmd.Flags().StringSliceVar(&buildFlags.Buildpacks, "buildpack", nil, "Buildpack ID, path to directory or .tgz/.tar file, or http(s) URL to .tgz file"+multiValueHelp("buildpack"))
+	cmd.Flags().StringVar(&buildFlags.Order, "order", "", "Path to an order.toml with the groups of buildpacks to detect instead of the builder's order\nBuildpacks given with --buildpack are added to the builder's for use in the groups")
+	cmd.Flags().StringVar(&buildFlags.PreviousImage, "previous-image", "", "Image to reuse layers from instead of the image being built, e.g. when renaming an app or promoting it between registries")
+	cmd.Flags().StringVar(&buildFlags.LifecycleImage, "lifecycle-image", "", "Image containing the lifecycle binaries to use instead of those in the builder")
+	cmd.Flags().StringVar(&buildFlags.LifecycleVersion, "lifecycle-version", "", "Version of a lifecycle release to download and use instead of the lifecycle in the builder, e.g. '0.5.0'")
+	cmd.Flags().StringVar(&buildFlags.Memory, "build-memory", "", "Memory limit for the lifecycle containers, e.g. '2g' (defaults to 'build-memory' in config.toml)")
+	cmd.Flags().Float64Var(&buildFlags.CPUs, "build-cpus", 0, "Number of CPUs available to the lifecycle containers (defaults to 'build-cpus' in config.toml)")
+	cmd.Flags().StringArrayVar(&buildFlags.Volumes, "volume", nil, "Mount a host directory into the detect and build containers, of the form 'host-path:container-path[:ro|rw]'\nRepeat for each volume")
+	cmd.Flags().StringArrayVar(&buildFlags.Bindings, "binding", nil, "Service binding directory to mount at /platform/bindings/<dir-name> for the build, and for the app with 'pack run'\nRepeat for each binding")
+	cmd.Flags().BoolVar(&buildFlags.ClearOnCancel, "clear-cache-on-interrupt", false, "Remove the cache volume when the build is interrupted or times out, as it may hold a partial build")
+	cmd.Flags().DurationVar(&buildFlags.Timeout, "timeout", 0, "Stop the build when it takes longer than this, e.g. '30m' (defaults to no timeout)")
+	cmd.Flags().StringVar(&buildFlags.Network, "network", "", "Connect the lifecycle containers to a network, e.g. a user-defined Docker network")
+	cmd.Flags().BoolVar(&buildFlags.Offline, "offline", false, "Build without network access: only use images on the daemon and buildpacks in the builder or on disk, and run buildpacks without a network")
+	cmd.Flags().StringArrayVar(&buildFlags.CACerts, "ca-cert", nil, "File of PEM encoded CA certificates trusted by buildpacks and the lifecycle besides the builder's, e.g. of a TLS-intercepting proxy\nAdded to 'ca-certs' in config.toml\nRepeat for each file")
+	cmd.Flags().BoolVar(&buildFlags.Sandbox, "sandbox", false, "Run the buildpacks of the builder sandboxed: without network access or capabilities, on a read-only root filesystem")
+	cmd.Flags().StringVar(&buildFlags.DefaultProcess, "default-process", "", "Process type started when the image is run without a command, e.g. 'worker' (defaults to 'web')")
+	cmd.Flags().IntVar(&buildFlags.Retries, "retries", 0, "Number of times to retry a phase that fails with a transient daemon or registry error")
+}
+
+func rebuildCommand() *cobra.Command {
+	var flags pack.RebuildFlags
+	cmd := &cobra.Command{
+		Use:   "rebuild <image-name>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Rebuild app image using the settings recorded when it was built",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			flags.RepoName = args[0]
+			bf, err := pack.DefaultBuildFactory(logger)
+			if err != nil {
+				return err
+			}
+			b, err := bf.RebuildConfigFromFlags(&flags)
+			if err != nil {
+				return err
+			}
+			ctx, stop := contextForSignals()
+			defer stop()
+			if err := b.RunContext(ctx); err != nil {
+				return err
+			}
+			logger.Info("Successfully rebuilt image %s", style.Symbol(b.RepoName))
+			logIdentifier(b.Identifier)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&flags.AppDir, "path", "p", "", "Path to app dir (defaults to path recorded on the image)")
+	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "Publish to registry")
+	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "Skip pulling images before use")
+	cmd.Flags().MarkDeprecated("no-pull", "use --pull-policy never")
+	cmd.Flags().StringVar(&flags.PullPolicy, "pull-policy", "", "When to pull images for daemon builds: 'if-changed' (skips the run image when its digest is unchanged), 'if-not-present', 'always' or 'never' (defaults to 'if-changed')")
+	addHelpFlag(cmd, "rebuild")
+	return cmd
 }
 
 func rebaseCommand() *cobra.Command {
@@ -132,6 +409,10 @@ func rebaseCommand() *cobra.Command {
 		RunE: logError(func(cmd *cobra.Command, args []string) error {
 			flags.RepoName = args[0]
 
+			cli, err := docker.New()
+			if err != nil {
+				return err
+			}
 			imageFactory, err := image.DefaultFactory()
 			if err != nil {
 				return err
@@ -144,24 +425,258 @@ func rebaseCommand() *cobra.Command {
 				Logger:       logger,
 				Config:       cfg,
 				ImageFactory: imageFactory,
+				Cli:          cli,
 			}
 			rebaseConfig, err := factory.RebaseConfigFromFlags(flags)
 			if err != nil {
 				return err
 			}
-			if err := factory.Rebase(rebaseConfig); err != nil {
+			id, err := factory.Rebase(rebaseConfig)
+			if err != nil {
 				return err
 			}
 			logger.Info("Successfully rebased image %s", style.Symbol(rebaseConfig.Image.Name()))
+			logIdentifier(id)
 			return nil
 		}),
 	}
+	cmd.Flags().StringVar(&flags.RunImage, "run-image", os.Getenv("CNB_RUN_IMAGE"), "Run image to rebase onto (defaults to $CNB_RUN_IMAGE or the image's stack run image)")
 	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "Publish to registry")
 	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "Skip pulling images before use")
+	cmd.Flags().MarkDeprecated("no-pull", "use --pull-policy never")
+	cmd.Flags().StringVar(&flags.PullPolicy, "pull-policy", pack.PullAlways, "When to pull images: 'always', 'if-not-present' or 'never'")
 	addHelpFlag(cmd, "rebase")
 	return cmd
 }
 
+func inspectImageCommand() *cobra.Command {
+	var remote bool
+	cmd := &cobra.Command{
+		Use:   "inspect-image <image-name>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Show how an app image was built",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			bf, err := pack.DefaultBuildFactory(logger)
+			if err != nil {
+				return err
+			}
+			metadata, err := bf.InspectImage(args[0], remote)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			w := tabwriter.NewWriter(&buf, 0, 0, 4, ' ', 0)
+			rows := [][2]string{
+				{"Pack Version", metadata.PackVersion},
+				{"Source", metadata.AppDir},
+				{"Source Commit", metadata.SourceCommit},
+				{"Builder", metadata.Builder},
+				{"Builder ID", metadata.BuilderID},
+				{"Builder Digest", metadata.BuilderDigest},
+				{"Run Image", metadata.RunImage},
+				{"Buildpacks", strings.Join(metadata.Buildpacks, ", ")},
+				{"Env", strings.Join(metadata.Env, ", ")},
+				{"Published", fmt.Sprintf("%t", metadata.Flags.Publish)},
+				{"Lifecycle Image", metadata.Flags.LifecycleImage},
+			}
+			for _, row := range rows {
+				if row[1] == "" {
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\n", style.Key(row[0]+":"), style.Noop(row[1]))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			logger.Info(buf.String())
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&remote, "remote", false, "Inspect image in registry instead of daemon")
+	addHelpFlag(cmd, "inspect-image")
+	return cmd
+}
+
+func imageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Manage app images",
+	}
+	cmd.AddCommand(imageDeleteCommand())
+	addHelpFlag(cmd, "image")
+	return cmd
+}
+
+func imageDeleteCommand() *cobra.Command {
+	var flags pack.DeleteImageFlags
+	cmd := &cobra.Command{
+		Use:   "delete <image-name>...",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Delete app images and any temporary images left behind by pack",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			flags.RepoNames = args
+			cli, err := docker.New()
+			if err != nil {
+				return err
+			}
+			imageFactory, err := image.DefaultFactory()
+			if err != nil {
+				return err
+			}
+			deleter := pack.ImageDeleter{
+				Cli:          cli,
+				Logger:       logger,
+				ImageFactory: imageFactory,
+			}
+			return deleter.Delete(flags)
+		}),
+	}
+	cmd.Flags().BoolVar(&flags.Registry, "registry", false, "Also delete the image from its registry, where supported")
+	cmd.Flags().BoolVarP(&flags.Force, "force", "f", false, "Delete images even if they are used by stopped containers")
+	addHelpFlag(cmd, "delete")
+	return cmd
+}
+
+func cacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage build caches",
+	}
+	cmd.AddCommand(cacheExportCommand())
+	cmd.AddCommand(cacheImportCommand())
+	cmd.AddCommand(cachePruneCommand())
+	addHelpFlag(cmd, "cache")
+	return cmd
+}
+
+func cacheExportCommand() *cobra.Command {
+	var flags pack.CacheArchiveFlags
+	var output string
+	cmd := &cobra.Command{
+		Use:   "export <image-name> -o <cache.tgz>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Export the build cache of an app image to a gzipped tarball",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			flags.RepoName = args[0]
+			archiver, err := newCacheArchiver()
+			if err != nil {
+				return err
+			}
+			if output == "-" {
+				return archiver.Export(flags, os.Stdout)
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := archiver.Export(flags, f); err != nil {
+				return err
+			}
+			logger.Info("Exported cache for %s to %s", style.Symbol(flags.RepoName), style.Symbol(output))
+			return f.Close()
+		}),
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the cache to, or '-' for stdout")
+	cmd.MarkFlagRequired("output")
+	cmd.Flags().StringVar(&flags.Builder, "builder", "", "Image used to access the cache volume (defaults to builder configured by 'set-default-builder')")
+	addHelpFlag(cmd, "export")
+	return cmd
+}
+
+func cacheImportCommand() *cobra.Command {
+	var flags pack.CacheArchiveFlags
+	var input string
+	cmd := &cobra.Command{
+		Use:   "import <image-name> -i <cache.tgz>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Replace the build cache of an app image with an exported tarball",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			flags.RepoName = args[0]
+			archiver, err := newCacheArchiver()
+			if err != nil {
+				return err
+			}
+			if input == "-" {
+				return archiver.Import(flags, os.Stdin)
+			}
+			f, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := archiver.Import(flags, f); err != nil {
+				return err
+			}
+			logger.Info("Imported cache for %s from %s", style.Symbol(flags.RepoName), style.Symbol(input))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&input, "input", "i", "", "File to read the cache from, or '-' for stdin")
+	cmd.MarkFlagRequired("input")
+	cmd.Flags().StringVar(&flags.Builder, "builder", "", "Image used to access the cache volume (defaults to builder configured by 'set-default-builder')")
+	addHelpFlag(cmd, "import")
+	return cmd
+}
+
+func cachePruneCommand() *cobra.Command {
+	var olderThan string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Args:  cobra.NoArgs,
+		Short: "Remove cache volumes that haven't been used by a build recently",
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			cli, err := docker.New()
+			if err != nil {
+				return err
+			}
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			usage, err := config.NewCacheUsage(config.PackHome())
+			if err != nil {
+				return err
+			}
+			if olderThan == "" {
+				olderThan = cfg.CacheTTL
+			}
+			ttl, err := pack.ParseCacheTTL(olderThan)
+			if err != nil {
+				return err
+			}
+			pruner := pack.CachePruner{
+				Cli:    cli,
+				Logger: logger,
+				Usage:  usage,
+			}
+			return pruner.Prune(ttl, dryRun)
+		}),
+	}
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Remove volumes unused for longer than this, e.g. '30d' (defaults to 'cache-ttl' in config.toml, or 30 days)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the volumes that would be removed without removing them")
+	addHelpFlag(cmd, "prune")
+	return cmd
+}
+
+func newCacheArchiver() (*pack.CacheArchiver, error) {
+	cli, err := docker.New()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.NewDefault()
+	if err != nil {
+		return nil, err
+	}
+	return &pack.CacheArchiver{
+		Cli:    cli,
+		Logger: logger,
+		Config: cfg,
+	}, nil
+}
+
 func createBuilderCommand() *cobra.Command {
 	flags := pack.CreateBuilderFlags{}
 	cmd := &cobra.Command{
@@ -205,7 +720,7 @@ func createBuilderCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "Skip pulling stack image before use")
 	cmd.Flags().StringVarP(&flags.BuilderTomlPath, "builder-config", "b", "", "Path to builder TOML file (required)")
 	cmd.MarkFlagRequired("builder-config")
-	cmd.Flags().StringVarP(&flags.StackID, "stack", "s", "", "Stack ID (defaults to stack configured by 'set-default-stack')")
+	cmd.Flags().StringVarP(&flags.StackID, "stack", "s", os.Getenv("CNB_STACK_ID"), "Stack ID (defaults to $CNB_STACK_ID or stack configured by 'set-default-stack')")
 	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "Publish to registry")
 	addHelpFlag(cmd, "create-builder")
 	return cmd
@@ -288,6 +803,51 @@ func setDefaultBuilderCommand() *cobra.Command {
 	return cmd
 }
 
+func trustBuilderCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trust-builder <builder-name>",
+		Short: "Trust a builder, so --creator runs its buildpacks in the container that holds the registry credentials",
+		Long: "Trust a builder, so 'pack build --publish --creator' runs its buildpacks in the container that holds the registry credentials.\n" +
+			"Buildpacks of untrusted builders run in containers without registry credentials, only the analyzer and exporter get them.\n" +
+			"Trust grants nothing else: images built on the daemon are read and written by pack, never giving the lifecycle the docker socket or root.",
+		Args: cobra.ExactArgs(1),
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			if err := cfg.TrustBuilder(args[0]); err != nil {
+				return err
+			}
+			logger.Info("Builder %s is now trusted", style.Symbol(args[0]))
+			return nil
+		}),
+	}
+	addHelpFlag(cmd, "trust-builder")
+	return cmd
+}
+
+func untrustBuilderCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "untrust-builder <builder-name>",
+		Short: "Stop trusting a builder, so its buildpacks never run with the registry credentials",
+		Args:  cobra.ExactArgs(1),
+		RunE: logError(func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.NewDefault()
+			if err != nil {
+				return err
+			}
+			if err := cfg.UntrustBuilder(args[0]); err != nil {
+				return err
+			}
+			logger.Info("Builder %s is no longer trusted", style.Symbol(args[0]))
+			return nil
+		}),
+	}
+	addHelpFlag(cmd, "untrust-builder")
+	return cmd
+}
+
 func updateStackCommand() *cobra.Command {
 	flags := struct {
 		BuildImage string
@@ -401,10 +961,64 @@ func makeStopChannelForSignals() <-chan struct{} {
 	return stopCh
 }
 
+// contextForSignals returns a context that is canceled on the first SIGINT or SIGTERM. Later signals
+// are handled as usual, so a second interrupt exits immediately.
+func contextForSignals() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigsCh := make(chan os.Signal, 1)
+	signal.Notify(sigsCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigsCh:
+			logger.Info("Interrupted, stopping the build (interrupt again to exit immediately)")
+			signal.Stop(sigsCh)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigsCh)
+		cancel()
+	}
+}
+
 func addHelpFlag(cmd *cobra.Command, commandName string) {
 	cmd.Flags().BoolP("help", "h", false, fmt.Sprintf("Help for '%s'", commandName))
 }
 
+func applyTheme(cmd *cobra.Command, args []string) error {
+	if color.NoColor {
+		return nil
+	}
+	cfg, err := config.NewDefault()
+	if err != nil {
+		return err
+	}
+	return style.ApplyTheme(cfg.Theme.Name, style.Theme{
+		Step:   cfg.Theme.Step,
+		Symbol: cfg.Theme.Symbol,
+		Tip:    cfg.Theme.Tip,
+		Error:  cfg.Theme.Error,
+		Warn:   cfg.Theme.Warn,
+		Prefix: cfg.Theme.Prefix,
+	})
+}
+
+// multiBuildFlag returns the flag given to build that builds several images, or "" when there is none
+func multiBuildFlag(all bool, manifestPath string, matrixBuilders, matrixRunImages []string) string {
+	switch {
+	case all:
+		return "--all"
+	case manifestPath != "":
+		return "--file"
+	case len(matrixBuilders) > 0:
+		return "--matrix-builder"
+	case len(matrixRunImages) > 0:
+		return "--matrix-run-image"
+	}
+	return ""
+}
+
 func logError(f func(cmd *cobra.Command, args []string) error) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceErrors = true
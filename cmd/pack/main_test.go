@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"github.com/spf13/cobra"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestPackCommands(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "pack", testPackCommands, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testPackCommands(t *testing.T, when spec.G, it spec.S) {
+	when("build flags", func() {
+		var (
+			factory          *pack.BuildFactory
+			mockController   *gomock.Controller
+			mockImageFactory *mocks.MockImageFactory
+			cmd              *cobra.Command
+			buildFlags       pack.BuildFlags
+			outBuf, errBuf   bytes.Buffer
+		)
+
+		it.Before(func() {
+			mockController = gomock.NewController(t)
+			mockImageFactory = mocks.NewMockImageFactory(mockController)
+			factory = &pack.BuildFactory{
+				ImageFactory: mockImageFactory,
+				Config: &config.Config{
+					Stacks: []config.Stack{
+						{
+							ID:        "some.stack.id",
+							RunImages: []string{"some/run"},
+						},
+					},
+				},
+				Cli:    mocks.NewMockDocker(mockController),
+				Logger: logging.NewLogger(&outBuf, &errBuf, true, false),
+			}
+			buildFlags = pack.BuildFlags{RepoName: "some/app"}
+			cmd = &cobra.Command{}
+			buildCommandFlags(cmd, &buildFlags)
+		})
+
+		it.After(func() {
+			mockController.Finish()
+		})
+
+		it("builds with only --offline, using the images on the daemon", func() {
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Found().Return(true, nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("some/builder", false).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Found().Return(true, nil)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil).Times(2)
+			mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
+
+			h.AssertNil(t, cmd.ParseFlags([]string{"--builder", "some/builder", "--offline"}))
+			h.AssertEq(t, buildFlags.PullPolicy, "")
+
+			_, err := factory.BuildConfigFromFlags(&buildFlags)
+			h.AssertNil(t, err)
+		})
+
+		it("rejects a pull policy that needs the network when set with --offline", func() {
+			h.AssertNil(t, cmd.ParseFlags([]string{"--builder", "some/builder", "--offline", "--pull-policy", "always"}))
+
+			_, err := factory.BuildConfigFromFlags(&buildFlags)
+			h.AssertError(t, err, "'--pull-policy always' cannot be used with '--offline'")
+		})
+
+		it("exits with the config error code for invalid flags", func() {
+			h.AssertNil(t, cmd.ParseFlags([]string{"--builder", "some/builder", "--load"}))
+
+			_, err := factory.BuildConfigFromFlags(&buildFlags)
+			h.AssertError(t, err, "'--load' can only be used with '--publish'")
+			h.AssertEq(t, exitCode(err), configErrorExitCode)
+		})
+
+		it("exits with the generic code when the builder can't be read from the daemon", func() {
+			mockImageFactory.EXPECT().NewLocal("some/builder", false).Return(nil, errors.New("Cannot connect to the Docker daemon"))
+			h.AssertNil(t, cmd.ParseFlags([]string{"--builder", "some/builder", "--pull-policy", "never"}))
+
+			_, err := factory.BuildConfigFromFlags(&buildFlags)
+			h.AssertError(t, err, "Cannot connect to the Docker daemon")
+			h.AssertEq(t, exitCode(err), 1)
+		})
+	})
+
+	when("#multiBuildFlag", func() {
+		it("names the flag given that builds several images", func() {
+			h.AssertEq(t, multiBuildFlag(true, "", nil, nil), "--all")
+			h.AssertEq(t, multiBuildFlag(false, "builds.toml", nil, nil), "--file")
+			h.AssertEq(t, multiBuildFlag(false, "", []string{"some/builder"}, nil), "--matrix-builder")
+			h.AssertEq(t, multiBuildFlag(false, "", nil, []string{"some/run"}), "--matrix-run-image")
+			h.AssertEq(t, multiBuildFlag(false, "", nil, nil), "")
+		})
+	})
+}
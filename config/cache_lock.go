@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CacheLocks keeps a lock file for each cache volume in use by a build, so builds sharing a cache volume
+// don't run at the same time. The locks are advisory: only pack takes them.
+type CacheLocks struct {
+	dir string
+}
+
+func NewCacheLocks(packHome string) *CacheLocks {
+	return &CacheLocks{dir: filepath.Join(packHome, "locks")}
+}
+
+// CacheLockedError is returned by Lock when another process holds the lock of the cache volume
+type CacheLockedError struct {
+	Volume string
+	PID    int
+}
+
+func (e *CacheLockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("cache volume %s is in use by another build", e.Volume)
+	}
+	return fmt.Sprintf("cache volume %s is in use by another build (pid %d)", e.Volume, e.PID)
+}
+
+// Lock takes the lock of volume, returning a func releasing it. Locks left behind by processes that are
+// no longer running are taken over.
+func (l *CacheLocks) Lock(volume string) (func() error, error) {
+	if err := os.MkdirAll(l.dir, 0777); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(l.dir, volume+".lock")
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() error { return os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+		if err != nil {
+			// the lock may have been created but not written yet
+			if info, statErr := os.Stat(path); statErr != nil || time.Since(info.ModTime()) < time.Minute {
+				return nil, &CacheLockedError{Volume: volume}
+			}
+		} else if processRunning(pid) {
+			return nil, &CacheLockedError{Volume: volume, PID: pid}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// processRunning reports whether the process with pid is running
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process on windows, which fails once it has exited
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CacheUsage records when each cache volume was last used by a build, and who owns its files.
+// Docker volume labels can't be changed after creation, so this is kept alongside the config.
+type CacheUsage struct {
+	LastUsed map[string]time.Time `toml:"last-used"`
+	// Owners identifies, for each cache volume, the owner a build gave its workspace and the volumes it did so
+	Owners map[string]string `toml:"owners,omitempty"`
+	path   string
+	mu     sync.Mutex
+}
+
+func NewCacheUsage(packHome string) (*CacheUsage, error) {
+	usage := &CacheUsage{
+		LastUsed: map[string]time.Time{},
+		Owners:   map[string]string{},
+		path:     filepath.Join(packHome, "cache-usage.toml"),
+	}
+	if _, err := toml.DecodeFile(usage.path, usage); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (u *CacheUsage) Touch(volume string, t time.Time) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.LastUsed[volume] = t
+	return u.save()
+}
+
+// Owner returns the owner recorded by SetOwner for volume, or "" when there is none
+func (u *CacheUsage) Owner(volume string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Owners[volume]
+}
+
+func (u *CacheUsage) SetOwner(volume, owner string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Owners[volume] = owner
+	return u.save()
+}
+
+func (u *CacheUsage) Forget(volume string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.LastUsed, volume)
+	delete(u.Owners, volume)
+	return u.save()
+}
+
+func (u *CacheUsage) save() error {
+	if err := os.MkdirAll(filepath.Dir(u.path), 0777); err != nil {
+		return err
+	}
+	w, err := os.Create(u.path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return toml.NewEncoder(w).Encode(u)
+}
@@ -5,17 +5,57 @@ import (
 	"fmt"
 	"github.com/buildpack/pack/style"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
+// DefaultImageNameTemplate names images built without an explicit image name.
+// Templates may reference {{.Basename}}, {{.Hash}} (md5 of the app dir path) and {{.Branch}} (current git branch).
+const DefaultImageNameTemplate = "pack.local/run/{{.Hash}}"
+
+// suggestedBuilders are offered when building without a builder, see SuggestedBuilders
+var suggestedBuilders = []string{"packs/samples:v3alpha2", "cloudfoundry/cnb:bionic"}
+
 type Config struct {
-	Stacks         []Stack `toml:"stacks"`
-	DefaultStackID string  `toml:"default-stack-id"`
-	DefaultBuilder string  `toml:"default-builder"`
-	configPath     string
+	Stacks            []Stack `toml:"stacks"`
+	DefaultStackID    string  `toml:"default-stack-id"`
+	DefaultBuilder    string  `toml:"default-builder"`
+	ImageNameTemplate string  `toml:"image-name-template,omitempty"`
+	BuildMemory       string  `toml:"build-memory,omitempty"`
+	BuildCPUs         float64 `toml:"build-cpus,omitempty"`
+	CacheTTL          string  `toml:"cache-ttl,omitempty"`
+	Theme             Theme   `toml:"theme,omitempty"`
+	Proxy             Proxy   `toml:"proxy,omitempty"`
+	// BuildpackRegistry is the index used to resolve buildpacks missing from the builder
+	BuildpackRegistry string `toml:"buildpack-registry,omitempty"`
+	// TrustedBuilders may run their buildpacks in the creator container of 'pack build --publish --creator',
+	// which holds the registry credentials. Trust grants nothing else: no build gives the lifecycle the docker socket or root.
+	TrustedBuilders []string `toml:"trusted-builders,omitempty"`
+	// CACerts are files of PEM encoded CA certificates trusted by every build, see 'pack build --ca-cert'
+	CACerts    []string `toml:"ca-certs,omitempty"`
+	configPath string
+}
+
+// Theme selects a built-in color theme ("dark" or "light") and optionally overrides individual colors
+type Theme struct {
+	Name   string `toml:"name,omitempty"`
+	Step   string `toml:"step,omitempty"`
+	Symbol string `toml:"symbol,omitempty"`
+	Tip    string `toml:"tip,omitempty"`
+	Error  string `toml:"error,omitempty"`
+	Warn   string `toml:"warn,omitempty"`
+	Prefix string `toml:"prefix,omitempty"`
+}
+
+// Proxy is passed to the containers running buildpacks when the host environment sets no proxy
+type Proxy struct {
+	HTTP    string `toml:"http-proxy,omitempty"`
+	HTTPS   string `toml:"https-proxy,omitempty"`
+	NoProxy string `toml:"no-proxy,omitempty"`
 }
 
 type Stack struct {
@@ -26,11 +66,16 @@ type Stack struct {
 }
 
 func NewDefault() (*Config, error) {
+	return New(PackHome())
+}
+
+// PackHome is the directory holding pack's config and state, $PACK_HOME or ~/.pack
+func PackHome() string {
 	packHome := os.Getenv("PACK_HOME")
 	if packHome == "" {
 		packHome = filepath.Join(os.Getenv("HOME"), ".pack")
 	}
-	return New(packHome)
+	return packHome
 }
 
 func New(path string) (*Config, error) {
@@ -79,7 +124,7 @@ func (c *Config) migrate() {
 	if c.DefaultStackID == "" {
 		c.DefaultStackID = "io.buildpacks.stacks.bionic"
 	}
-	if c.DefaultBuilder == "" || c.DefaultBuilder == "packs/samples" {
+	if c.DefaultBuilder == "packs/samples" {
 		c.DefaultBuilder = "packs/samples:v3alpha2"
 	}
 
@@ -197,17 +242,112 @@ func (c *Config) SetDefaultBuilder(builder string) error {
 	return c.save()
 }
 
+// SuggestedBuilders returns the builders to choose from when no default builder is set: the trusted builders
+// followed by well-known builders
+func (c *Config) SuggestedBuilders() []string {
+	var builders []string
+	for _, b := range append(append([]string{}, c.TrustedBuilders...), suggestedBuilders...) {
+		found := false
+		for _, seen := range builders {
+			found = found || seen == b
+		}
+		if !found {
+			builders = append(builders, b)
+		}
+	}
+	return builders
+}
+
+// TrustBuilder lets builder run its buildpacks in the creator container, with the registry credentials of builds
+func (c *Config) TrustBuilder(builder string) error {
+	if c.IsTrustedBuilder(builder) {
+		return nil
+	}
+	c.TrustedBuilders = append(c.TrustedBuilders, builder)
+	return c.save()
+}
+
+// UntrustBuilder removes builder from the trusted builders
+func (c *Config) UntrustBuilder(builder string) error {
+	for i, b := range c.TrustedBuilders {
+		if b == builder {
+			c.TrustedBuilders = append(c.TrustedBuilders[:i], c.TrustedBuilders[i+1:]...)
+			return c.save()
+		}
+	}
+	return fmt.Errorf("builder %s is not trusted", style.Symbol(builder))
+}
+
+// IsTrustedBuilder reports whether builder may run its buildpacks in the creator container, with registry credentials.
+// The default builder is always trusted.
+func (c *Config) IsTrustedBuilder(builder string) bool {
+	if builder == c.DefaultBuilder {
+		return true
+	}
+	for _, b := range c.TrustedBuilders {
+		if b == builder {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageByRegistry returns the image from images hosted on registry, falling back to the first image.
+// Registries are compared after normalization, so docker.io and index.docker.io are equivalent and default
+// ports are ignored. Images may use a wildcard registry (e.g. *.gcr.io/org/repo), which is replaced by
+// registry when it matches and no image matches exactly.
 func ImageByRegistry(registry string, images []string) (string, error) {
+	registry = normalizeRegistry(registry)
+	wildcardMatch, fallback := "", ""
 	for _, i := range images {
+		if pattern, repo, ok := wildcardImage(i); ok {
+			if matched, err := path.Match(pattern, registry); err == nil && matched && wildcardMatch == "" {
+				wildcardMatch = registry + "/" + repo
+			}
+			continue
+		}
+		if fallback == "" {
+			fallback = i
+		}
 		reg, err := Registry(i)
 		if err != nil {
 			continue
 		}
-		if registry == reg {
+		if registry == normalizeRegistry(reg) {
 			return i, nil
 		}
 	}
-	return images[0], nil
+	if wildcardMatch != "" {
+		return wildcardMatch, nil
+	}
+	if fallback == "" {
+		return images[0], nil
+	}
+	return fallback, nil
+}
+
+var dockerHubAliases = map[string]bool{
+	"docker.io":               true,
+	"index.docker.io":         true,
+	"registry-1.docker.io":    true,
+	"registry.hub.docker.com": true,
+}
+
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimSuffix(strings.ToLower(registry), ":443")
+	if registry == "" || dockerHubAliases[registry] {
+		return name.DefaultRegistry
+	}
+	return registry
+}
+
+// wildcardImage splits an image with a wildcard registry into the registry pattern and the rest of the name
+func wildcardImage(image string) (pattern, repo string, ok bool) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], "*") {
+		return "", "", false
+	}
+	return normalizeRegistry(parts[0]), parts[1], true
 }
 
 func Registry(imageName string) (string, error) {
@@ -43,7 +43,7 @@ func testConfig(t *testing.T, when spec.G, it spec.S) {
 				b, err := ioutil.ReadFile(filepath.Join(tmpDir, "config.toml"))
 				h.AssertNil(t, err)
 				h.AssertContains(t, string(b), `default-stack-id = "io.buildpacks.stacks.bionic"`)
-				h.AssertContains(t, string(b), `default-builder = "packs/samples:v3alpha2"`)
+				h.AssertContains(t, string(b), `default-builder = ""`)
 				h.AssertContains(t, string(b), strings.TrimSpace(`
 [[stacks]]
   id = "io.buildpacks.stacks.bionic"
@@ -57,7 +57,7 @@ func testConfig(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, len(subject.Stacks[0].RunImages), 1)
 				h.AssertEq(t, subject.Stacks[0].RunImages[0], "packs/run:v3alpha2")
 				h.AssertEq(t, subject.DefaultStackID, "io.buildpacks.stacks.bionic")
-				h.AssertEq(t, subject.DefaultBuilder, "packs/samples:v3alpha2")
+				h.AssertEq(t, subject.DefaultBuilder, "")
 			})
 
 			when("path is missing", func() {
@@ -335,6 +335,50 @@ default-stack-id = "old/builder"
 		})
 	})
 
+	when("Config#SuggestedBuilders", func() {
+		it("suggests the trusted builders before the well-known builders", func() {
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "config.toml"), []byte(`
+trusted-builders = ["some/builder", "packs/samples:v3alpha2"]
+`), 0666))
+			subject, err := config.New(tmpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, subject.SuggestedBuilders(), []string{"some/builder", "packs/samples:v3alpha2", "cloudfoundry/cnb:bionic"})
+		})
+	})
+
+	when("Config#TrustBuilder", func() {
+		var subject *config.Config
+		it.Before(func() {
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "config.toml"), []byte(`
+default-builder = "default/builder"
+trusted-builders = ["some/builder"]
+`), 0666))
+			var err error
+			subject, err = config.New(tmpDir)
+			h.AssertNil(t, err)
+		})
+
+		it("trusts the default builder and the trusted builders", func() {
+			h.AssertEq(t, subject.IsTrustedBuilder("default/builder"), true)
+			h.AssertEq(t, subject.IsTrustedBuilder("some/builder"), true)
+			h.AssertEq(t, subject.IsTrustedBuilder("other/builder"), false)
+		})
+
+		it("adds the builder to the trusted builders", func() {
+			h.AssertNil(t, subject.TrustBuilder("other/builder"))
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "config.toml"))
+			h.AssertNil(t, err)
+			h.AssertContains(t, string(b), `trusted-builders = ["some/builder", "other/builder"]`)
+			h.AssertEq(t, subject.IsTrustedBuilder("other/builder"), true)
+		})
+
+		it("removes the builder from the trusted builders", func() {
+			h.AssertNil(t, subject.UntrustBuilder("some/builder"))
+			h.AssertEq(t, subject.IsTrustedBuilder("some/builder"), false)
+			h.AssertError(t, subject.UntrustBuilder("some/builder"), "builder 'some/builder' is not trusted")
+		})
+	})
+
 	when("Config#Add", func() {
 		var subject *config.Config
 		it.Before(func() {
@@ -570,6 +614,60 @@ default-stack-id = "stack-1"
 			})
 		})
 
+		when("registry is an alias of dockerhub", func() {
+			it("returns the dockerhub image", func() {
+				for _, registry := range []string{"docker.io", "registry-1.docker.io", "Index.Docker.io:443"} {
+					name, err := config.ImageByRegistry(registry, images)
+					h.AssertNil(t, err)
+					h.AssertEq(t, name, "myorg/myrepo")
+				}
+			})
+
+			it("matches images using an explicit dockerhub registry or library namespace", func() {
+				name, err := config.ImageByRegistry("index.docker.io", []string{"gcr.io/org/repo", "docker.io/library/ubuntu"})
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "docker.io/library/ubuntu")
+			})
+		})
+
+		when("registry has a port", func() {
+			it("matches images on the same port", func() {
+				name, err := config.ImageByRegistry("localhost:5000", []string{"localhost/org/repo", "localhost:5000/org/repo"})
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "localhost:5000/org/repo")
+			})
+
+			it("ignores the default https port", func() {
+				name, err := config.ImageByRegistry("gcr.io:443", images)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "gcr.io/org/repo")
+			})
+		})
+
+		when("an image uses a wildcard registry", func() {
+			it.Before(func() {
+				images = []string{"first.com/org/repo", "*.gcr.io/org/repo", "eu.gcr.io/org/eu-repo"}
+			})
+
+			it("prefers an exact match", func() {
+				name, err := config.ImageByRegistry("eu.gcr.io", images)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "eu.gcr.io/org/eu-repo")
+			})
+
+			it("substitutes the registry when the wildcard matches", func() {
+				name, err := config.ImageByRegistry("us.gcr.io", images)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "us.gcr.io/org/repo")
+			})
+
+			it("falls back to the first image that isn't a wildcard", func() {
+				name, err := config.ImageByRegistry("quay.io", []string{"*.gcr.io/org/repo", "first.com/org/repo"})
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "first.com/org/repo")
+			})
+		})
+
 		when("one of the images is non-parsable", func() {
 			it.Before(func() {
 				images = []string{"as@ohd@as@op", "gcr.io/myorg/myrepo"}
@@ -1,17 +1,19 @@
 package pack
 
 import (
-	"compress/gzip"
+	"bytes"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/buildpack/lifecycle"
@@ -31,8 +33,13 @@ type BuilderConfig struct {
 	Groups     []lifecycle.BuildpackGroup
 	Repo       image.Image
 	BuilderDir string //original location of builder.toml, used for interpreting relative paths in buildpack URIs
+	RepoName   string // name of the builder, whose previous image layers are reused when unchanged
+	Publish    bool
 }
 
+// BuilderLayersLabel records the diff ID of each layer added by create-builder, keyed by the content it was generated from
+const BuilderLayersLabel = "io.buildpacks.pack.builder.layers"
+
 type BuilderFactory struct {
 	Logger       *logging.Logger
 	FS           FS
@@ -54,7 +61,10 @@ func (f *BuilderFactory) BuilderConfigFromFlags(flags CreateBuilderFlags) (Build
 		return BuilderConfig{}, err
 	}
 
-	builderConfig := BuilderConfig{}
+	builderConfig := BuilderConfig{
+		RepoName: flags.RepoName,
+		Publish:  flags.Publish,
+	}
 	builderConfig.BuilderDir = filepath.Dir(flags.BuilderTomlPath)
 	if flags.Publish {
 		builderConfig.Repo, err = f.ImageFactory.NewRemote(baseImage)
@@ -111,7 +121,7 @@ func (f *BuilderFactory) resolveBuildpackURI(builderDir string, b Buildpack) (Bu
 			if err != nil {
 				return Buildpack{}, fmt.Errorf(`failed to create temporary directory: %s`, err)
 			}
-			if err = f.untarZ(file, tmpDir); err != nil {
+			if err = untarZ(f.FS, file, tmpDir); err != nil {
 				return Buildpack{}, err
 			}
 			dir = tmpDir
@@ -119,38 +129,10 @@ func (f *BuilderFactory) resolveBuildpackURI(builderDir string, b Buildpack) (Bu
 			dir = path
 		}
 	case "http", "https":
-		uriDigest := fmt.Sprintf("%x", sha256.Sum256([]byte(b.URI)))
-		cachedDir := filepath.Join(f.Config.Path(), "dl-cache", uriDigest)
-		_, err := os.Stat(cachedDir)
-		if os.IsNotExist(err) {
-			if err = os.MkdirAll(cachedDir, 0744); err != nil {
-				return Buildpack{}, err
-			}
-		}
-		etagFile := cachedDir + ".etag"
-		bytes, err := ioutil.ReadFile(etagFile)
-		etag := ""
-		if err == nil {
-			etag = string(bytes)
-		}
-
-		reader, etag, err := f.downloadAsStream(b.URI, etag)
+		dir, err = downloadAndExtract(f.Logger, f.FS, filepath.Join(f.Config.Path(), "dl-cache"), b.URI)
 		if err != nil {
-			return Buildpack{}, errors.Wrapf(err, "failed to download from %q", b.URI)
-		} else if reader == nil {
-			// can use cached content
-			dir = cachedDir
-			break
-		} else {
-			if err = f.untarZ(reader, cachedDir); err != nil {
-				return Buildpack{}, err
-			}
-			if err = ioutil.WriteFile(etagFile, []byte(etag), 0744); err != nil {
-				return Buildpack{}, err
-			}
+			return Buildpack{}, err
 		}
-
-		dir = cachedDir
 	default:
 		return Buildpack{}, fmt.Errorf("unsupported protocol in URI %q", b.URI)
 	}
@@ -177,20 +159,35 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	orderTar, err := f.orderLayer(tmpDir, config.Groups)
+	layers := &builderLayers{
+		image:    config.Repo,
+		logger:   f.Logger,
+		previous: f.previousBuilderLayers(config),
+		current:  map[string]string{},
+	}
+
+	orderKey, err := orderLayerKey(config.Groups)
 	if err != nil {
 		return fmt.Errorf(`failed generate order.toml layer: %s`, err)
 	}
-	if err := config.Repo.AddLayer(orderTar); err != nil {
-		return fmt.Errorf(`failed append order.toml layer to image: %s`, err)
+	if err := layers.add(orderKey, func() (string, error) {
+		return f.orderLayer(tmpDir, config.Groups)
+	}); err != nil {
+		return fmt.Errorf(`failed to add order.toml layer to image: %s`, err)
 	}
-	for _, buildpack := range config.Buildpacks {
-		tarFile, err := f.buildpackLayer(tmpDir, buildpack, config.BuilderDir)
-		if err != nil {
-			return fmt.Errorf(`failed to generate layer for buildpack %s: %s`, style.Symbol(buildpack.ID), err)
+	prepared := f.prepareBuildpackLayers(tmpDir, config, layers.previous)
+	for i, buildpack := range config.Buildpacks {
+		buildpack, layer := buildpack, prepared[i]
+		if layer.err != nil {
+			return fmt.Errorf(`failed to generate layer for buildpack %s: %s`, style.Symbol(buildpack.ID), layer.err)
 		}
-		if err := config.Repo.AddLayer(tarFile); err != nil {
-			return fmt.Errorf(`failed append buildpack layer to image: %s`, err)
+		if err := layers.add(layer.key, func() (string, error) {
+			if layer.tarFile != "" {
+				return layer.tarFile, nil
+			}
+			return f.buildpackLayer(layer.dir, buildpack, config.BuilderDir)
+		}); err != nil {
+			return fmt.Errorf(`failed to add layer for buildpack %s to image: %s`, style.Symbol(buildpack.ID), err)
 		}
 	}
 	tarFile, err := f.latestLayer(config.Buildpacks, tmpDir, config.BuilderDir)
@@ -201,12 +198,207 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 		return fmt.Errorf(`failed append latest link layer to image: %s`, err)
 	}
 
+	if err := layers.record(); err != nil {
+		return err
+	}
 	if _, err := config.Repo.Save(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// previousBuilderLayers reads the layers recorded on the previous image of the builder, if any
+func (f *BuilderFactory) previousBuilderLayers(config BuilderConfig) map[string]string {
+	if config.RepoName == "" {
+		return nil
+	}
+	var previous image.Image
+	var err error
+	if config.Publish {
+		previous, err = f.ImageFactory.NewRemote(config.RepoName)
+	} else {
+		previous, err = f.ImageFactory.NewLocal(config.RepoName, false)
+	}
+	if err != nil {
+		f.Logger.Verbose("Not reusing layers from previous builder: %s", err)
+		return nil
+	}
+	if found, err := previous.Found(); err != nil || !found {
+		return nil
+	}
+	label, err := previous.Label(BuilderLayersLabel)
+	if err != nil || label == "" {
+		return nil
+	}
+	layers := map[string]string{}
+	if err := json.Unmarshal([]byte(label), &layers); err != nil {
+		f.Logger.Verbose("Not reusing layers from previous builder: invalid label %s: %s", style.Symbol(BuilderLayersLabel), err)
+		return nil
+	}
+	return layers
+}
+
+// builderLayers adds layers to a builder image, reusing those of the previous builder image when their key is unchanged
+type builderLayers struct {
+	image    image.Image
+	logger   *logging.Logger
+	previous map[string]string
+	current  map[string]string
+}
+
+func (l *builderLayers) add(key string, create func() (string, error)) error {
+	if diffID, ok := l.previous[key]; ok {
+		if err := l.image.ReuseLayer(diffID); err == nil {
+			l.logger.Verbose("Reusing unchanged layer %s", style.Symbol(key))
+			l.current[key] = diffID
+			return nil
+		}
+	}
+	tarFile, err := create()
+	if err != nil {
+		return err
+	}
+	diffID, err := fileDigest(tarFile)
+	if err != nil {
+		return err
+	}
+	if err := l.image.AddLayer(tarFile); err != nil {
+		return err
+	}
+	l.current[key] = diffID
+	return nil
+}
+
+func (l *builderLayers) record() error {
+	label, err := json.Marshal(l.current)
+	if err != nil {
+		return err
+	}
+	return l.image.SetLabel(BuilderLayersLabel, string(label))
+}
+
+func orderLayerKey(groups []lifecycle.BuildpackGroup) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(order{Groups: groups}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("order:%x", sha256.Sum256(buf.Bytes())), nil
+}
+
+func (f *BuilderFactory) buildpackLayerKey(buildpack Buildpack) (string, error) {
+	version, err := f.buildpackVersion(buildpack)
+	if err != nil {
+		return "", err
+	}
+	hash, err := dirDigest(buildpack.Dir)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%s:%s", buildpack.ID, version, hash), nil
+}
+
+type preparedBuildpackLayer struct {
+	key     string
+	dir     string
+	tarFile string
+	err     error
+}
+
+// prepareBuildpackLayers concurrently generates the layer of each buildpack, skipping those expected to be
+// reused from the previous builder image. Results are returned in the order of config.Buildpacks.
+func (f *BuilderFactory) prepareBuildpackLayers(dest string, config BuilderConfig, previous map[string]string) []preparedBuildpackLayer {
+	prepared := make([]preparedBuildpackLayer, len(config.Buildpacks))
+	workers := runtime.NumCPU()
+	if workers > len(config.Buildpacks) {
+		workers = len(config.Buildpacks)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				prepared[i] = f.prepareBuildpackLayer(dest, config.Buildpacks[i], config.BuilderDir, previous)
+			}
+		}()
+	}
+	for i := range config.Buildpacks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return prepared
+}
+
+func (f *BuilderFactory) prepareBuildpackLayer(dest string, buildpack Buildpack, builderDir string, previous map[string]string) preparedBuildpackLayer {
+	key, err := f.buildpackLayerKey(buildpack)
+	if err != nil {
+		return preparedBuildpackLayer{err: err}
+	}
+	// each buildpack gets its own directory, buildpacks sharing an ID and version would otherwise race on the same tar file
+	dir, err := ioutil.TempDir(dest, "buildpack")
+	if err != nil {
+		return preparedBuildpackLayer{err: err}
+	}
+	if _, ok := previous[key]; ok {
+		return preparedBuildpackLayer{key: key, dir: dir}
+	}
+	tarFile, err := f.buildpackLayer(dir, buildpack, builderDir)
+	return preparedBuildpackLayer{key: key, dir: dir, tarFile: tarFile, err: err}
+}
+
+// dirDigest hashes the names, modes, contents and link targets of every file in dir
+func dirDigest(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00", target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(h, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
 type order struct {
 	Groups []lifecycle.BuildpackGroup `toml:"groups"`
 }
@@ -245,9 +437,21 @@ type BuildpackData struct {
 // The tgz file is either created from an initially local directory, or it is downloaded (and validated) from
 // a remote location if the buildpack uri uses the http(s) protocol.
 func (f *BuilderFactory) buildpackLayer(dest string, buildpack Buildpack, builderDir string) (layerTar string, err error) {
-	dir := buildpack.Dir
+	version, err := f.buildpackVersion(buildpack)
+	if err != nil {
+		return "", err
+	}
+
+	tarFile := filepath.Join(dest, fmt.Sprintf("%s.%s.tar", buildpack.escapedID(), version))
+	if err := f.FS.CreateTarFile(tarFile, buildpack.Dir, filepath.Join("/buildpacks", buildpack.escapedID(), version), 0, 0); err != nil {
+		return "", err
+	}
+	return tarFile, err
+}
 
-	data, err := f.buildpackData(buildpack, dir)
+// buildpackVersion reads the version from buildpack.toml, checking it describes the expected buildpack
+func (f *BuilderFactory) buildpackVersion(buildpack Buildpack) (string, error) {
+	data, err := f.buildpackData(buildpack, buildpack.Dir)
 	if err != nil {
 		return "", err
 	}
@@ -258,12 +462,7 @@ func (f *BuilderFactory) buildpackLayer(dest string, buildpack Buildpack, builde
 	if bp.Version == "" {
 		return "", fmt.Errorf("buildpack.toml must provide version: %s", filepath.Join(buildpack.Dir, "buildpack.toml"))
 	}
-
-	tarFile := filepath.Join(dest, fmt.Sprintf("%s.%s.tar", buildpack.escapedID(), bp.Version))
-	if err := f.FS.CreateTarFile(tarFile, dir, filepath.Join("/buildpacks", buildpack.escapedID(), bp.Version), 0, 0); err != nil {
-		return "", err
-	}
-	return tarFile, err
+	return bp.Version, nil
 }
 
 func (f *BuilderFactory) buildpackData(buildpack Buildpack, dir string) (*BuildpackData, error) {
@@ -275,15 +474,6 @@ func (f *BuilderFactory) buildpackData(buildpack Buildpack, dir string) (*Buildp
 	return data, nil
 }
 
-func (f *BuilderFactory) untarZ(r io.Reader, dir string) error {
-	gzr, err := gzip.NewReader(r)
-	if err != nil {
-		return errors.Wrapf(err, "could not unzip")
-	}
-	defer gzr.Close()
-	return f.FS.Untar(gzr, dir)
-}
-
 func (f *BuilderFactory) latestLayer(buildpacks []Buildpack, dest, builderDir string) (string, error) {
 	tmpDir, err := ioutil.TempDir(dest, "create-builder-latest")
 	if err != nil {
@@ -311,27 +501,3 @@ func (f *BuilderFactory) latestLayer(buildpacks []Buildpack, dest, builderDir st
 	}
 	return tarFile, err
 }
-
-func (f *BuilderFactory) downloadAsStream(uri string, etag string) (io.Reader, string, error) {
-	c := http.Client{}
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, "", err
-	}
-	if etag != "" {
-		req.Header.Set("If-None-Match", etag)
-	}
-	if resp, err := c.Do(req); err != nil {
-		return nil, "", err
-	} else {
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			f.Logger.Verbose("Downloading from %q\n", uri)
-			return resp.Body, resp.Header.Get("Etag"), nil
-		} else if resp.StatusCode == 304 {
-			f.Logger.Verbose("Using cached version of %q\n", uri)
-			return nil, etag, nil
-		} else {
-			return nil, "", fmt.Errorf("could not download from %q, code http status %d", uri, resp.StatusCode)
-		}
-	}
-}
@@ -195,6 +195,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 				it("returns no errors", func() {
 					mockImage := mocks.NewMockImage(mockController)
 					mockImage.EXPECT().AddLayer(gomock.Any()).AnyTimes()
+					mockImage.EXPECT().SetLabel(pack.BuilderLayersLabel, gomock.Any())
 					mockImage.EXPECT().Save()
 
 					err := factory.Create(pack.BuilderConfig{
@@ -206,6 +207,91 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 					h.AssertNil(t, err)
 				})
 			})
+
+			when("there are many buildpacks", func() {
+				it("appends their layers in the order of builder.toml", func() {
+					var buildpacks []pack.Buildpack
+					for i := 0; i < 8; i++ {
+						bpDir, err := ioutil.TempDir("", "create-builder-bp")
+						h.AssertNil(t, err)
+						id := fmt.Sprintf("some.bp%d", i)
+						h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(fmt.Sprintf(`[buildpack]
+id = "%s"
+version = "1.2.3"
+name = "Some Buildpack"
+`, id)), 0644))
+						buildpacks = append(buildpacks, pack.Buildpack{ID: id, Dir: bpDir})
+					}
+
+					var added []string
+					mockImage := mocks.NewMockImage(mockController)
+					mockImage.EXPECT().AddLayer(gomock.Any()).Do(func(path string) {
+						added = append(added, filepath.Base(path))
+					}).AnyTimes()
+					mockImage.EXPECT().SetLabel(pack.BuilderLayersLabel, gomock.Any())
+					mockImage.EXPECT().Save()
+
+					h.AssertNil(t, factory.Create(pack.BuilderConfig{
+						Repo:       mockImage,
+						Buildpacks: buildpacks,
+						Groups:     []lifecycle.BuildpackGroup{},
+					}))
+
+					h.AssertEq(t, len(added), len(buildpacks)+2)
+					for i, bp := range buildpacks {
+						h.AssertEq(t, added[i+1], fmt.Sprintf("%s.1.2.3.tar", bp.ID))
+					}
+				})
+			})
+
+			when("the previous builder image has unchanged layers", func() {
+				var builderConfig pack.BuilderConfig
+
+				it.Before(func() {
+					bpDir, err := ioutil.TempDir("", "create-builder-bp")
+					h.AssertNil(t, err)
+					h.AssertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(`[buildpack]
+id = "some.bp1"
+version = "1.2.3"
+name = "Some Buildpack"
+`), 0644))
+
+					builderConfig = pack.BuilderConfig{
+						Buildpacks: []pack.Buildpack{{ID: "some.bp1", Dir: bpDir}},
+						Groups: []lifecycle.BuildpackGroup{{
+							Buildpacks: []*lifecycle.Buildpack{{ID: "some.bp1", Version: "1.2.3"}},
+						}},
+						RepoName: "some/builder",
+					}
+				})
+
+				it("reuses them instead of adding new layers", func() {
+					var label string
+					firstImage := mocks.NewMockImage(mockController)
+					previousImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewLocal("some/builder", false).Return(previousImage, nil).Times(2)
+					previousImage.EXPECT().Found().Return(false, nil)
+					firstImage.EXPECT().AddLayer(gomock.Any()).Times(3)
+					firstImage.EXPECT().SetLabel(pack.BuilderLayersLabel, gomock.Any()).Do(func(_, value string) {
+						label = value
+					})
+					firstImage.EXPECT().Save()
+
+					builderConfig.Repo = firstImage
+					h.AssertNil(t, factory.Create(builderConfig))
+
+					secondImage := mocks.NewMockImage(mockController)
+					previousImage.EXPECT().Found().Return(true, nil)
+					previousImage.EXPECT().Label(pack.BuilderLayersLabel).Return(label, nil)
+					secondImage.EXPECT().ReuseLayer(gomock.Any()).Times(2)
+					secondImage.EXPECT().AddLayer(gomock.Any()).Times(1)
+					secondImage.EXPECT().SetLabel(pack.BuilderLayersLabel, label)
+					secondImage.EXPECT().Save()
+
+					builderConfig.Repo = secondImage
+					h.AssertNil(t, factory.Create(builderConfig))
+				})
+			})
 		})
 		when("a buildpack location uses no scheme uris", func() {
 			it("supports relative directories as well as archives", func() {
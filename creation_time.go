@@ -0,0 +1,153 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// SourceDateEpochEnv is the variable of https://reproducible-builds.org/specs/source-date-epoch/, the default
+// of --creation-time. Buildpacks are given it as well, so the tools they run can produce reproducible output.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// parseCreationTime parses a creation time given as seconds since the Unix epoch or in RFC 3339 format
+func parseCreationTime(creationTime string) (*time.Time, error) {
+	if creationTime == "" {
+		return nil, nil
+	}
+	if secs, err := strconv.ParseInt(creationTime, 10, 64); err == nil {
+		t := time.Unix(secs, 0).UTC()
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, creationTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid creation time %s: must be seconds since the Unix epoch or of the form '2006-01-02T15:04:05Z'", style.Symbol(creationTime))
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// SetCreationTime sets the created time of the exported image to CreationTime, so builds of the same source
+// produce the same image. The layers pack writes have fixed modification times already.
+func (b *BuildConfig) SetCreationTime() error {
+	created := v1.Time{Time: *b.CreationTime}
+	if b.Publish {
+		return b.setRemoteCreationTime(created)
+	}
+	return b.setDaemonCreationTime(created)
+}
+
+func (b *BuildConfig) setRemoteCreationTime(created v1.Time) error {
+	ref, err := name.ParseReference(b.RepoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return errors.Wrapf(err, "reading image %s", style.Symbol(b.RepoName))
+	}
+	if img, err = mutate.CreatedAt(img, created); err != nil {
+		return err
+	}
+	auth, err := authn.DefaultKeychain.Resolve(ref.Context().Registry)
+	if err != nil {
+		return err
+	}
+	if err := remote.Write(ref, img, auth, http.DefaultTransport); err != nil {
+		return errors.Wrapf(err, "publishing image %s", style.Symbol(b.RepoName))
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	b.Identifier, err = remoteDigestReference(b.RepoName, digest.String())
+	return err
+}
+
+// setDaemonCreationTime replaces the image on the daemon with a copy that has the created time: the daemon
+// can't change the config of an image, which is part of its ID
+func (b *BuildConfig) setDaemonCreationTime(created v1.Time) error {
+	ctx := b.context()
+	tag, err := name.NewTag(b.RepoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	img, cleanup, err := b.savedImage(ctx, b.RepoName)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if img, err = mutate.CreatedAt(img, created); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.Write(tag, img, nil, pw))
+	}()
+	resp, err := b.Cli.ImageLoad(ctx, pr, true)
+	pr.CloseWithError(err)
+	if err != nil {
+		return errors.Wrapf(err, "loading image %s", style.Symbol(b.RepoName))
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return errors.Wrapf(err, "loading image %s", style.Symbol(b.RepoName))
+	}
+	b.Identifier, err = localImageID(ctx, b.Cli, b.RepoName)
+	return err
+}
+
+// savedImage saves the image repoName on the daemon to a temporary file, which cleanup removes once the
+// returned image has been read
+func (b *BuildConfig) savedImage(ctx context.Context, repoName string) (v1.Image, func(), error) {
+	tag, err := name.NewTag(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, nil, err
+	}
+	saved, err := ioutil.TempFile("", "pack.image.")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		saved.Close()
+		os.Remove(saved.Name())
+	}
+	rc, err := b.Cli.ImageSave(ctx, []string{repoName})
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrapf(err, "saving image %s", style.Symbol(repoName))
+	}
+	_, err = io.Copy(saved, rc)
+	rc.Close()
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrapf(err, "saving image %s", style.Symbol(repoName))
+	}
+	if err := saved.Close(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	img, err := tarball.ImageFromPath(saved.Name(), &tag)
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrapf(err, "reading image %s", style.Symbol(repoName))
+	}
+	return img, cleanup, nil
+}
@@ -0,0 +1,130 @@
+package pack
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// useCreator reports whether the build runs in a single creator container. The creator must be
+// requested, provided by the lifecycle, and run by a trusted builder outside the sandbox as it runs the
+// buildpacks with the registry credentials. As pack exports images to the daemon itself, it only publishes images.
+func (b *BuildConfig) useCreator() (bool, error) {
+	if !b.Creator || b.DetectOnly {
+		return false, nil
+	}
+	if !b.Publish {
+		b.Logger.Warn("The creator can only publish images, running the lifecycle phases in separate containers")
+		return false, nil
+	}
+	if b.Sandbox {
+		b.Logger.Warn("The creator cannot run sandboxed, running the lifecycle phases in separate containers")
+		return false, nil
+	}
+	if b.StopAfter != "" {
+		b.Logger.Warn("The creator cannot stop after a phase, running the lifecycle phases in separate containers")
+		return false, nil
+	}
+	if !b.TrustBuilder {
+		b.Logger.Warn("Builder %s is not trusted, running the lifecycle phases in separate containers", style.Symbol(b.Builder))
+		return false, nil
+	}
+	ok, err := b.lifecycleHasCreator()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		b.Logger.Warn("The lifecycle of %s does not provide a creator, running the lifecycle phases in separate containers", style.Symbol(b.Builder))
+	}
+	return ok, nil
+}
+
+// lifecycleHasCreator reports whether the lifecycle used for the build provides the creator
+func (b *BuildConfig) lifecycleHasCreator() (bool, error) {
+	ctx := b.context()
+	if err := b.prepareLifecycleVolume(ctx); err != nil {
+		return false, err
+	}
+	hostConfig := &container.HostConfig{}
+	if b.lifecycleVolume != "" {
+		hostConfig.Binds = []string{fmt.Sprintf("%s:%s:ro", b.lifecycleVolume, lifecycleDir)}
+	}
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{Image: b.Builder}, hostConfig, nil, "")
+	if err != nil {
+		return false, errors.Wrap(err, "create lifecycle container")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctr.ID, lifecycleDir+"/creator")
+	if err != nil {
+		return false, nil
+	}
+	rc.Close()
+	return true, nil
+}
+
+// Create runs detect, restore, analyze, build, export and cache in a single creator container,
+// saving the container startups between the phases
+func (b *BuildConfig) Create() error {
+	ctx := b.context()
+	if err := b.prepareVolumes(ctx); err != nil {
+		return err
+	}
+
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
+	ctrConf := &container.Config{
+		Image: b.phaseImage(),
+		Env:   append(b.proxyEnv(), b.caCertsEnv()...),
+		Cmd: []string{
+			"/lifecycle/creator",
+			"-app", launchDir + "/app",
+			"-buildpacks", buildpacksDir,
+			"-order", orderPath,
+			"-group", groupPath,
+			"-plan", planPath,
+			"-layers", launchDir,
+			"-platform", platformDir,
+			b.platform().CacheDirFlag, layersCacheDir,
+			b.platform().RunImageFlag, b.RunImage,
+			"-uid", strconv.Itoa(uid),
+			"-gid", strconv.Itoa(gid),
+		},
+		User: fmt.Sprintf("%d:%d", uid, gid),
+	}
+	hostConfig := &container.HostConfig{
+		Binds:       b.buildpackBinds(),
+		Resources:   b.Resources,
+		NetworkMode: b.registryNetworkMode(),
+	}
+	if b.PreviousImage != "" {
+		ctrConf.Cmd = append(ctrConf.Cmd, "-previous-image", b.PreviousImage)
+	}
+	authHeader, err := authHeader(b.RepoName)
+	if err != nil {
+		return err
+	}
+	ctrConf.Env = append(ctrConf.Env, fmt.Sprintf(`PACK_REGISTRY_AUTH=%s`, authHeader))
+	ctrConf.Cmd = append(ctrConf.Cmd, b.RepoName)
+
+	ctr, err := b.Cli.ContainerCreate(ctx, ctrConf, hostConfig, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create creator container")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	if err := b.copyBuildInputs(ctx, ctr.ID); err != nil {
+		return err
+	}
+
+	if err := b.runPhase(ctx, ctr.ID, "creator"); err != nil {
+		return errors.Wrap(err, "run lifecycle/creator")
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/image"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// The lifecycle is never given the docker socket. Instead of running the analyzer and exporter with
+// -daemon, pack reads the previous image from the daemon and writes the new one itself, using the
+// layers the build left in the workspace volume.
+
+const lifecycleMetadataLabel = "io.buildpacks.lifecycle.metadata"
+
+// layerTOML is the <layer>.toml a buildpack writes next to each of its layer dirs
+type layerTOML struct {
+	Build    bool        `toml:"build"`
+	Launch   bool        `toml:"launch"`
+	Cache    bool        `toml:"cache"`
+	Metadata interface{} `toml:"metadata,omitempty"`
+}
+
+// buildpackMetadata returns the metadata of the buildpack id in the metadata of an app image, or nil
+func buildpackMetadata(metadata *lifecycle.AppImageMetadata, id string) *lifecycle.BuildpackMetadata {
+	for i := range metadata.Buildpacks {
+		if metadata.Buildpacks[i].ID == id {
+			return &metadata.Buildpacks[i]
+		}
+	}
+	return nil
+}
+
+// readDaemonMetadata returns the lifecycle metadata of an image on the daemon, or nil when the image
+// doesn't exist or has no valid metadata
+func (b *BuildConfig) readDaemonMetadata(repoName string) (*lifecycle.AppImageMetadata, error) {
+	img, err := b.ImageFactory.NewLocal(repoName, false)
+	if err != nil {
+		return nil, err
+	}
+	if found, err := img.Found(); err != nil {
+		return nil, err
+	} else if !found {
+		b.Logger.Verbose("Image %s not found on the daemon, not reusing layers", style.Symbol(repoName))
+		return nil, nil
+	}
+	label, err := img.Label(lifecycleMetadataLabel)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		return nil, nil
+	}
+	var metadata lifecycle.AppImageMetadata
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		b.Logger.Verbose("Not reusing layers of %s: invalid label %s: %s", style.Symbol(repoName), style.Symbol(lifecycleMetadataLabel), err)
+		return nil, nil
+	}
+	return &metadata, nil
+}
+
+// readGroup reads the buildpack group chosen by the detector from the workspace volume through the container ctrID
+func (b *BuildConfig) readGroup(ctx context.Context, ctrID string) (lifecycle.BuildpackGroup, error) {
+	var group lifecycle.BuildpackGroup
+	txt, err := b.readWorkspaceFile(ctx, ctrID, groupPath)
+	if err != nil {
+		return group, err
+	}
+	if _, err := toml.Decode(string(txt), &group); err != nil {
+		return group, errors.Wrapf(err, "decoding %s", style.Symbol(groupPath))
+	}
+	return group, nil
+}
+
+// analyzeFromDaemon does the analyzer's work for images on the daemon: it writes the <layer>.toml of every
+// layer the buildpacks of the group contributed to the previous image, so buildpacks can reuse them
+func (b *BuildConfig) analyzeFromDaemon(ctx context.Context) error {
+	metadata, err := b.readDaemonMetadata(b.analyzedImage())
+	if err != nil {
+		return errors.Wrapf(err, "reading previous image %s", style.Symbol(b.analyzedImage()))
+	}
+	if metadata == nil {
+		return nil
+	}
+
+	ctrID, err := createCacheContainer(ctx, b.Cli, b.CacheVolume, b.phaseImage())
+	if err != nil {
+		return err
+	}
+	defer b.removeContainer(ctrID)
+	group, err := b.readGroup(ctx, ctrID)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, bp := range group.Buildpacks {
+		previous := buildpackMetadata(metadata, bp.ID)
+		if previous == nil {
+			continue
+		}
+		bpDir := path.Join(launchDir, (&Buildpack{ID: bp.ID}).escapedID())
+		if err := writeTarDir(tw, bpDir, uid, gid); err != nil {
+			return err
+		}
+		for name, layer := range previous.Layers {
+			var contents bytes.Buffer
+			err := toml.NewEncoder(&contents).Encode(layerTOML{Build: layer.Build, Launch: layer.Launch, Cache: layer.Cache, Metadata: layer.Data})
+			if err != nil {
+				return errors.Wrapf(err, "encoding metadata of layer %s", style.Symbol(bp.ID+"/"+name))
+			}
+			if err := writeTarFile(tw, path.Join(bpDir, name+".toml"), contents.Bytes(), uid, gid); err != nil {
+				return err
+			}
+			b.Logger.Verbose("Restored metadata of layer %s from %s", style.Symbol(bp.ID+"/"+name), style.Symbol(b.analyzedImage()))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", &buf, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrap(err, "restoring layer metadata")
+	}
+	return nil
+}
+
+// exportToDaemon does the exporter's work for images on the daemon: it saves the app image to the daemon
+// from the run image and the app, config, launcher and launch layers in the workspace volume. Layers
+// whose contents are unchanged, or that buildpacks kept without restoring, are reused from the previous
+// image of the same name. Only these layers are copied from the volume, which also holds the app
+// source and the layers cache.
+func (b *BuildConfig) exportToDaemon(ctx context.Context) error {
+	previous, err := b.readDaemonMetadata(b.RepoName)
+	if err != nil {
+		return errors.Wrapf(err, "reading previous image %s", style.Symbol(b.RepoName))
+	}
+	if previous == nil {
+		previous = &lifecycle.AppImageMetadata{}
+	}
+
+	img, err := b.ImageFactory.NewLocal(b.RunImage, false)
+	if err != nil {
+		return err
+	}
+	var metadata lifecycle.AppImageMetadata
+	if metadata.RunImage.TopLayer, err = img.TopLayer(); err != nil {
+		return errors.Wrapf(err, "get run image %s top layer", style.Symbol(b.RunImage))
+	}
+	if metadata.RunImage.SHA, err = img.Digest(); err != nil {
+		return errors.Wrapf(err, "get run image %s digest", style.Symbol(b.RunImage))
+	}
+	img.Rename(b.RepoName)
+
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image: b.phaseImage(),
+		Cmd:   []string{"/bin/sh", "-c", listLayersScript},
+	}, &container.HostConfig{
+		Binds:       b.phaseBinds(),
+		Resources:   b.Resources,
+		NetworkMode: container.NetworkMode(b.Network),
+	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create export container")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	group, err := b.readGroup(ctx, ctr.ID)
+	if err != nil {
+		return err
+	}
+	var list bytes.Buffer
+	if err := b.Cli.RunContainer(ctx, ctr.ID, &list, b.Logger.VerboseErrorWriter()); err != nil {
+		return errors.Wrap(err, "listing layers")
+	}
+	entries := strings.FieldsFunc(list.String(), func(r rune) bool { return r == 0 })
+	sort.Strings(entries)
+	layerDirs := map[string]bool{}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "/") {
+			layerDirs[strings.TrimSuffix(entry, "/")] = true
+		}
+	}
+	uid, gid, err := b.packUidGid(b.Builder)
+	if err != nil {
+		return errors.Wrap(err, "get pack uid and gid")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "pack.export.")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, layer := range []struct {
+		name string
+		sha  *string
+	}{
+		{"app", &metadata.App.SHA},
+		{"config", &metadata.Config.SHA},
+	} {
+		tarFile, err := b.copyLayer(ctx, ctr.ID, path.Join(launchDir, layer.name), tmpDir, uid, gid)
+		if err != nil {
+			return errors.Wrapf(err, "reading %s layer", layer.name)
+		}
+		if *layer.sha, err = addDaemonLayer(img, tarFile, ""); err != nil {
+			return errors.Wrapf(err, "add %s layer", layer.name)
+		}
+	}
+
+	for _, bp := range group.Buildpacks {
+		bpMetadata := lifecycle.BuildpackMetadata{ID: bp.ID, Layers: map[string]lifecycle.LayerMetadata{}}
+		prevLayers := map[string]lifecycle.LayerMetadata{}
+		if prev := buildpackMetadata(previous, bp.ID); prev != nil {
+			prevLayers = prev.Layers
+		}
+		bpDir := path.Join(launchDir, (&Buildpack{ID: bp.ID}).escapedID())
+		for _, entry := range entries {
+			if path.Dir(entry) != bpDir || !strings.HasSuffix(entry, ".toml") {
+				continue
+			}
+			name := strings.TrimSuffix(path.Base(entry), ".toml")
+			contents, err := b.readWorkspaceFile(ctx, ctr.ID, entry)
+			if err != nil {
+				return err
+			}
+			var layer layerTOML
+			if _, err := toml.Decode(string(contents), &layer); err != nil {
+				return errors.Wrapf(err, "decoding metadata of layer %s", style.Symbol(bp.ID+"/"+name))
+			}
+			if !layer.Launch {
+				continue
+			}
+			// a buildpack may keep a layer of the previous image without restoring its contents
+			var tarFile string
+			if layerDir := path.Join(bpDir, name); layerDirs[layerDir] {
+				if tarFile, err = b.copyLayer(ctx, ctr.ID, layerDir, tmpDir, uid, gid); err != nil {
+					return errors.Wrapf(err, "reading layer %s", style.Symbol(bp.ID+"/"+name))
+				}
+			}
+			sha, err := addDaemonLayer(img, tarFile, prevLayers[name].SHA)
+			if err != nil {
+				return errors.Wrapf(err, "add layer %s", style.Symbol(bp.ID+"/"+name))
+			}
+			bpMetadata.Layers[name] = lifecycle.LayerMetadata{SHA: sha, Data: layer.Metadata, Build: layer.Build, Launch: layer.Launch, Cache: layer.Cache}
+		}
+		metadata.Buildpacks = append(metadata.Buildpacks, bpMetadata)
+	}
+
+	launcherTar := filepath.Join(tmpDir, "launcher.tar")
+	if err := b.copyLauncher(ctx, ctr.ID, launcherTar, uid, gid); err != nil {
+		return errors.Wrap(err, "reading launcher")
+	}
+	if metadata.Launcher.SHA, err = addDaemonLayer(img, launcherTar, previous.Launcher.SHA); err != nil {
+		return errors.Wrap(err, "add launcher layer")
+	}
+
+	label, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err := img.SetLabel(lifecycleMetadataLabel, string(label)); err != nil {
+		return err
+	}
+	if err := img.SetEnv("PACK_LAYERS_DIR", launchDir); err != nil {
+		return err
+	}
+	if err := img.SetEnv("PACK_APP_DIR", launchDir+"/app"); err != nil {
+		return err
+	}
+	if err := img.SetEntrypoint(lifecycleDir + "/launcher"); err != nil {
+		return err
+	}
+	if _, err := img.Save(); err != nil {
+		return errors.Wrapf(err, "saving image %s", style.Symbol(b.RepoName))
+	}
+	b.Logger.Verbose("Saved image %s to the daemon", style.Symbol(b.RepoName))
+	return nil
+}
+
+// listLayersScript prints the <layer>.toml files of the buildpacks in the workspace, each followed by its
+// layer dir with a trailing slash when it exists. Entries end with a NUL, as layer names may hold any other
+// character. Dot dirs such as the layers cache are skipped.
+const listLayersScript = `for f in ` + launchDir + `/*/*.toml; do
+	if [ -f "$f" ]; then printf '%s\0' "$f"; fi
+	if [ -d "${f%.toml}" ]; then printf '%s\0' "${f%.toml}/"; fi
+done`
+
+// addDaemonLayer adds the layer tar to img and returns its diff ID. The previous layer with diff ID
+// prevSHA is reused instead when the contents are unchanged, or when tarFile is empty because the
+// buildpack kept the layer without restoring its contents.
+func addDaemonLayer(img image.Image, tarFile, prevSHA string) (string, error) {
+	if tarFile == "" {
+		if prevSHA == "" {
+			return "", errors.New("layer has no contents and is not in the previous image")
+		}
+		return prevSHA, img.ReuseLayer(prevSHA)
+	}
+	sha, err := fileDigest(tarFile)
+	if err != nil {
+		return "", err
+	}
+	if sha == prevSHA {
+		return sha, img.ReuseLayer(sha)
+	}
+	return sha, img.AddLayer(tarFile)
+}
+
+// copyLauncher writes a layer tar holding the lifecycle launcher of the container ctrID
+func (b *BuildConfig) copyLauncher(ctx context.Context, ctrID, tarFile string, uid, gid int) error {
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, lifecycleDir+"/launcher")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Create(tarFile)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	tw := tar.NewWriter(fh)
+	if err := writeTarDir(tw, lifecycleDir, uid, gid); err != nil {
+		return err
+	}
+	hdr.Name = lifecycleDir + "/launcher"
+	normalizeHeader(hdr, uid, gid)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, tr); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// copyLayer writes the dir layerDir of the container ctrID, e.g. /workspace/app, to a layer tar in tmpDir,
+// with its files owned by uid and gid and modification times cleared so unchanged layers keep their diff ID
+func (b *BuildConfig) copyLayer(ctx context.Context, ctrID, layerDir, tmpDir string, uid, gid int) (string, error) {
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, layerDir)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(filepath.Join(tmpDir, strings.Replace(strings.TrimPrefix(layerDir, launchDir+"/"), "/", "_", -1)+".tar"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	if err := writeTarParents(tw, layerDir, uid, gid); err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		// entries are relative to the parent of layerDir, e.g. app/file.txt
+		hdr.Name = path.Join(path.Dir(layerDir), hdr.Name)
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = path.Join(path.Dir(layerDir), hdr.Linkname)
+		}
+		normalizeHeader(hdr, uid, gid)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// writeTarParents writes the dirs above the layer dir dir, so they are owned by uid and gid in the image
+func writeTarParents(tw *tar.Writer, dir string, uid, gid int) error {
+	var parents []string
+	for parent := path.Dir(dir); parent != "/"; parent = path.Dir(parent) {
+		parents = append([]string{parent}, parents...)
+	}
+	for _, parent := range parents {
+		if err := writeTarDir(tw, parent, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTarDir(tw *tar.Writer, dir string, uid, gid int) error {
+	return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0755, Uid: uid, Gid: gid, ModTime: time.Unix(0, 0)})
+}
+
+func writeTarFile(tw *tar.Writer, name string, contents []byte, uid, gid int) error {
+	hdr := &tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644, Uid: uid, Gid: gid, ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+func normalizeHeader(hdr *tar.Header, uid, gid int) {
+	hdr.Uid, hdr.Gid = uid, gid
+	hdr.Uname, hdr.Gname = "", ""
+	hdr.ModTime = time.Unix(0, 0)
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+}
@@ -0,0 +1,138 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDaemonExport(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "daemon-export", testDaemonExport, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDaemonExport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject          *pack.BuildConfig
+		mockController   *gomock.Controller
+		mockDocker       *mocks.MockDocker
+		mockImageFactory *mocks.MockImageFactory
+		mockRunImage     *mocks.MockImage
+		outBuf           bytes.Buffer
+		ctr              container.ContainerCreateCreatedBody
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		mockImageFactory = mocks.NewMockImageFactory(mockController)
+		subject = &pack.BuildConfig{
+			RepoName:     "some/app",
+			Builder:      "some/builder",
+			RunImage:     "some/run",
+			CacheVolume:  "some-cache-volume",
+			Cli:          mockDocker,
+			ImageFactory: mockImageFactory,
+			Logger:       logging.NewLogger(&outBuf, &outBuf, false, false),
+		}
+		ctr = container.ContainerCreateCreatedBody{ID: "some-container-id"}
+
+		mockPrevImage := mocks.NewMockImage(mockController)
+		mockPrevImage.EXPECT().Found().Return(false, nil)
+		mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockPrevImage, nil)
+		mockRunImage = mocks.NewMockImage(mockController)
+		mockRunImage.EXPECT().TopLayer().Return("some-top-layer", nil)
+		mockRunImage.EXPECT().Digest().Return("some-digest", nil)
+		mockRunImage.EXPECT().Rename("some/app")
+		mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	tarOf := func(files map[string]string) io.ReadCloser {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, contents := range files {
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}))
+			_, err := tw.Write([]byte(contents))
+			h.AssertNil(t, err)
+		}
+		h.AssertNil(t, tw.Close())
+		return ioutil.NopCloser(&buf)
+	}
+
+	expectCopy := func(path string, files map[string]string) {
+		mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, path).
+			Return(tarOf(files), dockertypes.ContainerPathStat{}, nil)
+	}
+
+	when("#Export", func() {
+		it("only reads the app, config, launcher and launch layers from the workspace", func() {
+			mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").Return(ctr, nil)
+			mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, gomock.Any()).Return(nil)
+			expectCopy("/workspace/group.toml", map[string]string{"group.toml": "[[buildpacks]]\nid = \"some.bp\"\nversion = \"1.2.3\"\n"})
+			mockDocker.EXPECT().RunContainer(gomock.Any(), ctr.ID, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, stdout, _ io.Writer) error {
+					_, err := io.WriteString(stdout, strings.Join([]string{
+						"/workspace/some.bp/build-only.toml",
+						"/workspace/some.bp/build-only/",
+						"/workspace/some.bp/cache-only.toml",
+						"/workspace/some.bp/cache-only/",
+						"/workspace/some.bp/some layer.toml",
+						"/workspace/some.bp/some layer/",
+					}, "\x00")+"\x00")
+					return err
+				})
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &container.Config{Env: []string{"PACK_USER_ID=1000", "PACK_GROUP_ID=1000"}},
+			}, nil, nil)
+			expectCopy("/workspace/app", map[string]string{"app/file.txt": "some text"})
+			expectCopy("/workspace/config", map[string]string{"config/metadata.toml": ""})
+			expectCopy("/workspace/some.bp/build-only.toml", map[string]string{"build-only.toml": "build = true"})
+			expectCopy("/workspace/some.bp/cache-only.toml", map[string]string{"cache-only.toml": "cache = true"})
+			expectCopy("/workspace/some.bp/some layer.toml", map[string]string{"some layer.toml": "launch = true"})
+			expectCopy("/workspace/some.bp/some layer", map[string]string{"some layer/file.txt": "content"})
+			expectCopy("/lifecycle/launcher", map[string]string{"launcher": "some-launcher"})
+
+			mockRunImage.EXPECT().AddLayer(gomock.Any()).Return(nil).Times(4)
+			mockRunImage.EXPECT().SetLabel("io.buildpacks.lifecycle.metadata", gomock.Any()).Return(nil)
+			mockRunImage.EXPECT().SetEnv(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+			mockRunImage.EXPECT().SetEntrypoint("/lifecycle/launcher").Return(nil)
+			mockRunImage.EXPECT().Save().Return("some-image-id", nil)
+
+			h.AssertNil(t, subject.Export())
+		})
+
+		it("does not give trusted builders the docker socket", func() {
+			var hostConfig *container.HostConfig
+			mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").
+				DoAndReturn(func(_ context.Context, _ *container.Config, hc *container.HostConfig, _ interface{}, _ string) (container.ContainerCreateCreatedBody, error) {
+					hostConfig = hc
+					return container.ContainerCreateCreatedBody{}, errors.New("some-create-error")
+				})
+
+			subject.TrustBuilder = true
+			h.AssertError(t, subject.Export(), "some-create-error")
+			h.AssertEq(t, hostConfig.Binds, []string{"some-cache-volume:/workspace:", "some-cache-volume-layers:/workspace/.cache:"})
+		})
+	})
+}
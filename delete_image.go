@@ -0,0 +1,133 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+const rebaseTmpImagePattern = "pack-rebase-tmp-*"
+
+type ImageDeleter struct {
+	Cli          Docker
+	Logger       *logging.Logger
+	ImageFactory ImageFactory
+}
+
+type DeleteImageFlags struct {
+	RepoNames []string
+	Registry  bool
+	Force     bool
+}
+
+// Delete removes images from the daemon, and from their registry when flags.Registry is set.
+// Leftover temporary rebase images are always removed.
+func (d *ImageDeleter) Delete(flags DeleteImageFlags) error {
+	ctx := context.Background()
+	for _, repoName := range flags.RepoNames {
+		img, err := d.ImageFactory.NewLocal(repoName, false)
+		if err != nil {
+			return err
+		}
+		if found, err := img.Found(); err != nil {
+			return err
+		} else if found {
+			if err := d.removeFromDaemon(ctx, repoName, flags.Force); err != nil {
+				return err
+			}
+			d.Logger.Info("Deleted image %s from daemon", style.Symbol(repoName))
+		} else if !flags.Registry {
+			return fmt.Errorf("image %s does not exist on the daemon", style.Symbol(repoName))
+		}
+
+		if flags.Registry {
+			if err := d.removeFromRegistry(repoName); err != nil {
+				return err
+			}
+			d.Logger.Info("Deleted image %s from registry", style.Symbol(repoName))
+		}
+	}
+	return d.removeTemporaryImages(ctx)
+}
+
+func (d *ImageDeleter) removeFromDaemon(ctx context.Context, imageID string, force bool) error {
+	if _, err := d.Cli.ImageRemove(ctx, imageID, dockertypes.ImageRemoveOptions{Force: force, PruneChildren: true}); err != nil {
+		return errors.Wrapf(err, "deleting image %s", style.Symbol(imageID))
+	}
+	return nil
+}
+
+func (d *ImageDeleter) removeTemporaryImages(ctx context.Context) error {
+	args := filters.NewArgs()
+	args.Add("reference", rebaseTmpImagePattern)
+	images, err := d.Cli.ImageList(ctx, dockertypes.ImageListOptions{Filters: args})
+	if err != nil {
+		return errors.Wrap(err, "listing temporary images")
+	}
+	for _, img := range images {
+		if err := d.removeFromDaemon(ctx, img.ID, true); err != nil {
+			return err
+		}
+		d.Logger.Verbose("Deleted temporary image %s", style.Symbol(strings.Join(img.RepoTags, ", ")))
+	}
+	return nil
+}
+
+// removeFromRegistry deletes the manifest the image reference points to using the registry API.
+// Not every registry supports deletion (notably Docker Hub), in which case an error is returned.
+func (d *ImageDeleter) removeFromRegistry(repoName string) error {
+	img, err := d.ImageFactory.NewRemote(repoName)
+	if err != nil {
+		return err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return errors.Wrapf(err, "resolving digest of %s", style.Symbol(repoName))
+	}
+
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	registry := ref.Context().RegistryStr()
+	scheme := "https"
+	if strings.HasPrefix(registry, "localhost") || strings.HasPrefix(registry, "127.0.0.1") {
+		scheme = "http"
+	}
+	url := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, registry, ref.Context().RepositoryStr(), digest)
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	auth, err := authHeader(repoName)
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s from registry", style.Symbol(repoName))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return nil
+	case http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType:
+		return fmt.Errorf("registry %s does not support deleting images", style.Symbol(registry))
+	default:
+		return fmt.Errorf("deleting %s from registry failed with status %d", style.Symbol(repoName), resp.StatusCode)
+	}
+}
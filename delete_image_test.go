@@ -0,0 +1,74 @@
+package pack_test
+
+import (
+	"bytes"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDeleteImage(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "delete-image", testDeleteImage, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDeleteImage(t *testing.T, when spec.G, it spec.S) {
+	when("#Delete", func() {
+		var (
+			subject          pack.ImageDeleter
+			mockController   *gomock.Controller
+			mockDocker       *mocks.MockDocker
+			mockImageFactory *mocks.MockImageFactory
+			outBuf           bytes.Buffer
+			errBuf           bytes.Buffer
+		)
+
+		it.Before(func() {
+			mockController = gomock.NewController(t)
+			mockDocker = mocks.NewMockDocker(mockController)
+			mockImageFactory = mocks.NewMockImageFactory(mockController)
+			subject = pack.ImageDeleter{
+				Cli:          mockDocker,
+				Logger:       logging.NewLogger(&outBuf, &errBuf, true, false),
+				ImageFactory: mockImageFactory,
+			}
+		})
+
+		it.After(func() {
+			mockController.Finish()
+		})
+
+		it("deletes the image and leftover temporary rebase images from the daemon", func() {
+			mockImage := mocks.NewMockImage(mockController)
+			mockImage.EXPECT().Found().Return(true, nil)
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), "some/app", dockertypes.ImageRemoveOptions{PruneChildren: true}).Return(nil, nil)
+			mockDocker.EXPECT().ImageList(gomock.Any(), gomock.Any()).Return([]dockertypes.ImageSummary{
+				{ID: "sha256:tmp", RepoTags: []string{"pack-rebase-tmp-123:latest"}},
+			}, nil)
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), "sha256:tmp", dockertypes.ImageRemoveOptions{Force: true, PruneChildren: true}).Return(nil, nil)
+
+			h.AssertNil(t, subject.Delete(pack.DeleteImageFlags{RepoNames: []string{"some/app"}}))
+			h.AssertContains(t, outBuf.String(), "Deleted image 'some/app' from daemon")
+		})
+
+		it("returns an error when the image does not exist on the daemon", func() {
+			mockImage := mocks.NewMockImage(mockController)
+			mockImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockImage, nil)
+
+			err := subject.Delete(pack.DeleteImageFlags{RepoNames: []string{"some/app"}})
+			h.AssertError(t, err, "image 'some/app' does not exist on the daemon")
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package pack
+
+import (
+	"archive/tar"
+	"context"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// DetectResult is the buildpack group chosen by the detector and the build plan its buildpacks contributed
+type DetectResult struct {
+	Group lifecycle.BuildpackGroup
+	Plan  string
+}
+
+// ReadDetectResult reads the group.toml and plan.toml written by Detect from the cache volume
+func (b *BuildConfig) ReadDetectResult() (*DetectResult, error) {
+	ctx := context.Background()
+	ctrID, err := createCacheContainer(ctx, b.Cli, b.CacheVolume, b.Builder)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	group, err := b.readWorkspaceFile(ctx, ctrID, groupPath)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := b.readWorkspaceFile(ctx, ctrID, planPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DetectResult{Plan: string(plan)}
+	if _, err := toml.Decode(string(group), &result.Group); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", style.Symbol(groupPath))
+	}
+	return result, nil
+}
+
+func (b *BuildConfig) readWorkspaceFile(ctx context.Context, ctrID, filePath string) ([]byte, error) {
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", style.Symbol(filePath))
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", style.Symbol(filePath))
+		}
+		if path.Base(hdr.Name) == path.Base(filePath) {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// printDetectResult logs the group and plan chosen by the detector
+func (b *BuildConfig) printDetectResult() error {
+	result, err := b.ReadDetectResult()
+	if err != nil {
+		return err
+	}
+
+	b.Logger.Info("Detected buildpack group:")
+	for _, bp := range result.Group.Buildpacks {
+		b.Logger.Info("  %s", style.Symbol(bp.ID+"@"+bp.Version))
+	}
+	b.Logger.Info("Build plan:")
+	plan := strings.TrimSpace(result.Plan)
+	if plan == "" {
+		b.Logger.Info("  (empty)")
+		return nil
+	}
+	for _, line := range strings.Split(plan, "\n") {
+		b.Logger.Info("  %s", line)
+	}
+	return nil
+}
+
+// logDetectSummary logs the group chosen by the detector and the entries of its build plan in verbose mode,
+// which explains the buildpacks that run next
+func (b *BuildConfig) logDetectSummary() error {
+	if !b.Logger.IsVerbose() {
+		return nil
+	}
+	result, err := b.ReadDetectResult()
+	if err != nil {
+		return err
+	}
+	var plan map[string]interface{}
+	if _, err := toml.Decode(result.Plan, &plan); err != nil {
+		return errors.Wrapf(err, "decoding %s", style.Symbol(planPath))
+	}
+
+	b.Logger.Verbose("Buildpacks that will build the app, in order:")
+	for _, bp := range result.Group.Buildpacks {
+		b.Logger.Verbose("  %s", style.Symbol(bp.ID+"@"+bp.Version))
+	}
+	if len(plan) == 0 {
+		b.Logger.Verbose("The build plan has no entries")
+		return nil
+	}
+	var names []string
+	for name := range plan {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.Logger.Verbose("Build plan entries the buildpacks provide and require:")
+	for _, name := range names {
+		entry, _ := plan[name].(map[string]interface{})
+		if version, ok := entry["version"].(string); ok && version != "" {
+			b.Logger.Verbose("  %s %s", style.Symbol(name), version)
+		} else {
+			b.Logger.Verbose("  %s", style.Symbol(name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestDetectResult(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "detect-result", testDetectResult, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDetectResult(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject        *pack.BuildConfig
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		outBuf         bytes.Buffer
+		ctr            container.ContainerCreateCreatedBody
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		subject = &pack.BuildConfig{
+			Builder:     "some/builder",
+			CacheVolume: "some-cache-volume",
+			Cli:         mockDocker,
+			Logger:      logging.NewLogger(&outBuf, &outBuf, false, false),
+		}
+		ctr = container.ContainerCreateCreatedBody{ID: "some-container-id"}
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	singleFileTar := func(name, contents string) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}))
+		_, err := tw.Write([]byte(contents))
+		h.AssertNil(t, err)
+		h.AssertNil(t, tw.Close())
+		return &buf
+	}
+
+	when("#ReadDetectResult", func() {
+		it("reads the group and plan from the cache volume", func() {
+			mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+				Image: "some/builder",
+				Cmd:   []string{"true"},
+			}, &container.HostConfig{
+				Binds: []string{"some-cache-volume:/workspace"},
+			}, nil, "").Return(ctr, nil)
+			mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, dockertypes.ContainerRemoveOptions{}).Return(nil)
+			mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/group.toml").
+				Return(ioutil.NopCloser(singleFileTar("group.toml", "[[buildpacks]]\nid = \"some.bp\"\nversion = \"1.2.3\"\n")), dockertypes.ContainerPathStat{}, nil)
+			mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/plan.toml").
+				Return(ioutil.NopCloser(singleFileTar("plan.toml", "[some-dep]\nversion = \"4.5\"\n")), dockertypes.ContainerPathStat{}, nil)
+
+			result, err := subject.ReadDetectResult()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(result.Group.Buildpacks), 1)
+			h.AssertEq(t, result.Group.Buildpacks[0].ID, "some.bp")
+			h.AssertEq(t, result.Group.Buildpacks[0].Version, "1.2.3")
+			h.AssertEq(t, result.Plan, "[some-dep]\nversion = \"4.5\"\n")
+		})
+	})
+
+	when("#ReadProcessTypes", func() {
+		it("reads the process types declared by the buildpacks from the cache volume", func() {
+			mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").Return(ctr, nil)
+			mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, dockertypes.ContainerRemoveOptions{}).Return(nil)
+			mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/config/metadata.toml").
+				Return(ioutil.NopCloser(singleFileTar("metadata.toml", "[[processes]]\ntype = \"web\"\ncommand = \"npm start\"\n\n[[processes]]\ntype = \"worker\"\ncommand = \"npm run worker\"\n")), dockertypes.ContainerPathStat{}, nil)
+
+			types, err := subject.ReadProcessTypes()
+			h.AssertNil(t, err)
+			h.AssertEq(t, types, []string{"web", "worker"})
+		})
+	})
+}
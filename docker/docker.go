@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	dockercli "github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/docker/pkg/term"
 	"github.com/pkg/errors"
 )
 
@@ -55,3 +58,65 @@ func (d *Client) RunContainer(ctx context.Context, id string, stdout io.Writer,
 	}
 	return <-copyErr
 }
+
+// RunInteractive starts a container created with Tty and OpenStdin, connecting it to stdin and stdout
+// until it exits. When stdin is a terminal it is put in raw mode for the duration.
+func (d *Client) RunInteractive(ctx context.Context, id string, stdin io.Reader, stdout io.Writer) error {
+	resp, err := d.ContainerAttach(ctx, id, dockertypes.ContainerAttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "container attach")
+	}
+	defer resp.Close()
+
+	if fd, isTerminal := term.GetFdInfo(stdin); isTerminal {
+		state, err := term.SetRawTerminal(fd)
+		if err != nil {
+			return errors.Wrap(err, "set raw terminal")
+		}
+		defer term.RestoreTerminal(fd, state)
+	}
+
+	bodyChan, errChan := d.ContainerWait(ctx, id, container.WaitConditionNextExit)
+	if err := d.ContainerStart(ctx, id, dockertypes.ContainerStartOptions{}); err != nil {
+		return errors.Wrap(err, "container start")
+	}
+
+	go io.Copy(resp.Conn, stdin)
+	go io.Copy(stdout, resp.Reader)
+
+	select {
+	case body := <-bodyChan:
+		if body.StatusCode != 0 {
+			return fmt.Errorf("failed with status code: %d", body.StatusCode)
+		}
+	case err := <-errChan:
+		return err
+	}
+	return nil
+}
+
+// IsRemote reports whether host, the address of a daemon such as DOCKER_HOST, is on another machine. Sockets,
+// named pipes and loopback addresses are local.
+func IsRemote(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "tcp", "http", "https", "ssh":
+	default:
+		return false
+	}
+	switch hostname := u.Hostname(); hostname {
+	case "", "localhost":
+		return false
+	default:
+		ip := net.ParseIP(hostname)
+		return ip == nil || !ip.IsLoopback()
+	}
+}
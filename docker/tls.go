@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions configure TLS when DOCKER_HOST points at a daemon exposed over tcp://
+type TLSOptions struct {
+	Verify   bool
+	CertPath string // directory containing ca.pem, cert.pem and key.pem
+}
+
+// SetTLSEnv exports opts as DOCKER_TLS_VERIFY and DOCKER_CERT_PATH, which are read by New and by the
+// lifecycle image factory when connecting to the daemon. Options left unset keep the values from the environment.
+// As with the docker CLI, the cert path defaults to ~/.docker when verification is enabled.
+func SetTLSEnv(opts TLSOptions) error {
+	if opts.Verify {
+		if err := os.Setenv("DOCKER_TLS_VERIFY", "1"); err != nil {
+			return err
+		}
+	}
+	if opts.CertPath != "" {
+		if err := os.Setenv("DOCKER_CERT_PATH", opts.CertPath); err != nil {
+			return err
+		}
+	}
+
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		if os.Getenv("DOCKER_TLS_VERIFY") == "" {
+			return nil
+		}
+		certPath = filepath.Join(os.Getenv("HOME"), ".docker")
+		if err := os.Setenv("DOCKER_CERT_PATH", certPath); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		if _, err := os.Stat(filepath.Join(certPath, file)); err != nil {
+			return errors.Wrap(err, "docker TLS certificates")
+		}
+	}
+	return nil
+}
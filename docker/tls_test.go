@@ -0,0 +1,109 @@
+package docker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/docker"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestTLS(t *testing.T) {
+	// not parallel, the specs change the environment
+	spec.Run(t, "tls", testTLS, spec.Report(report.Terminal{}))
+}
+
+func testTLS(t *testing.T, when spec.G, it spec.S) {
+	when("#SetTLSEnv", func() {
+		var (
+			tmpDir  string
+			hostEnv map[string]string
+		)
+
+		writeCerts := func(dir string, files ...string) {
+			h.AssertNil(t, os.MkdirAll(dir, 0755))
+			for _, file := range files {
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(dir, file), []byte("some-pem"), 0644))
+			}
+		}
+
+		it.Before(func() {
+			hostEnv = map[string]string{}
+			for _, name := range []string{"HOME", "DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH"} {
+				hostEnv[name] = os.Getenv(name)
+				os.Unsetenv(name)
+			}
+			var err error
+			tmpDir, err = ioutil.TempDir("", "pack.docker.tls.")
+			h.AssertNil(t, err)
+			os.Setenv("HOME", filepath.Join(tmpDir, "home"))
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+			for name, value := range hostEnv {
+				if value == "" {
+					os.Unsetenv(name)
+				} else {
+					os.Setenv(name, value)
+				}
+			}
+		})
+
+		it("leaves the environment alone without verification", func() {
+			h.AssertNil(t, docker.SetTLSEnv(docker.TLSOptions{}))
+			h.AssertEq(t, os.Getenv("DOCKER_TLS_VERIFY"), "")
+			h.AssertEq(t, os.Getenv("DOCKER_CERT_PATH"), "")
+		})
+
+		it("overrides the environment with the flags", func() {
+			envCerts := filepath.Join(tmpDir, "env-certs")
+			flagCerts := filepath.Join(tmpDir, "flag-certs")
+			writeCerts(flagCerts, "ca.pem", "cert.pem", "key.pem")
+			os.Setenv("DOCKER_CERT_PATH", envCerts)
+
+			h.AssertNil(t, docker.SetTLSEnv(docker.TLSOptions{Verify: true, CertPath: flagCerts}))
+			h.AssertEq(t, os.Getenv("DOCKER_TLS_VERIFY"), "1")
+			h.AssertEq(t, os.Getenv("DOCKER_CERT_PATH"), flagCerts)
+		})
+
+		it("keeps the cert path of the environment without --tlscert", func() {
+			envCerts := filepath.Join(tmpDir, "env-certs")
+			writeCerts(envCerts, "ca.pem", "cert.pem", "key.pem")
+			os.Setenv("DOCKER_CERT_PATH", envCerts)
+
+			h.AssertNil(t, docker.SetTLSEnv(docker.TLSOptions{Verify: true}))
+			h.AssertEq(t, os.Getenv("DOCKER_CERT_PATH"), envCerts)
+		})
+
+		it("defaults the cert path to ~/.docker", func() {
+			homeCerts := filepath.Join(tmpDir, "home", ".docker")
+			writeCerts(homeCerts, "ca.pem", "cert.pem", "key.pem")
+
+			h.AssertNil(t, docker.SetTLSEnv(docker.TLSOptions{Verify: true}))
+			h.AssertEq(t, os.Getenv("DOCKER_CERT_PATH"), homeCerts)
+		})
+
+		it("returns an error when a certificate file is missing", func() {
+			for _, missing := range []string{"ca.pem", "cert.pem", "key.pem"} {
+				certs := filepath.Join(tmpDir, "without-"+missing)
+				var files []string
+				for _, file := range []string{"ca.pem", "cert.pem", "key.pem"} {
+					if file != missing {
+						files = append(files, file)
+					}
+				}
+				writeCerts(certs, files...)
+
+				err := docker.SetTLSEnv(docker.TLSOptions{Verify: true, CertPath: certs})
+				h.AssertError(t, err, "docker TLS certificates")
+				h.AssertError(t, err, missing)
+			}
+		})
+	})
+}
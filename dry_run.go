@@ -0,0 +1,109 @@
+package pack
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildpack/pack/style"
+)
+
+// logResolvedConfig prints the images, cache and inputs a build would use, for --dry-run
+func (b *BuildConfig) logResolvedConfig() {
+	field := func(label, value string) {
+		b.Logger.Info("  %-12s %s", label+":", value)
+	}
+
+	b.Logger.Info("Dry run, no containers will be created")
+	if len(b.Tags) > 0 {
+		field("Tags", strings.Join(b.Tags, ", "))
+	}
+	field("Builder", style.Symbol(b.Builder))
+	field("Run image", style.Symbol(b.RunImage))
+	field("Stack", style.Symbol(b.StackID))
+	if b.LifecycleImage != "" {
+		field("Lifecycle", style.Symbol(b.LifecycleImage))
+	}
+	if b.LifecycleVersion != "" {
+		field("Lifecycle", style.Symbol(b.LifecycleVersion))
+	}
+	field("Platform", style.Symbol(b.platform().Version))
+	if b.Platform != "" {
+		field("Target", style.Symbol(b.Platform))
+	}
+	if b.CreationTime != nil {
+		field("Created", style.Symbol(b.CreationTime.Format(time.RFC3339)))
+	}
+	if src := b.ProjectSource; src != nil {
+		var parts []string
+		for _, part := range append([]string{src.Metadata.Repository, src.Version.Commit}, src.Metadata.Refs...) {
+			if part != "" {
+				parts = append(parts, style.Symbol(part))
+			}
+		}
+		field("Source", strings.Join(parts, " "))
+	}
+	if b.DefaultProcess != "" {
+		field("Process", style.Symbol(b.DefaultProcess))
+	}
+	if b.PreviousImage != "" {
+		field("Previous", style.Symbol(b.PreviousImage))
+	}
+	if b.GID != nil {
+		field("Group ID", style.Symbol(strconv.Itoa(*b.GID)))
+	}
+	field("Cache", style.Symbol(b.CacheVolume))
+	field("Layers cache", style.Symbol(LayersCacheVolume(b.CacheVolume)))
+	if b.CacheImage != "" {
+		field("Cache image", style.Symbol(b.CacheImage))
+	}
+	if len(b.Order) > 0 {
+		var groups []string
+		for _, group := range b.Order {
+			var ids []string
+			for _, bp := range group.Buildpacks {
+				ids = append(ids, bp.ID+"@"+bp.Version)
+			}
+			groups = append(groups, "["+strings.Join(ids, ", ")+"]")
+		}
+		field("Order", strings.Join(groups, " "))
+	}
+	if len(b.Buildpacks) == 0 {
+		field("Buildpacks", "(detected from the builder's order)")
+	} else {
+		field("Buildpacks", strings.Join(b.Buildpacks, ", "))
+	}
+
+	if len(b.Bindings) > 0 {
+		field("Bindings", strings.Join(b.bindingNames(), ", "))
+	}
+	if len(b.Labels) > 0 {
+		var labels []string
+		for k, v := range b.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		field("Labels", strings.Join(labels, ", "))
+	}
+
+	// values are left out as they commonly hold credentials
+	var env []string
+	for k := range b.EnvFile {
+		env = append(env, k)
+	}
+	sort.Strings(env)
+	if len(env) == 0 {
+		field("Env", "(none)")
+	} else {
+		field("Env", strings.Join(env, ", "))
+	}
+	if len(b.LaunchEnv) > 0 {
+		var launchEnv []string
+		for k := range b.LaunchEnv {
+			launchEnv = append(launchEnv, k)
+		}
+		sort.Strings(launchEnv)
+		field("Launch env", strings.Join(launchEnv, ", "))
+	}
+}
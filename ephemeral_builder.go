@@ -0,0 +1,95 @@
+package pack
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// createEphemeralBuilder derives a builder image from the builder when buildpacks are provided, adding the
+// buildpacks and an order.toml that selects them, so every phase runs the same buildpacks without copying
+// them again. The image is named after the cache volume and tagged with an ID unique to the build, so
+// concurrent builds sharing the cache volume don't remove each other's builder, and is removed by
+// removeEphemeralBuilder when the build ends.
+func (b *BuildConfig) createEphemeralBuilder(ctx context.Context) error {
+	if len(b.Buildpacks) == 0 || b.ephemeralBuilder != "" {
+		return nil
+	}
+
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{Image: b.Builder}, &container.HostConfig{}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create ephemeral builder container")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	buildpacks, err := b.copyBuildpacksToContainer(ctx, ctr.ID)
+	if err != nil {
+		return errors.Wrap(err, "copy buildpacks to container")
+	}
+	groups := b.Order
+	if len(groups) == 0 {
+		b.Logger.Verbose("Using manually-provided group")
+		groups = lifecycle.BuildpackOrder{
+			lifecycle.BuildpackGroup{
+				Buildpacks: buildpacks,
+			},
+		}
+	} else {
+		b.Logger.Verbose("Using manually-provided order")
+	}
+	if err := b.copyOrderToContainer(ctx, ctr.ID, groups); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("pack.local/builder/%x:%s", md5.Sum([]byte(b.CacheVolume)), uuid.New().String())
+	if _, err := b.Cli.ContainerCommit(ctx, ctr.ID, dockertypes.ContainerCommitOptions{Reference: name}); err != nil {
+		return errors.Wrapf(err, "create ephemeral builder %s", style.Symbol(name))
+	}
+	b.ephemeralBuilder = name
+	b.Logger.Verbose("Using ephemeral builder %s", style.Symbol(b.ephemeralBuilder))
+	return nil
+}
+
+func (b *BuildConfig) removeEphemeralBuilder() {
+	if b.ephemeralBuilder == "" {
+		return
+	}
+	if _, err := b.Cli.ImageRemove(context.Background(), b.ephemeralBuilder, dockertypes.ImageRemoveOptions{Force: true, PruneChildren: true}); err != nil {
+		b.Logger.Verbose("Unable to remove ephemeral builder %s: %s", style.Symbol(b.ephemeralBuilder), err)
+	}
+	b.ephemeralBuilder = ""
+}
+
+// phaseImage returns the image lifecycle phases run in: the ephemeral builder when there is one, otherwise the builder
+func (b *BuildConfig) phaseImage() string {
+	if b.ephemeralBuilder != "" {
+		return b.ephemeralBuilder
+	}
+	return b.Builder
+}
+
+// copyOrderToContainer writes groups as the order.toml of the container
+func (b *BuildConfig) copyOrderToContainer(ctx context.Context, ctrID string, groups lifecycle.BuildpackOrder) error {
+	var tomlBuilder strings.Builder
+	if err := toml.NewEncoder(&tomlBuilder).Encode(map[string]interface{}{"groups": groups}); err != nil {
+		return errors.Wrapf(err, "encoding order.toml: %#v", groups)
+	}
+	ftr, err := b.FS.CreateSingleFileTar(orderPath, tomlBuilder.String())
+	if err != nil {
+		return errors.Wrap(err, "converting order TOML to tar reader")
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", ftr, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("creating %s", orderPath))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestEphemeralBuilder(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "ephemeral builder", testEphemeralBuilder, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testEphemeralBuilder(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		outBuf, errBuf bytes.Buffer
+		newBuildConfig func() *BuildConfig
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		newBuildConfig = func() *BuildConfig {
+			return &BuildConfig{
+				RepoName:    "some/app",
+				Builder:     "some/builder",
+				CacheVolume: "some-cache-volume",
+				Buildpacks:  []string{"some.bp@1.2.3"},
+				Cli:         mockDocker,
+				FS:          &fs.FS{},
+				Logger:      logging.NewLogger(&outBuf, &errBuf, true, false),
+			}
+		}
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	// expectCreate expects the ephemeral builder to be committed from the container ctrID, and returns its name
+	expectCreate := func(ctrID string) *string {
+		var name string
+		mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{Image: "some/builder"}, gomock.Any(), nil, "").
+			Return(container.ContainerCreateCreatedBody{ID: ctrID}, nil)
+		mockDocker.EXPECT().CopyToContainer(gomock.Any(), ctrID, "/", gomock.Any(), gomock.Any()).Return(nil)
+		mockDocker.EXPECT().ContainerCommit(gomock.Any(), ctrID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, opts dockertypes.ContainerCommitOptions) (dockertypes.IDResponse, error) {
+				name = opts.Reference
+				return dockertypes.IDResponse{ID: "sha256:" + ctrID}, nil
+			})
+		mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctrID, gomock.Any()).Return(nil)
+		return &name
+	}
+
+	when("#createEphemeralBuilder", func() {
+		it("names the builder after the cache volume with a tag unique to the build", func() {
+			name := expectCreate("some-ctr")
+			b := newBuildConfig()
+
+			h.AssertNil(t, b.createEphemeralBuilder(context.Background()))
+
+			pattern := fmt.Sprintf(`^pack\.local/builder/%x:[0-9a-f-]+$`, md5.Sum([]byte("some-cache-volume")))
+			if !regexp.MustCompile(pattern).MatchString(*name) {
+				t.Fatalf("expected ephemeral builder name %s to match %s", *name, pattern)
+			}
+			h.AssertEq(t, b.phaseImage(), *name)
+		})
+
+		it("gives concurrent builds with the same cache volume their own builder", func() {
+			name := expectCreate("some-ctr")
+			otherName := expectCreate("other-ctr")
+			b, other := newBuildConfig(), newBuildConfig()
+
+			h.AssertNil(t, b.createEphemeralBuilder(context.Background()))
+			h.AssertNil(t, other.createEphemeralBuilder(context.Background()))
+			if *name == *otherName {
+				t.Fatalf("expected builds to use different ephemeral builders, both used %s", *name)
+			}
+
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), *name, gomock.Any()).Return(nil, nil)
+			b.removeEphemeralBuilder()
+			h.AssertEq(t, other.phaseImage(), *otherName)
+		})
+
+		it("does not create a builder without buildpacks", func() {
+			b := newBuildConfig()
+			b.Buildpacks = nil
+
+			h.AssertNil(t, b.createEphemeralBuilder(context.Background()))
+			h.AssertEq(t, b.phaseImage(), "some/builder")
+		})
+	})
+
+	when("#removeEphemeralBuilder", func() {
+		it("removes the builder once the build succeeds", func() {
+			name := expectCreate("some-ctr")
+			b := newBuildConfig()
+			h.AssertNil(t, b.createEphemeralBuilder(context.Background()))
+
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), *name, dockertypes.ImageRemoveOptions{Force: true, PruneChildren: true}).Return(nil, nil)
+			b.removeEphemeralBuilder()
+			h.AssertEq(t, b.phaseImage(), "some/builder")
+		})
+
+		it("removes the builder when the build fails", func() {
+			name := expectCreate("some-ctr")
+			b := newBuildConfig()
+			h.AssertNil(t, b.createEphemeralBuilder(context.Background()))
+
+			mockDocker.EXPECT().VolumeCreate(gomock.Any(), gomock.Any()).Return(dockertypes.Volume{}, errors.New("no space left on device"))
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), *name, gomock.Any()).Return(nil, nil)
+			h.AssertNotNil(t, b.RunContext(context.Background()))
+			h.AssertEq(t, b.phaseImage(), "some/builder")
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package fs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches slash-separated paths, relative to a root directory, against gitignore-style patterns.
+//
+// Patterns without a slash match a name at any depth, others are relative to the root. '*' and '?' do not
+// match '/', while '**' does. A trailing '/' only matches directories, and a leading '!' re-includes paths
+// matched by an earlier pattern. A path also matches when any of its parent directories match.
+type Matcher struct {
+	rules []matchRule
+}
+
+type matchRule struct {
+	pattern string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewMatcher compiles patterns, ignoring blank lines and lines starting with '#'
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		rule := matchRule{pattern: p}
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		re, err := regexp.Compile(globToRegexp(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %s", rule.pattern, err)
+		}
+		rule.re = re
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.rules) == 0
+}
+
+// Matches reports whether relPath, or one of its parent directories, matches
+func (m *Matcher) Matches(relPath string, isDir bool) bool {
+	if m.Empty() {
+		return false
+	}
+	relPath = strings.Trim(path.Clean(relPath), "/")
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(relPath, isDir)
+}
+
+func (m *Matcher) match(relPath string, isDir bool) bool {
+	matched := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(relPath) {
+			matched = !rule.negate
+		}
+	}
+	return matched
+}
+
+func globToRegexp(pattern string) string {
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var re strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if anchored {
+		return "^" + re.String() + "$"
+	}
+	return "^(.*/)?" + re.String() + "$"
+}
@@ -0,0 +1,69 @@
+package fs_test
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestMatcher(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "matcher", testMatcher, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testMatcher(t *testing.T, when spec.G, it spec.S) {
+	matcher := func(patterns ...string) *fs.Matcher {
+		m, err := fs.NewMatcher(patterns)
+		h.AssertNil(t, err)
+		return m
+	}
+
+	it("matches names at any depth", func() {
+		m := matcher("*.log", "node_modules")
+		h.AssertEq(t, m.Matches("debug.log", false), true)
+		h.AssertEq(t, m.Matches("logs/debug.log", false), true)
+		h.AssertEq(t, m.Matches("node_modules/left-pad/index.js", false), true)
+		h.AssertEq(t, m.Matches("src/node_modules", true), true)
+		h.AssertEq(t, m.Matches("src/app.js", false), false)
+	})
+
+	it("anchors patterns containing a slash", func() {
+		m := matcher("/build", "docs/*.md")
+		h.AssertEq(t, m.Matches("build/out.jar", false), true)
+		h.AssertEq(t, m.Matches("src/build/out.jar", false), false)
+		h.AssertEq(t, m.Matches("docs/README.md", false), true)
+		h.AssertEq(t, m.Matches("docs/api/README.md", false), false)
+	})
+
+	it("supports ** across directories", func() {
+		m := matcher("**/testdata/**", "src/**/*.tmp")
+		h.AssertEq(t, m.Matches("pkg/testdata/file.txt", false), true)
+		h.AssertEq(t, m.Matches("src/a/b/c.tmp", false), true)
+		h.AssertEq(t, m.Matches("src/c.tmp", false), true)
+		h.AssertEq(t, m.Matches("other/c.tmp", false), false)
+	})
+
+	it("only matches directories with a trailing slash", func() {
+		m := matcher("tmp/")
+		h.AssertEq(t, m.Matches("tmp", true), true)
+		h.AssertEq(t, m.Matches("tmp/file", false), true)
+		h.AssertEq(t, m.Matches("tmp", false), false)
+	})
+
+	it("re-includes negated patterns", func() {
+		m := matcher("*.env", "!example.env")
+		h.AssertEq(t, m.Matches("prod.env", false), true)
+		h.AssertEq(t, m.Matches("example.env", false), false)
+	})
+
+	it("ignores blank lines and comments", func() {
+		m := matcher("", "# a comment")
+		h.AssertEq(t, m.Empty(), true)
+		h.AssertEq(t, m.Matches("# a comment", false), false)
+	})
+}
@@ -2,10 +2,13 @@ package fs
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -20,20 +23,62 @@ func (*FS) CreateTarFile(tarFile, srcDir, tarDir string, uid, gid int) error {
 		return fmt.Errorf("create file for tar: %s", err)
 	}
 	defer fh.Close()
-	return writeTarArchive(fh, srcDir, tarDir, uid, gid)
+	return writeTarArchive(fh, srcDir, tarDir, uid, gid, nil)
 }
 
-func (*FS) CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error) {
+// tarBufferSize bounds the part of a streamed archive held in memory. The archive is written to the reader
+// in chunks of this size rather than per tar header, so large app dirs are copied efficiently.
+const tarBufferSize = 1 << 20
+
+// CreateTarReader streams a tar of srcDir. Errors writing the archive are returned by Read, and by Close,
+// which must be called to release the goroutine producing the archive. Only tarBufferSize bytes of the
+// archive are held in memory, however large srcDir is.
+func (fs *FS) CreateTarReader(srcDir, tarDir string, uid, gid int) io.ReadCloser {
+	return fs.CreateFilteredTarReader(srcDir, tarDir, uid, gid, nil)
+}
+
+// IncludeFunc reports whether a file or directory, with a slash-separated path relative to the source
+// directory, is added to an archive. Excluding a directory excludes everything beneath it.
+type IncludeFunc func(relPath string, fi os.FileInfo) bool
+
+// CreateFilteredTarReader is like CreateTarReader, but only archives files for which include returns true
+func (*FS) CreateFilteredTarReader(srcDir, tarDir string, uid, gid int, include IncludeFunc) io.ReadCloser {
 	r, w := io.Pipe()
-	errChan := make(chan error, 1)
+	tr := &tarReader{PipeReader: r, done: make(chan struct{})}
 
 	go func() {
-		defer w.Close()
-		err := writeTarArchive(w, srcDir, tarDir, uid, gid)
-		w.Close()
-		errChan <- err
+		defer close(tr.done)
+		tr.err = buffered(w, func(bw io.Writer) error {
+			return writeTarArchive(bw, srcDir, tarDir, uid, gid, include)
+		})
+		w.CloseWithError(tr.err)
 	}()
-	return r, errChan
+	return tr
+}
+
+// buffered calls write with a writer buffering up to tarBufferSize bytes for w, and flushes it
+func buffered(w io.Writer, write func(io.Writer) error) error {
+	bw := bufio.NewWriterSize(w, tarBufferSize)
+	if err := write(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type tarReader struct {
+	*io.PipeReader
+	done chan struct{}
+	err  error
+}
+
+func (t *tarReader) Close() error {
+	t.PipeReader.Close()
+	<-t.done
+	if t.err == io.ErrClosedPipe {
+		// the reader was closed before the whole archive was read
+		return nil
+	}
+	return t.err
 }
 
 func (*FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
@@ -51,7 +96,7 @@ func (*FS) CreateSingleFileTar(path, txt string) (io.Reader, error) {
 	return bytes.NewReader(buf.Bytes()), nil
 }
 
-func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
+func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int, include IncludeFunc) error {
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
@@ -59,13 +104,19 @@ func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 		if err != nil {
 			return err
 		}
-		if fi.Mode().IsDir() {
-			return nil
-		}
 		relPath, err := filepath.Rel(srcDir, file)
 		if err != nil {
 			return err
 		}
+		if include != nil && relPath != "." && !include(filepath.ToSlash(relPath), fi) {
+			if fi.Mode().IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.Mode().IsDir() {
+			return nil
+		}
 
 		var header *tar.Header
 		if fi.Mode()&os.ModeSymlink != 0 {
@@ -86,6 +137,7 @@ func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 		header.Name = filepath.Join(tarDir, relPath)
 		if runtime.GOOS == "windows" {
 			header.Name = strings.Replace(header.Name, "\\", "/", -1)
+			header.Mode = windowsTarMode(header.Mode)
 		}
 		header.Uid = uid
 		header.Gid = gid
@@ -107,6 +159,12 @@ func writeTarArchive(w io.Writer, srcDir, tarDir string, uid, gid int) error {
 	})
 }
 
+// windowsTarMode returns the mode of a file archived on Windows, which has no executable bit: like
+// docker build, every file is made executable so scripts such as a buildpack's bin/detect can run.
+func windowsTarMode(mode int64) int64 {
+	return mode&^0777 | mode&0755 | 0111
+}
+
 func (*FS) AddTextToTar(tw *tar.Writer, name string, contents []byte) error {
 	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}
 	if err := tw.WriteHeader(hdr); err != nil {
@@ -130,6 +188,13 @@ func (*FS) AddFileToTar(tw *tar.Writer, name string, contents *os.File) error {
 }
 
 func (*FS) Untar(r io.Reader, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
 	tr := tar.NewReader(r)
 	for {
 		hdr, err := tr.Next()
@@ -141,22 +206,40 @@ func (*FS) Untar(r io.Reader, dest string) error {
 			return err
 		}
 
-		path := filepath.Join(dest, hdr.Name)
+		if path.Clean(hdr.Name) == "." {
+			continue
+		}
+		// entries must stay inside dest, archives may be downloaded
+		name, err := relocatedName("/", hdr.Name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		// a symlink extracted earlier must not lead a later entry outside dest
+		if err := checkParentDirs(root, name); err != nil {
+			return err
+		}
 
 		switch hdr.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(path, hdr.FileInfo().Mode()); err != nil {
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode()); err != nil {
 				return err
 			}
 		case tar.TypeReg, tar.TypeRegA:
-			_, err := os.Stat(filepath.Dir(path))
+			_, err := os.Stat(filepath.Dir(target))
 			if os.IsNotExist(err) {
-				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 					return err
 				}
 			}
 
-			fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, hdr.FileInfo().Mode())
+			// a file replaces a symlink of the same name instead of being written through it
+			if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			fh, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, hdr.FileInfo().Mode())
 			if err != nil {
 				return err
 			}
@@ -166,11 +249,199 @@ func (*FS) Untar(r io.Reader, dest string) error {
 			}
 			fh.Close()
 		case tar.TypeSymlink:
-			if err := os.Symlink(hdr.Linkname, path); err != nil {
+			link := path.Join(path.Dir(strings.TrimPrefix(name, "/")), hdr.Linkname)
+			if path.IsAbs(hdr.Linkname) || link == ".." || strings.HasPrefix(link, "../") {
+				return fmt.Errorf("invalid symlink in tar %s: target %s is outside of the archive", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
 				return err
 			}
+		case tar.TypeXGlobalHeader:
+			// e.g. the commit ID recorded by git archive
+			continue
 		default:
 			return fmt.Errorf("unknown file type in tar %d", hdr.Typeflag)
 		}
 	}
 }
+
+// RelocateTar streams the tar read from r with every entry moved beneath tarDir and owned by uid and gid.
+// Like CreateTarReader, errors are returned by Read and Close, which must be called.
+func (*FS) RelocateTar(r io.Reader, tarDir string, uid, gid int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	tr := &tarReader{PipeReader: pr, done: make(chan struct{})}
+
+	go func() {
+		defer close(tr.done)
+		tr.err = buffered(pw, func(bw io.Writer) error {
+			return relocateTarArchive(bw, r, tarDir, uid, gid)
+		})
+		pw.CloseWithError(tr.err)
+	}()
+	return tr
+}
+
+func relocateTarArchive(w io.Writer, r io.Reader, tarDir string, uid, gid int) error {
+	in := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	for {
+		hdr, err := in.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			// directories are created for the files beneath them, as with CreateTarReader
+			continue
+		case tar.TypeReg, tar.TypeRegA, tar.TypeSymlink, tar.TypeLink:
+		default:
+			return fmt.Errorf("unknown file type in tar %d", hdr.Typeflag)
+		}
+
+		name, err := relocatedName(tarDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			if hdr.Linkname, err = relocatedName(tarDir, hdr.Linkname); err != nil {
+				return err
+			}
+		}
+		hdr.Uid, hdr.Gid = uid, gid
+		hdr.Uname, hdr.Gname = "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, in); err != nil {
+			return err
+		}
+	}
+}
+
+// WithParentDirs streams the tar read from r, preceding its entries with the directories above them, from
+// rootDir down, owned by uid and gid. Otherwise extracting the archive creates missing directories owned by
+// root. Like CreateTarReader, errors are returned by Read and Close, which must be called.
+func (*FS) WithParentDirs(r io.Reader, rootDir string, uid, gid int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	tr := &tarReader{PipeReader: pr, done: make(chan struct{})}
+
+	go func() {
+		defer close(tr.done)
+		tr.err = buffered(pw, func(bw io.Writer) error {
+			return writeParentDirs(bw, r, path.Clean(rootDir), uid, gid)
+		})
+		pw.CloseWithError(tr.err)
+	}()
+	return tr
+}
+
+// Gzip streams r compressed with gzip, favouring speed over size as the archive is compressed while it is
+// sent. Like CreateTarReader, errors are returned by Read and Close, which must be called.
+func (*FS) Gzip(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	tr := &tarReader{PipeReader: pr, done: make(chan struct{})}
+
+	go func() {
+		defer close(tr.done)
+		tr.err = buffered(pw, func(bw io.Writer) error {
+			gz, err := gzip.NewWriterLevel(bw, gzip.BestSpeed)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(gz, r); err != nil {
+				return err
+			}
+			return gz.Close()
+		})
+		pw.CloseWithError(tr.err)
+	}()
+	return tr
+}
+
+func writeParentDirs(w io.Writer, r io.Reader, rootDir string, uid, gid int) error {
+	in := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	written := map[string]bool{}
+	for {
+		hdr, err := in.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		var parents []string
+		for dir := path.Dir(name); !written[dir] && (dir == rootDir || strings.HasPrefix(dir, rootDir+"/")); dir = path.Dir(dir) {
+			parents = append([]string{dir}, parents...)
+		}
+		for _, dir := range parents {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0755, Uid: uid, Gid: gid}); err != nil {
+				return err
+			}
+			written[dir] = true
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			written[name] = true
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, in); err != nil {
+			return err
+		}
+	}
+}
+
+func relocatedName(tarDir, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid file path in tar %s", name)
+	}
+	return path.Join(tarDir, clean), nil
+}
+
+// checkParentDirs fails when a parent dir of the entry name, once its symlinks are resolved, is outside root.
+// Missing parent dirs are created as dirs, so they can't lead outside root.
+func checkParentDirs(root, name string) error {
+	name = strings.TrimPrefix(name, "/")
+	dir := root
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			continue
+		}
+		next := filepath.Join(dir, part)
+		fi, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if next, err = filepath.EvalSymlinks(next); err != nil {
+				return fmt.Errorf("invalid file path in tar %s: %s", name, err)
+			}
+			if next != root && !strings.HasPrefix(next, root+string(os.PathSeparator)) {
+				return fmt.Errorf("invalid file path in tar %s: a symlink leads outside of the destination", name)
+			}
+		}
+		dir = next
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package fs_test
 
 import (
 	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"github.com/fatih/color"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -15,6 +18,7 @@ import (
 	"github.com/sclevine/spec/report"
 
 	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
 )
 
 func TestFS(t *testing.T) {
@@ -98,4 +102,271 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 			}
 		}
 	})
+
+	when("#CreateTarReader", func() {
+		it("streams a tar of the dir", func() {
+			r := fs.CreateTarReader(src, "/dir-in-archive", 1234, 2345)
+			tr := tar.NewReader(r)
+			header, err := tr.Next()
+			if err != nil {
+				t.Fatalf("Failed to get next file: %s", err)
+			}
+			if header.Name != "/dir-in-archive/some-file.txt" {
+				t.Fatalf(`expected file with name /dir-in-archive/some-file.txt, got %s`, header.Name)
+			}
+			if _, err := io.Copy(ioutil.Discard, r); err != nil {
+				t.Fatalf("failed to read tar: %s", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("expected no error on close, got %s", err)
+			}
+		})
+
+		it("returns archive errors from Read and Close", func() {
+			r := fs.CreateTarReader(filepath.Join(tmpDir, "does-not-exist"), "/dir-in-archive", 0, 0)
+			if _, err := ioutil.ReadAll(r); err == nil {
+				t.Fatal("expected an error reading the tar")
+			}
+			if err := r.Close(); err == nil {
+				t.Fatal("expected an error on close")
+			}
+		})
+
+		it("only archives files accepted by the filter", func() {
+			var seen []string
+			r := fs.CreateFilteredTarReader(src, "/dir-in-archive", 0, 0, func(relPath string, fi os.FileInfo) bool {
+				seen = append(seen, relPath)
+				return relPath != "sub-dir"
+			})
+			tr := tar.NewReader(r)
+			var names []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to get next file: %s", err)
+				}
+				names = append(names, header.Name)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("expected no error on close, got %s", err)
+			}
+			if len(names) != 1 || names[0] != "/dir-in-archive/some-file.txt" {
+				t.Fatalf("expected only /dir-in-archive/some-file.txt, got %v", names)
+			}
+			for _, path := range seen {
+				if path == "sub-dir/link-file" {
+					t.Fatal("expected excluded directory not to be walked")
+				}
+			}
+		})
+
+		it("streams files larger than the archive buffer", func() {
+			contents := make([]byte, 3<<20)
+			rand.Read(contents)
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "big-file"), contents, 0644))
+
+			r := fs.CreateTarReader(tmpDir, "/dir-in-archive", 0, 0)
+			tr := tar.NewReader(r)
+			header, err := tr.Next()
+			h.AssertNil(t, err)
+			h.AssertEq(t, header.Name, "/dir-in-archive/big-file")
+			read, err := ioutil.ReadAll(tr)
+			h.AssertNil(t, err)
+			h.AssertEq(t, bytes.Equal(read, contents), true)
+			_, err = tr.Next()
+			h.AssertEq(t, err, io.EOF)
+			h.AssertNil(t, r.Close())
+		})
+
+		it("does not error when closed before the archive is read", func() {
+			r := fs.CreateTarReader(src, "/dir-in-archive", 0, 0)
+			if err := r.Close(); err != nil {
+				t.Fatalf("expected no error on close, got %s", err)
+			}
+		})
+	})
+
+	when("#Untar", func() {
+		it("rejects entries outside the dest dir", func() {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "../escaped.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}))
+			_, err := tw.Write([]byte("data"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, tw.Close())
+
+			dest := filepath.Join(tmpDir, "dest")
+			h.AssertError(t, fs.Untar(&buf, dest), "invalid file path in tar ../escaped.txt")
+			if _, err := os.Stat(filepath.Join(tmpDir, "escaped.txt")); !os.IsNotExist(err) {
+				t.Fatalf("expected no file outside the dest dir, got %v", err)
+			}
+		})
+
+		writeTar := func(headers ...*tar.Header) io.Reader {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range headers {
+				h.AssertNil(t, tw.WriteHeader(hdr))
+				if hdr.Typeflag == tar.TypeReg {
+					_, err := tw.Write([]byte("data"))
+					h.AssertNil(t, err)
+				}
+			}
+			h.AssertNil(t, tw.Close())
+			return &buf
+		}
+
+		it("extracts symlinks inside the dest dir", func() {
+			dest := filepath.Join(tmpDir, "dest")
+			h.AssertNil(t, fs.Untar(writeTar(
+				&tar.Header{Name: "lib/tool", Typeflag: tar.TypeReg, Mode: 0755, Size: 4},
+				&tar.Header{Name: "bin/tool", Typeflag: tar.TypeSymlink, Linkname: "../lib/tool"},
+			), dest))
+
+			contents, err := ioutil.ReadFile(filepath.Join(dest, "bin", "tool"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "data")
+		})
+
+		it("rejects symlinks to absolute paths", func() {
+			dest := filepath.Join(tmpDir, "dest")
+			err := fs.Untar(writeTar(
+				&tar.Header{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+				&tar.Header{Name: "etc/some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+			), dest)
+			h.AssertError(t, err, "invalid symlink in tar etc: target /etc is outside of the archive")
+		})
+
+		it("rejects symlinks leading outside the dest dir", func() {
+			dest := filepath.Join(tmpDir, "dest")
+			err := fs.Untar(writeTar(
+				&tar.Header{Name: "bin/escape", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+				&tar.Header{Name: "bin/escape/some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+			), dest)
+			h.AssertError(t, err, "invalid symlink in tar bin/escape: target ../../outside is outside of the archive")
+		})
+
+		it("does not write through chained symlinks leading outside the dest dir", func() {
+			dest := filepath.Join(tmpDir, "dest")
+			h.AssertNil(t, os.MkdirAll(filepath.Join(tmpDir, "outside"), 0755))
+			err := fs.Untar(writeTar(
+				&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				&tar.Header{Name: "dir/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				&tar.Header{Name: "dir/escape", Typeflag: tar.TypeSymlink, Linkname: "up/../outside"},
+				&tar.Header{Name: "dir/escape/some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+			), dest)
+			h.AssertError(t, err, "invalid file path in tar dir/escape/some-file: a symlink leads outside of the destination")
+			if _, err := os.Stat(filepath.Join(tmpDir, "outside", "some-file")); !os.IsNotExist(err) {
+				t.Fatalf("expected no file outside the dest dir, got %v", err)
+			}
+		})
+	})
+
+	when("#RelocateTar", func() {
+		writeTar := func(names ...string) io.Reader {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, name := range names {
+				h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4, Uid: 1000}))
+				_, err := tw.Write([]byte("data"))
+				h.AssertNil(t, err)
+			}
+			h.AssertNil(t, tw.Close())
+			return &buf
+		}
+
+		it("moves every entry beneath the tar dir", func() {
+			r := fs.RelocateTar(writeTar("some-file.txt", "./sub-dir/other-file.txt"), "/workspace/app", 1234, 2345)
+			tr := tar.NewReader(r)
+			var names []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to get next file: %s", err)
+				}
+				if header.Uid != 1234 || header.Gid != 2345 {
+					t.Fatalf("expected owner 1234:2345, got %d:%d", header.Uid, header.Gid)
+				}
+				names = append(names, header.Name)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("expected no error on close, got %s", err)
+			}
+			if len(names) != 2 || names[0] != "/workspace/app/some-file.txt" || names[1] != "/workspace/app/sub-dir/other-file.txt" {
+				t.Fatalf("expected relocated names, got %v", names)
+			}
+		})
+
+		it("rejects entries outside the archive root", func() {
+			r := fs.RelocateTar(writeTar("../escaped.txt"), "/workspace/app", 0, 0)
+			if _, err := ioutil.ReadAll(r); err == nil {
+				t.Fatal("expected an error reading the tar")
+			}
+			if err := r.Close(); err == nil {
+				t.Fatal("expected an error on close")
+			}
+		})
+	})
+
+	when("#Gzip", func() {
+		it("streams the input compressed with gzip", func() {
+			contents := bytes.Repeat([]byte("some-content"), 1<<16)
+			r := fs.Gzip(bytes.NewReader(contents))
+			var compressed bytes.Buffer
+			_, err := io.Copy(&compressed, r)
+			h.AssertNil(t, err)
+			h.AssertNil(t, r.Close())
+			if compressed.Len() >= len(contents) {
+				t.Fatalf("expected the stream to be compressed, got %d bytes for %d", compressed.Len(), len(contents))
+			}
+
+			gz, err := gzip.NewReader(&compressed)
+			h.AssertNil(t, err)
+			read, err := ioutil.ReadAll(gz)
+			h.AssertNil(t, err)
+			h.AssertEq(t, bytes.Equal(read, contents), true)
+		})
+	})
+
+	when("#WithParentDirs", func() {
+		it("adds the directories beneath the root dir owned by the uid and gid", func() {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, name := range []string{"/workspace/app/some-file.txt", "/workspace/app/sub-dir/other-file.txt", "/workspace/app/sub-dir/last-file.txt"} {
+				h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4, Uid: 1234, Gid: 2345}))
+				_, err := tw.Write([]byte("data"))
+				h.AssertNil(t, err)
+			}
+			h.AssertNil(t, tw.Close())
+
+			r := fs.WithParentDirs(&buf, "/workspace/app", 1234, 2345)
+			tr := tar.NewReader(r)
+			var names []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				h.AssertNil(t, err)
+				if header.Uid != 1234 || header.Gid != 2345 {
+					t.Fatalf("expected owner 1234:2345, got %d:%d", header.Uid, header.Gid)
+				}
+				names = append(names, header.Name)
+			}
+			h.AssertNil(t, r.Close())
+			h.AssertEq(t, names, []string{
+				"/workspace/app",
+				"/workspace/app/some-file.txt",
+				"/workspace/app/sub-dir",
+				"/workspace/app/sub-dir/other-file.txt",
+				"/workspace/app/sub-dir/last-file.txt",
+			})
+		})
+	})
 }
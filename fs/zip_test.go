@@ -0,0 +1,72 @@
+package fs_test
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/fs"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestUnzip(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "unzip", testUnzip, spec.Report(report.Terminal{}))
+}
+
+func testUnzip(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "unzip-test")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	writeZip := func(entries map[string]string) string {
+		path := filepath.Join(tmpDir, "app.jar")
+		f, err := os.Create(path)
+		h.AssertNil(t, err)
+		defer f.Close()
+		zw := zip.NewWriter(f)
+		for name, contents := range entries {
+			w, err := zw.Create(name)
+			h.AssertNil(t, err)
+			_, err = w.Write([]byte(contents))
+			h.AssertNil(t, err)
+		}
+		h.AssertNil(t, zw.Close())
+		return path
+	}
+
+	it("extracts the archive into the destination", func() {
+		path := writeZip(map[string]string{
+			"META-INF/MANIFEST.MF":  "Main-Class: com.example.App",
+			"com/example/App.class": "some-class",
+		})
+		dest := filepath.Join(tmpDir, "dest")
+
+		h.AssertNil(t, (&fs.FS{}).Unzip(path, dest))
+
+		h.AssertDirContainsFileWithContents(t, dest, "META-INF/MANIFEST.MF", "Main-Class: com.example.App")
+		h.AssertDirContainsFileWithContents(t, dest, "com/example/App.class", "some-class")
+	})
+
+	it("rejects entries outside of the destination", func() {
+		path := writeZip(map[string]string{"../evil": "some-contents"})
+
+		err := (&fs.FS{}).Unzip(path, filepath.Join(tmpDir, "dest"))
+
+		h.AssertError(t, err, "zip entry ../evil is outside of the destination")
+	})
+}
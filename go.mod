@@ -6,6 +6,7 @@ require (
 	github.com/dgodd/dockerdial v1.0.1
 	github.com/docker/docker v0.7.3-0.20181027010111-b8e87cfdad8d
 	github.com/docker/go-connections v0.4.0
+	github.com/docker/go-units v0.3.3
 	github.com/fatih/color v1.7.0
 	github.com/golang/mock v1.2.0
 	github.com/google/go-cmp v0.2.0
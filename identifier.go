@@ -0,0 +1,95 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// Identifier identifies an image produced by pack. Images saved to the daemon are identified by their
+// image ID (LocalImageID), published images by a reference to their digest (RemoteDigestReference).
+type Identifier interface {
+	fmt.Stringer
+	// Kind tells which of the two an identifier is
+	Kind() IdentifierKind
+}
+
+// IdentifierKind is the kind of an Identifier
+type IdentifierKind string
+
+const (
+	// LocalImageIDKind is the kind of LocalImageID
+	LocalImageIDKind IdentifierKind = "image-id"
+	// RemoteDigestReferenceKind is the kind of RemoteDigestReference
+	RemoteDigestReferenceKind IdentifierKind = "digest-reference"
+)
+
+// LocalImageID is the ID of an image in the docker daemon, e.g. sha256:...
+type LocalImageID string
+
+func (id LocalImageID) String() string {
+	return string(id)
+}
+
+func (LocalImageID) Kind() IdentifierKind {
+	return LocalImageIDKind
+}
+
+// RemoteDigestReference references a published image by digest, e.g. registry.com/org/app@sha256:...
+type RemoteDigestReference struct {
+	Repository string
+	Digest     string
+}
+
+func (r RemoteDigestReference) String() string {
+	return r.Repository + "@" + r.Digest
+}
+
+func (RemoteDigestReference) Kind() IdentifierKind {
+	return RemoteDigestReferenceKind
+}
+
+// localImageID returns the ID of the image repoName on the daemon. The ID is read from the daemon rather
+// than taken from saving the image, which doesn't always return it.
+func localImageID(ctx context.Context, cli Docker, repoName string) (Identifier, error) {
+	i, _, err := cli.ImageInspectWithRaw(ctx, repoName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "inspecting image %s", style.Symbol(repoName))
+	}
+	if i.ID == "" {
+		return nil, fmt.Errorf("daemon returned no ID for image %s", style.Symbol(repoName))
+	}
+	return LocalImageID(i.ID), nil
+}
+
+// remoteDigestReference returns the reference to the published image repoName by its digest
+func remoteDigestReference(repoName, digest string) (Identifier, error) {
+	if digest == "" {
+		return nil, fmt.Errorf("registry returned no digest for image %s", style.Symbol(repoName))
+	}
+	if !strings.Contains(digest, ":") {
+		digest = "sha256:" + digest
+	}
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return RemoteDigestReference{Repository: ref.Context().Name(), Digest: digest}, nil
+}
+
+// identify sets the Identifier of the image produced by the build, where saved is the value returned by
+// saving it
+func (b *BuildConfig) identify(saved string) error {
+	var err error
+	if b.Publish {
+		b.Identifier, err = remoteDigestReference(b.RepoName, saved)
+	} else {
+		b.Identifier, err = localImageID(b.context(), b.Cli, b.RepoName)
+	}
+	return err
+}
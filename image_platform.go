@@ -0,0 +1,100 @@
+package pack
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/buildpack/lifecycle/image"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+var platformRegexp = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+func validatePlatform(platform string) error {
+	if platform != "" && !platformRegexp.MatchString(platform) {
+		return fmt.Errorf("invalid platform %s: must be of the form 'os/arch[/variant]', e.g. 'linux/arm64'", style.Symbol(platform))
+	}
+	return nil
+}
+
+// platformImageFactory pulls images for platform, e.g. linux/arm64, instead of the daemon's own platform.
+// Containers are created from the pulled images, so the lifecycle runs on platform when the daemon
+// can emulate it.
+type platformImageFactory struct {
+	ImageFactory
+	cli      Docker
+	platform string
+}
+
+func (f *platformImageFactory) NewLocal(repoName string, pull bool) (image.Image, error) {
+	if pull {
+		if err := pullImage(context.Background(), f.cli, repoName, f.platform); err != nil {
+			return nil, errors.Wrapf(err, "pulling image %s for platform %s", style.Symbol(repoName), style.Symbol(f.platform))
+		}
+	}
+	return f.ImageFactory.NewLocal(repoName, false)
+}
+
+func pullImage(ctx context.Context, cli Docker, repoName, platform string) error {
+	auth, err := registryAuth(repoName)
+	if err != nil {
+		return err
+	}
+	rc, err := cli.ImagePull(ctx, repoName, dockertypes.ImagePullOptions{RegistryAuth: auth, Platform: platform})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// the pull only completes once its progress is read
+	_, err = io.Copy(ioutil.Discard, rc)
+	return err
+}
+
+// registryAuth returns the credentials for the registry of repoName in the encoding docker expects,
+// or "" for anonymous access
+func registryAuth(repoName string) (string, error) {
+	header, err := authHeader(repoName)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", nil
+	}
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+	auth, err := json.Marshal(dockertypes.AuthConfig{Username: parts[0], Password: parts[1], ServerAddress: ref.Context().RegistryStr()})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(auth), nil
+}
+
+// validatePlatformImage checks that the image on the daemon, used for containers or as the base of the app
+// image, is an image for platform
+func validatePlatformImage(inspect dockertypes.ImageInspect, kind, repoName, platform string) error {
+	parts := strings.SplitN(platform, "/", 3)
+	if inspect.Os != parts[0] || inspect.Architecture != parts[1] {
+		return fmt.Errorf("%s image %s is a %s/%s image, not an image for platform %s: use %s to pull it for the platform", kind, style.Symbol(repoName), inspect.Os, inspect.Architecture, style.Symbol(platform), style.Symbol("--pull-policy always"))
+	}
+	return nil
+}
@@ -6,21 +6,36 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/buildpack/lifecycle/image"
+
+	"github.com/buildpack/pack/fs"
 )
 
 //go:generate mockgen -package mocks -destination mocks/docker.go github.com/buildpack/pack Docker
 type Docker interface {
 	RunContainer(ctx context.Context, id string, stdout io.Writer, stderr io.Writer) error
+	RunInteractive(ctx context.Context, id string, stdin io.Reader, stdout io.Writer) error
 	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+	VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error)
+	VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error)
 	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error)
 	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerCommit(ctx context.Context, container string, options types.ContainerCommitOptions) (types.IDResponse, error)
 	CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options types.CopyToContainerOptions) error
 	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
 	ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error)
 	ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error)
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
+	ImageSave(ctx context.Context, imageIDs []string) (io.ReadCloser, error)
+	ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error)
 }
 
 //go:generate mockgen -package mocks -destination mocks/task.go github.com/buildpack/pack Task
@@ -31,8 +46,13 @@ type Task interface {
 //go:generate mockgen -package mocks -destination mocks/fs.go github.com/buildpack/pack FS
 type FS interface {
 	CreateTarFile(tarFile, srcDir, tarDir string, uid, gid int) error
-	CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error)
+	CreateTarReader(srcDir, tarDir string, uid, gid int) io.ReadCloser
+	CreateFilteredTarReader(srcDir, tarDir string, uid, gid int, include fs.IncludeFunc) io.ReadCloser
+	RelocateTar(r io.Reader, tarDir string, uid, gid int) io.ReadCloser
+	WithParentDirs(r io.Reader, rootDir string, uid, gid int) io.ReadCloser
+	Gzip(r io.Reader) io.ReadCloser
 	Untar(r io.Reader, dest string) error
+	Unzip(path, dest string) error
 	CreateSingleFileTar(path, txt string) (io.Reader, error)
 }
 
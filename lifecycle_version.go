@@ -0,0 +1,82 @@
+package pack
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/style"
+)
+
+// lifecycleReleaseURL is the URL of the lifecycle release archive for a version, formatted with the version
+const lifecycleReleaseURL = "https://github.com/buildpack/lifecycle/releases/download/v%[1]s/lifecycle-v%[1]s+linux.x86-64.tgz"
+
+var lifecycleVersionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// downloadLifecycle downloads a lifecycle release into cacheDir. It returns the directory holding the
+// lifecycle binaries and the platform API the release declares in its lifecycle.toml.
+func downloadLifecycle(logger *logging.Logger, fs FS, cacheDir, version string) (string, string, error) {
+	dir, err := downloadAndExtract(logger, fs, cacheDir, fmt.Sprintf(lifecycleReleaseURL, version))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "downloading lifecycle %s", style.Symbol(version))
+	}
+
+	apiVersion := legacyPlatformAPI
+	var descriptor struct {
+		API struct {
+			Platform string `toml:"platform"`
+		} `toml:"api"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(dir, "lifecycle.toml"), &descriptor); err == nil && descriptor.API.Platform != "" {
+		apiVersion = descriptor.API.Platform
+	}
+
+	// release archives keep the binaries in a lifecycle dir
+	if fi, err := os.Stat(filepath.Join(dir, "lifecycle")); err == nil && fi.IsDir() {
+		dir = filepath.Join(dir, "lifecycle")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "detector")); err != nil {
+		return "", "", fmt.Errorf("lifecycle %s release has no %s binary", style.Symbol(version), style.Symbol("detector"))
+	}
+	return dir, apiVersion, nil
+}
+
+// prepareDownloadedLifecycleVolume copies the binaries of the downloaded lifecycle to a volume mounted
+// over the builder's lifecycle dir
+func (b *BuildConfig) prepareDownloadedLifecycleVolume(ctx context.Context) error {
+	volume := fmt.Sprintf("pack-lifecycle-%x", md5.Sum([]byte(b.LifecycleVersion)))
+	// the volume is mounted outside the builder's lifecycle dir so it is not populated from it
+	const mountDir = "/pack-lifecycle"
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image: b.Builder,
+		Cmd:   []string{"true"},
+	}, &container.HostConfig{
+		Binds: []string{fmt.Sprintf("%s:%s:", volume, mountDir)},
+	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create lifecycle container")
+	}
+	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+
+	tr := b.FS.CreateTarReader(b.lifecycleBins, mountDir, 0, 0)
+	if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+		tr.Close()
+		return errors.Wrapf(err, "copying lifecycle %s to volume", style.Symbol(b.LifecycleVersion))
+	}
+	if err := tr.Close(); err != nil {
+		return errors.Wrapf(err, "copying lifecycle %s to volume", style.Symbol(b.LifecycleVersion))
+	}
+
+	b.lifecycleVolume = volume
+	b.Logger.Verbose("Using lifecycle volume %s", style.Symbol(b.lifecycleVolume))
+	return nil
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// lineFilter collapses consecutive identical lines and limits the number of lines printed each second
+type lineFilter struct {
+	mu                sync.Mutex
+	maxLinesPerSecond int
+	now               func() time.Time
+
+	last     string
+	repeats  int
+	window   time.Time
+	printed  int
+	dropped  int
+	hasLines bool
+}
+
+func newLineFilter(maxLinesPerSecond int) *lineFilter {
+	return &lineFilter{maxLinesPerSecond: maxLinesPerSecond, now: time.Now}
+}
+
+// filter returns the lines to print in place of line
+func (f *lineFilter) filter(line string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.hasLines && line == f.last {
+		f.repeats++
+		return nil
+	}
+	out := f.summary()
+	f.last, f.hasLines = line, true
+
+	if f.maxLinesPerSecond > 0 {
+		if now := f.now(); now.Sub(f.window) >= time.Second {
+			out = append(out, f.dropSummary()...)
+			f.window, f.printed = now, 0
+		}
+		if f.printed >= f.maxLinesPerSecond {
+			f.dropped++
+			return out
+		}
+		f.printed++
+	}
+	return append(out, line)
+}
+
+func (f *lineFilter) flush() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append(f.summary(), f.dropSummary()...)
+}
+
+func (f *lineFilter) summary() []string {
+	if f.repeats == 0 {
+		return nil
+	}
+	out := []string{fmt.Sprintf("(previous line repeated %d more times)", f.repeats)}
+	f.repeats = 0
+	return out
+}
+
+func (f *lineFilter) dropSummary() []string {
+	if f.dropped == 0 {
+		return nil
+	}
+	out := []string{fmt.Sprintf("(%d lines suppressed)", f.dropped)}
+	f.dropped = 0
+	return out
+}
@@ -7,19 +7,43 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"regexp"
+	"strings"
 )
 
 type Logger struct {
 	verbose bool
 	out     *logWriter
 	err     *logWriter
+	// quietOut and quietErr only write to the log file, they replace verbose writers when not verbose
+	quietOut *logWriter
+	quietErr *logWriter
 }
 
 func NewLogger(stdout, stderr io.Writer, verbose, timestamps bool) *Logger {
 	return &Logger{
-		verbose: verbose,
-		out:     newLogWriter(stdout, timestamps),
-		err:     newLogWriter(stderr, timestamps),
+		verbose:  verbose,
+		out:      newLogWriter(stdout, timestamps),
+		err:      newLogWriter(stderr, timestamps),
+		quietOut: newLogWriter(ioutil.Discard, false),
+		quietErr: newLogWriter(ioutil.Discard, false),
+	}
+}
+
+// SetLogFile additionally writes all output to w, including verbose output and lines removed by Condense
+func (l *Logger) SetLogFile(w io.Writer) {
+	file := log.New(w, "", log.LstdFlags)
+	for _, lw := range []*logWriter{l.out, l.err, l.quietOut, l.quietErr} {
+		lw.file = file
+	}
+}
+
+// Condense collapses consecutive identical lines written to prefixed writers, and prints at most
+// maxLinesPerSecond of their lines each second. A maxLinesPerSecond of 0 disables rate limiting.
+func (l *Logger) Condense(maxLinesPerSecond int) {
+	for _, lw := range []*logWriter{l.out, l.err, l.quietOut, l.quietErr} {
+		lw.condense = true
+		lw.maxLinesPerSecond = maxLinesPerSecond
 	}
 }
 
@@ -32,29 +56,48 @@ func (l *Logger) Info(format string, a ...interface{}) {
 }
 
 func (l *Logger) Verbose(format string, a ...interface{}) {
-	if l.verbose {
-		l.printf(l.out, format, a...)
-	}
+	l.printf(l.VerboseWriter(), format, a...)
 }
 
 func (l *Logger) Error(format string, a ...interface{}) {
 	l.printf(l.err, style.Error("ERROR: ")+format, a...)
 }
 
+func (l *Logger) Warn(format string, a ...interface{}) {
+	l.printf(l.err, style.Warn("WARNING: ")+format, a...)
+}
+
 func (l *Logger) Tip(format string, a ...interface{}) {
 	l.printf(l.out, style.Tip("Tip: ")+format, a...)
 }
 
+// WithPrefix returns a logger prefixing every line with prefix, so the output of builds running at once can be
+// told apart
+func (l *Logger) WithPrefix(prefix string) *Logger {
+	return &Logger{
+		verbose:  l.verbose,
+		out:      l.out.WithPrefix(prefix),
+		err:      l.err.WithPrefix(prefix),
+		quietOut: l.quietOut.WithPrefix(prefix),
+		quietErr: l.quietErr.WithPrefix(prefix),
+	}
+}
+
+// IsVerbose reports whether verbose output is shown, so callers can skip work only needed for it
+func (l *Logger) IsVerbose() bool {
+	return l.verbose
+}
+
 func (l *Logger) VerboseWriter() *logWriter {
 	if !l.verbose {
-		return nullLogWriter
+		return l.quietOut
 	}
 	return l.out
 }
 
 func (l *Logger) VerboseErrorWriter() *logWriter {
 	if !l.verbose {
-		return nullLogWriter
+		return l.quietErr
 	}
 	return l.err
 }
@@ -62,9 +105,14 @@ func (l *Logger) VerboseErrorWriter() *logWriter {
 type logWriter struct {
 	prefix string
 	log    *log.Logger
+	file   *log.Logger
+
+	condense          bool
+	maxLinesPerSecond int
+	filter            *lineFilter
 }
 
-var nullLogWriter = newLogWriter(ioutil.Discard, false)
+var ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 
 func newLogWriter(out io.Writer, timestamps bool) *logWriter {
 	flags := 0
@@ -88,13 +136,41 @@ func newLogWriter(out io.Writer, timestamps bool) *logWriter {
 }
 
 func (w *logWriter) WithPrefix(prefix string) *logWriter {
-	return &logWriter{
-		log:    w.log,
-		prefix: fmt.Sprintf("%s[%s] ", w.prefix, style.Prefix(prefix)),
+	pw := &logWriter{
+		log:               w.log,
+		file:              w.file,
+		prefix:            fmt.Sprintf("%s[%s] ", w.prefix, style.Prefix(prefix)),
+		condense:          w.condense,
+		maxLinesPerSecond: w.maxLinesPerSecond,
+	}
+	if w.condense {
+		pw.filter = newLineFilter(w.maxLinesPerSecond)
 	}
+	return pw
 }
 
 func (w *logWriter) Write(p []byte) (n int, err error) {
-	w.log.Print(w.prefix + string(p))
+	if w.file != nil {
+		w.file.Print(strings.TrimLeft(ansiCodes.ReplaceAllString(w.prefix+string(p), ""), " "))
+	}
+	if w.filter == nil {
+		w.log.Print(w.prefix + string(p))
+		return len(p), nil
+	}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(string(p), "\n"), "\n") {
+		for _, out := range w.filter.filter(strings.TrimSuffix(line, "\n")) {
+			w.log.Print(w.prefix + out)
+		}
+	}
 	return len(p), nil
 }
+
+// Flush prints a summary of any lines still held back by Condense
+func (w *logWriter) Flush() {
+	if w.filter == nil {
+		return
+	}
+	for _, out := range w.filter.flush() {
+		w.log.Print(w.prefix + out)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -46,6 +47,10 @@ func testLogging(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, ignoreEmptyTimestampColorCodes(outBuf.String()), "Some text\n")
 			})
 
+			it("is verbose", func() {
+				h.AssertEq(t, logger.IsVerbose(), true)
+			})
+
 			it("returns real err writer", func() {
 				writer := logger.VerboseErrorWriter()
 				writer.Write([]byte("Some error\n"))
@@ -70,6 +75,10 @@ func testLogging(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, outBuf.String(), "")
 			})
 
+			it("is not verbose", func() {
+				h.AssertEq(t, logger.IsVerbose(), false)
+			})
+
 			it("returns discard err writer", func() {
 				writer := logger.VerboseErrorWriter()
 				writer.Write([]byte("some-text\n"))
@@ -132,6 +141,14 @@ func testLogging(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("#Warn", func() {
+			it("displays styled warning message to error buffer", func() {
+				logger.Warn("Something looks off")
+
+				h.AssertEq(t, ignoreEmptyTimestampColorCodes(errBuf.String()), style.Warn("WARNING: ")+"Something looks off\n")
+			})
+		})
+
 		when("#Tip", func() {
 			it("displays styled tip message", func() {
 				logger.Tip("This is a tip")
@@ -147,7 +164,81 @@ func testLogging(t *testing.T, when spec.G, it spec.S) {
 			writer.WithPrefix("Some prefix").Write([]byte("Some text\n"))
 			h.AssertEq(t, ignoreEmptyTimestampColorCodes(outBuf.String()), fmt.Sprintf("[%s] Some text\n", style.Prefix("Some prefix")))
 		})
+
+		it("returns a logger prefixing every line", func() {
+			prefixed := logging.NewLogger(&outBuf, &errBuf, true, false).WithPrefix("some/app")
+			prefixed.Info("Some info")
+			prefixed.VerboseWriter().WithPrefix("phase").Write([]byte("Some text\n"))
+			h.AssertEq(t, ignoreEmptyTimestampColorCodes(outBuf.String()), fmt.Sprintf("[%s] Some info\n[%s] [%s] Some text\n", style.Prefix("some/app"), style.Prefix("some/app"), style.Prefix("phase")))
+		})
 	})
+
+	when("#Condense", func() {
+		it.Before(func() {
+			logger = logging.NewLogger(&outBuf, &errBuf, true, false)
+		})
+
+		it("collapses consecutive identical lines of prefixed writers", func() {
+			logger.Condense(0)
+			writer := logger.VerboseWriter().WithPrefix("phase")
+			writer.Write([]byte("Waiting for lock\n"))
+			writer.Write([]byte("Waiting for lock\nWaiting for lock\n"))
+			writer.Write([]byte("Done\n"))
+			writer.Write([]byte("Done\n"))
+			writer.Flush()
+
+			output := stripColor(outBuf.String())
+			h.AssertEq(t, output, "[phase] Waiting for lock\n"+
+				"[phase] (previous line repeated 2 more times)\n"+
+				"[phase] Done\n"+
+				"[phase] (previous line repeated 1 more times)\n")
+		})
+
+		it("limits the number of lines printed each second", func() {
+			logger.Condense(3)
+			writer := logger.VerboseWriter().WithPrefix("phase")
+			for i := 0; i < 10; i++ {
+				writer.Write([]byte(fmt.Sprintf("line %d\n", i)))
+			}
+			writer.Flush()
+
+			output := stripColor(outBuf.String())
+			h.AssertEq(t, output, "[phase] line 0\n[phase] line 1\n[phase] line 2\n[phase] (7 lines suppressed)\n")
+		})
+
+		it("does not condense unprefixed output", func() {
+			logger.Condense(1)
+			logger.Info("Some text")
+			logger.Info("Some text")
+			h.AssertEq(t, stripColor(outBuf.String()), "Some text\nSome text\n")
+		})
+	})
+
+	when("#SetLogFile", func() {
+		var fileBuf bytes.Buffer
+
+		it("writes full, unstyled output to the log file", func() {
+			logger = logging.NewLogger(&outBuf, &errBuf, false, false)
+			logger.SetLogFile(&fileBuf)
+			logger.Condense(1)
+
+			logger.Verbose("Some verbose output")
+			logger.Error("Something went wrong!")
+			writer := logger.VerboseWriter().WithPrefix("phase")
+			writer.Write([]byte("line 1\n"))
+			writer.Write([]byte("line 2\n"))
+
+			h.AssertEq(t, outBuf.String(), "")
+			h.AssertContains(t, fileBuf.String(), "Some verbose output\n")
+			h.AssertContains(t, fileBuf.String(), "ERROR: Something went wrong!\n")
+			h.AssertContains(t, fileBuf.String(), "[phase] line 1\n")
+			h.AssertContains(t, fileBuf.String(), "[phase] line 2\n")
+		})
+	})
+}
+
+func stripColor(s string) string {
+	return regexp.MustCompile(`\x1b\[[0-9;]*m`).ReplaceAllString(s, "")
 }
 
 func ignoreEmptyTimestampColorCodes(s string) string {
@@ -8,7 +8,9 @@ import (
 	context "context"
 	types "github.com/docker/docker/api/types"
 	container "github.com/docker/docker/api/types/container"
+	filters "github.com/docker/docker/api/types/filters"
 	network "github.com/docker/docker/api/types/network"
+	volume "github.com/docker/docker/api/types/volume"
 	gomock "github.com/golang/mock/gomock"
 	io "io"
 	reflect "reflect"
@@ -37,6 +39,19 @@ func (m *MockDocker) EXPECT() *MockDockerMockRecorder {
 	return m.recorder
 }
 
+// ContainerCommit mocks base method
+func (m *MockDocker) ContainerCommit(arg0 context.Context, arg1 string, arg2 types.ContainerCommitOptions) (types.IDResponse, error) {
+	ret := m.ctrl.Call(m, "ContainerCommit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.IDResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerCommit indicates an expected call of ContainerCommit
+func (mr *MockDockerMockRecorder) ContainerCommit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerCommit", reflect.TypeOf((*MockDocker)(nil).ContainerCommit), arg0, arg1, arg2)
+}
+
 // ContainerCreate mocks base method
 func (m *MockDocker) ContainerCreate(arg0 context.Context, arg1 *container.Config, arg2 *container.HostConfig, arg3 *network.NetworkingConfig, arg4 string) (container.ContainerCreateCreatedBody, error) {
 	ret := m.ctrl.Call(m, "ContainerCreate", arg0, arg1, arg2, arg3, arg4)
@@ -50,6 +65,19 @@ func (mr *MockDockerMockRecorder) ContainerCreate(arg0, arg1, arg2, arg3, arg4 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerCreate", reflect.TypeOf((*MockDocker)(nil).ContainerCreate), arg0, arg1, arg2, arg3, arg4)
 }
 
+// ContainerInspect mocks base method
+func (m *MockDocker) ContainerInspect(arg0 context.Context, arg1 string) (types.ContainerJSON, error) {
+	ret := m.ctrl.Call(m, "ContainerInspect", arg0, arg1)
+	ret0, _ := ret[0].(types.ContainerJSON)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerInspect indicates an expected call of ContainerInspect
+func (mr *MockDockerMockRecorder) ContainerInspect(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerInspect", reflect.TypeOf((*MockDocker)(nil).ContainerInspect), arg0, arg1)
+}
+
 // ContainerRemove mocks base method
 func (m *MockDocker) ContainerRemove(arg0 context.Context, arg1 string, arg2 types.ContainerRemoveOptions) error {
 	ret := m.ctrl.Call(m, "ContainerRemove", arg0, arg1, arg2)
@@ -62,6 +90,18 @@ func (mr *MockDockerMockRecorder) ContainerRemove(arg0, arg1, arg2 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerRemove", reflect.TypeOf((*MockDocker)(nil).ContainerRemove), arg0, arg1, arg2)
 }
 
+// ContainerStart mocks base method
+func (m *MockDocker) ContainerStart(arg0 context.Context, arg1 string, arg2 types.ContainerStartOptions) error {
+	ret := m.ctrl.Call(m, "ContainerStart", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ContainerStart indicates an expected call of ContainerStart
+func (mr *MockDockerMockRecorder) ContainerStart(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerStart", reflect.TypeOf((*MockDocker)(nil).ContainerStart), arg0, arg1, arg2)
+}
+
 // CopyFromContainer mocks base method
 func (m *MockDocker) CopyFromContainer(arg0 context.Context, arg1, arg2 string) (io.ReadCloser, types.ContainerPathStat, error) {
 	ret := m.ctrl.Call(m, "CopyFromContainer", arg0, arg1, arg2)
@@ -115,6 +155,71 @@ func (mr *MockDockerMockRecorder) ImageInspectWithRaw(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageInspectWithRaw", reflect.TypeOf((*MockDocker)(nil).ImageInspectWithRaw), arg0, arg1)
 }
 
+// ImageLoad mocks base method
+func (m *MockDocker) ImageLoad(arg0 context.Context, arg1 io.Reader, arg2 bool) (types.ImageLoadResponse, error) {
+	ret := m.ctrl.Call(m, "ImageLoad", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.ImageLoadResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImageLoad indicates an expected call of ImageLoad
+func (mr *MockDockerMockRecorder) ImageLoad(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageLoad", reflect.TypeOf((*MockDocker)(nil).ImageLoad), arg0, arg1, arg2)
+}
+
+// ImageList mocks base method
+func (m *MockDocker) ImageList(arg0 context.Context, arg1 types.ImageListOptions) ([]types.ImageSummary, error) {
+	ret := m.ctrl.Call(m, "ImageList", arg0, arg1)
+	ret0, _ := ret[0].([]types.ImageSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImageList indicates an expected call of ImageList
+func (mr *MockDockerMockRecorder) ImageList(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageList", reflect.TypeOf((*MockDocker)(nil).ImageList), arg0, arg1)
+}
+
+// ImagePull mocks base method
+func (m *MockDocker) ImagePull(arg0 context.Context, arg1 string, arg2 types.ImagePullOptions) (io.ReadCloser, error) {
+	ret := m.ctrl.Call(m, "ImagePull", arg0, arg1, arg2)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImagePull indicates an expected call of ImagePull
+func (mr *MockDockerMockRecorder) ImagePull(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImagePull", reflect.TypeOf((*MockDocker)(nil).ImagePull), arg0, arg1, arg2)
+}
+
+// ImageRemove mocks base method
+func (m *MockDocker) ImageRemove(arg0 context.Context, arg1 string, arg2 types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	ret := m.ctrl.Call(m, "ImageRemove", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.ImageDeleteResponseItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImageRemove indicates an expected call of ImageRemove
+func (mr *MockDockerMockRecorder) ImageRemove(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageRemove", reflect.TypeOf((*MockDocker)(nil).ImageRemove), arg0, arg1, arg2)
+}
+
+// ImageSave mocks base method
+func (m *MockDocker) ImageSave(arg0 context.Context, arg1 []string) (io.ReadCloser, error) {
+	ret := m.ctrl.Call(m, "ImageSave", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImageSave indicates an expected call of ImageSave
+func (mr *MockDockerMockRecorder) ImageSave(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageSave", reflect.TypeOf((*MockDocker)(nil).ImageSave), arg0, arg1)
+}
+
 // RunContainer mocks base method
 func (m *MockDocker) RunContainer(arg0 context.Context, arg1 string, arg2, arg3 io.Writer) error {
 	ret := m.ctrl.Call(m, "RunContainer", arg0, arg1, arg2, arg3)
@@ -127,6 +232,44 @@ func (mr *MockDockerMockRecorder) RunContainer(arg0, arg1, arg2, arg3 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunContainer", reflect.TypeOf((*MockDocker)(nil).RunContainer), arg0, arg1, arg2, arg3)
 }
 
+// RunInteractive mocks base method
+func (m *MockDocker) RunInteractive(arg0 context.Context, arg1 string, arg2 io.Reader, arg3 io.Writer) error {
+	ret := m.ctrl.Call(m, "RunInteractive", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunInteractive indicates an expected call of RunInteractive
+func (mr *MockDockerMockRecorder) RunInteractive(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunInteractive", reflect.TypeOf((*MockDocker)(nil).RunInteractive), arg0, arg1, arg2, arg3)
+}
+
+// VolumeCreate mocks base method
+func (m *MockDocker) VolumeCreate(arg0 context.Context, arg1 volume.VolumeCreateBody) (types.Volume, error) {
+	ret := m.ctrl.Call(m, "VolumeCreate", arg0, arg1)
+	ret0, _ := ret[0].(types.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VolumeCreate indicates an expected call of VolumeCreate
+func (mr *MockDockerMockRecorder) VolumeCreate(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VolumeCreate", reflect.TypeOf((*MockDocker)(nil).VolumeCreate), arg0, arg1)
+}
+
+// VolumeList mocks base method
+func (m *MockDocker) VolumeList(arg0 context.Context, arg1 filters.Args) (volume.VolumeListOKBody, error) {
+	ret := m.ctrl.Call(m, "VolumeList", arg0, arg1)
+	ret0, _ := ret[0].(volume.VolumeListOKBody)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VolumeList indicates an expected call of VolumeList
+func (mr *MockDockerMockRecorder) VolumeList(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VolumeList", reflect.TypeOf((*MockDocker)(nil).VolumeList), arg0, arg1)
+}
+
 // VolumeRemove mocks base method
 func (m *MockDocker) VolumeRemove(arg0 context.Context, arg1 string, arg2 bool) error {
 	ret := m.ctrl.Call(m, "VolumeRemove", arg0, arg1, arg2)
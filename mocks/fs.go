@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	fs "github.com/buildpack/pack/fs"
 	gomock "github.com/golang/mock/gomock"
 	io "io"
 	reflect "reflect"
@@ -33,6 +34,18 @@ func (m *MockFS) EXPECT() *MockFSMockRecorder {
 	return m.recorder
 }
 
+// CreateFilteredTarReader mocks base method
+func (m *MockFS) CreateFilteredTarReader(arg0, arg1 string, arg2, arg3 int, arg4 fs.IncludeFunc) io.ReadCloser {
+	ret := m.ctrl.Call(m, "CreateFilteredTarReader", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(io.ReadCloser)
+	return ret0
+}
+
+// CreateFilteredTarReader indicates an expected call of CreateFilteredTarReader
+func (mr *MockFSMockRecorder) CreateFilteredTarReader(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFilteredTarReader", reflect.TypeOf((*MockFS)(nil).CreateFilteredTarReader), arg0, arg1, arg2, arg3, arg4)
+}
+
 // CreateSingleFileTar mocks base method
 func (m *MockFS) CreateSingleFileTar(arg0, arg1 string) (io.Reader, error) {
 	ret := m.ctrl.Call(m, "CreateSingleFileTar", arg0, arg1)
@@ -59,11 +72,10 @@ func (mr *MockFSMockRecorder) CreateTarFile(arg0, arg1, arg2, arg3, arg4 interfa
 }
 
 // CreateTarReader mocks base method
-func (m *MockFS) CreateTarReader(arg0, arg1 string, arg2, arg3 int) (io.Reader, chan error) {
+func (m *MockFS) CreateTarReader(arg0, arg1 string, arg2, arg3 int) io.ReadCloser {
 	ret := m.ctrl.Call(m, "CreateTarReader", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].(io.Reader)
-	ret1, _ := ret[1].(chan error)
-	return ret0, ret1
+	ret0, _ := ret[0].(io.ReadCloser)
+	return ret0
 }
 
 // CreateTarReader indicates an expected call of CreateTarReader
@@ -71,6 +83,30 @@ func (mr *MockFSMockRecorder) CreateTarReader(arg0, arg1, arg2, arg3 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTarReader", reflect.TypeOf((*MockFS)(nil).CreateTarReader), arg0, arg1, arg2, arg3)
 }
 
+// Gzip mocks base method
+func (m *MockFS) Gzip(arg0 io.Reader) io.ReadCloser {
+	ret := m.ctrl.Call(m, "Gzip", arg0)
+	ret0, _ := ret[0].(io.ReadCloser)
+	return ret0
+}
+
+// Gzip indicates an expected call of Gzip
+func (mr *MockFSMockRecorder) Gzip(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Gzip", reflect.TypeOf((*MockFS)(nil).Gzip), arg0)
+}
+
+// RelocateTar mocks base method
+func (m *MockFS) RelocateTar(arg0 io.Reader, arg1 string, arg2, arg3 int) io.ReadCloser {
+	ret := m.ctrl.Call(m, "RelocateTar", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(io.ReadCloser)
+	return ret0
+}
+
+// RelocateTar indicates an expected call of RelocateTar
+func (mr *MockFSMockRecorder) RelocateTar(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RelocateTar", reflect.TypeOf((*MockFS)(nil).RelocateTar), arg0, arg1, arg2, arg3)
+}
+
 // Untar mocks base method
 func (m *MockFS) Untar(arg0 io.Reader, arg1 string) error {
 	ret := m.ctrl.Call(m, "Untar", arg0, arg1)
@@ -82,3 +118,27 @@ func (m *MockFS) Untar(arg0 io.Reader, arg1 string) error {
 func (mr *MockFSMockRecorder) Untar(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Untar", reflect.TypeOf((*MockFS)(nil).Untar), arg0, arg1)
 }
+
+// Unzip mocks base method
+func (m *MockFS) Unzip(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "Unzip", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unzip indicates an expected call of Unzip
+func (mr *MockFSMockRecorder) Unzip(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unzip", reflect.TypeOf((*MockFS)(nil).Unzip), arg0, arg1)
+}
+
+// WithParentDirs mocks base method
+func (m *MockFS) WithParentDirs(arg0 io.Reader, arg1 string, arg2, arg3 int) io.ReadCloser {
+	ret := m.ctrl.Call(m, "WithParentDirs", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(io.ReadCloser)
+	return ret0
+}
+
+// WithParentDirs indicates an expected call of WithParentDirs
+func (mr *MockFSMockRecorder) WithParentDirs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithParentDirs", reflect.TypeOf((*MockFS)(nil).WithParentDirs), arg0, arg1, arg2, arg3)
+}
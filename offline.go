@@ -0,0 +1,54 @@
+package pack
+
+import (
+	"fmt"
+
+	"github.com/buildpack/lifecycle/image"
+
+	"github.com/buildpack/pack/style"
+)
+
+// offlineImageFactory only provides images already on the daemon, for --offline builds. Requests to pull
+// an image or read it from a registry fail at once instead of timing out without network access.
+type offlineImageFactory struct {
+	ImageFactory
+}
+
+func (f *offlineImageFactory) NewLocal(repoName string, pull bool) (image.Image, error) {
+	img, err := f.ImageFactory.NewLocal(repoName, false)
+	if err != nil || !pull {
+		return img, err
+	}
+	if found, err := img.Found(); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("image %s is not on the daemon and %s forbids pulling it", style.Symbol(repoName), style.Symbol("--offline"))
+	}
+	return img, nil
+}
+
+func (f *offlineImageFactory) NewRemote(repoName string) (image.Image, error) {
+	return nil, fmt.Errorf("%s forbids reading image %s from its registry", style.Symbol("--offline"), style.Symbol(repoName))
+}
+
+// validateOffline rejects the flags of a build that need network access
+func validateOffline(f *BuildFlags, cacheRef string) error {
+	switch {
+	case f.Publish:
+		return fmt.Errorf("%s cannot be used with %s", style.Symbol("--publish"), style.Symbol("--offline"))
+	case cacheRef != "":
+		return fmt.Errorf("a registry cache cannot be used with %s", style.Symbol("--offline"))
+	case f.LifecycleVersion != "":
+		return fmt.Errorf("%s cannot be used with %s, use %s with a lifecycle image on the daemon instead", style.Symbol("--lifecycle-version"), style.Symbol("--offline"), style.Symbol("--lifecycle-image"))
+	case f.Network != "" && f.Network != "none":
+		return fmt.Errorf("%s cannot be used with %s, which runs buildpacks without network access", style.Symbol("--network "+f.Network), style.Symbol("--offline"))
+	case f.PullPolicy == PullIfChanged || f.PullPolicy == PullAlways:
+		return fmt.Errorf("%s cannot be used with %s", style.Symbol("--pull-policy "+f.PullPolicy), style.Symbol("--offline"))
+	}
+	for _, bp := range f.Buildpacks {
+		if isBuildpackURL(bp) {
+			return fmt.Errorf("buildpack %s cannot be downloaded with %s", style.Symbol(bp), style.Symbol("--offline"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	// OCIOutput writes the image as an OCI image layout directory, see https://github.com/opencontainers/image-spec/blob/master/image-layout.md
+	OCIOutput = "oci"
+	// DockerArchiveOutput writes the image as a tarball that 'docker load' reads
+	DockerArchiveOutput = "docker-archive"
+)
+
+// Output is where the built image is written besides the daemon, given as '<type>:<path>' with --output
+type Output struct {
+	Type string
+	Path string
+	// Sparse leaves the layers of the run image out, keeping only the app and buildpack layers and the manifest
+	Sparse bool
+}
+
+func (o Output) String() string {
+	return o.Type + ":" + o.Path
+}
+
+// parseOutput parses an --output, returning nil when it is empty
+func parseOutput(output string) (*Output, error) {
+	if output == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(output, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid output %s: must be of the form 'oci:<dir>' or 'docker-archive:<file>'", style.Symbol(output))
+	}
+	switch parts[0] {
+	case OCIOutput, DockerArchiveOutput:
+	default:
+		return nil, fmt.Errorf("invalid output type %s: must be %s or %s", style.Symbol(parts[0]), style.Symbol(OCIOutput), style.Symbol(DockerArchiveOutput))
+	}
+	path, err := filepath.Abs(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Output{Type: parts[0], Path: path}, nil
+}
+
+// WriteOutput writes the image built on the daemon to Output. The daemon keeps the image, so the next build
+// reuses its layers.
+func (b *BuildConfig) WriteOutput() error {
+	var err error
+	switch b.Output.Type {
+	case OCIOutput:
+		err = b.writeOCIOutput()
+	case DockerArchiveOutput:
+		err = b.writeDockerArchive()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing image %s to %s", style.Symbol(b.RepoName), style.Symbol(b.Output.String()))
+	}
+	b.Logger.Info("Wrote image %s to %s", style.Symbol(b.RepoName), style.Symbol(b.Output.String()))
+	return nil
+}
+
+func (b *BuildConfig) writeOCIOutput() error {
+	var baseLayers int
+	if b.Output.Sparse {
+		var err error
+		if baseLayers, err = b.runImageLayers(); err != nil {
+			return err
+		}
+	}
+	img, cleanup, err := b.savedImage(b.context(), b.RepoName)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	tag, err := name.NewTag(b.RepoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	return writeOCILayout(b.Output.Path, img, tag.TagStr(), baseLayers)
+}
+
+// runImageLayers returns the number of layers the image on the daemon has from its run image, which are its
+// bottom layers
+func (b *BuildConfig) runImageLayers() (int, error) {
+	runImage, _, err := b.Cli.ImageInspectWithRaw(b.context(), b.RunImage)
+	if err != nil {
+		return 0, errors.Wrapf(err, "inspecting run image %s", style.Symbol(b.RunImage))
+	}
+	appImage, _, err := b.Cli.ImageInspectWithRaw(b.context(), b.RepoName)
+	if err != nil {
+		return 0, errors.Wrapf(err, "inspecting image %s", style.Symbol(b.RepoName))
+	}
+	if len(appImage.RootFS.Layers) < len(runImage.RootFS.Layers) {
+		return 0, fmt.Errorf("image %s is not based on run image %s", style.Symbol(b.RepoName), style.Symbol(b.RunImage))
+	}
+	for i, layer := range runImage.RootFS.Layers {
+		if appImage.RootFS.Layers[i] != layer {
+			return 0, fmt.Errorf("image %s is not based on run image %s", style.Symbol(b.RepoName), style.Symbol(b.RunImage))
+		}
+	}
+	return len(runImage.RootFS.Layers), nil
+}
+
+// writeDockerArchive writes the image as the daemon saves it, tagged with its name. The archive is written
+// to a temporary file first, so a failed save leaves no partial archive behind.
+func (b *BuildConfig) writeDockerArchive() error {
+	var baseLayers int
+	if b.Output.Sparse {
+		var err error
+		if baseLayers, err = b.runImageLayers(); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Dir(b.Output.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(b.Output.Path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	rc, err := b.Cli.ImageSave(b.context(), []string{b.RepoName})
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	_, err = io.Copy(tmp, rc)
+	rc.Close()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if baseLayers > 0 {
+		return writeSparseArchive(tmp.Name(), b.Output.Path, baseLayers)
+	}
+	return os.Rename(tmp.Name(), b.Output.Path)
+}
+
+// writeSparseArchive writes the docker archive at src to dst without the files of its bottom baseLayers
+// layers. Its manifest.json still lists them, so 'docker load' takes them from the image already loaded.
+func writeSparseArchive(src, dst string, baseLayers int) error {
+	var manifest []struct {
+		Layers []string
+	}
+	err := readArchive(src, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name == "manifest.json" {
+			return json.NewDecoder(r).Decode(&manifest)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(manifest) != 1 || len(manifest[0].Layers) < baseLayers {
+		return fmt.Errorf("archive does not have the %d layers of the run image", baseLayers)
+	}
+
+	// docker keeps each layer in a dir of its own, which a layer above the base layers may share
+	omitted := map[string]bool{}
+	for _, layer := range manifest[0].Layers[:baseLayers] {
+		omitted[layer] = true
+		if dir := path.Dir(layer); dir != "." {
+			omitted[dir] = true
+		}
+	}
+	for _, layer := range manifest[0].Layers[baseLayers:] {
+		delete(omitted, layer)
+		delete(omitted, path.Dir(layer))
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	tw := tar.NewWriter(tmp)
+	err = readArchive(src, func(hdr *tar.Header, r io.Reader) error {
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if omitted[name] || omitted[path.Dir(name)] {
+			return nil
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// readArchive calls fn with each entry of the tar file
+func readArchive(file string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+const (
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigMediaType   = "application/vnd.oci.image.config.v1+json"
+	ociLayerMediaType    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// writeOCILayout writes img to the OCI image layout in dir, as the only image of its index, named refName.
+// The blobs of its bottom baseLayers layers are left out, though its manifest still lists them.
+func writeOCILayout(dir string, img v1.Image, refName string, baseLayers int) error {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		return err
+	}
+
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	manifest := ociManifest{SchemaVersion: 2}
+	if manifest.Config, err = writeBlob(dir, ociConfigMediaType, bytes.NewReader(rawConfig)); err != nil {
+		return err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for i, layer := range layers {
+		if i < baseLayers {
+			desc, err := layerDescriptor(layer)
+			if err != nil {
+				return err
+			}
+			manifest.Layers = append(manifest.Layers, desc)
+			continue
+		}
+		rc, err := layer.Compressed()
+		if err != nil {
+			return err
+		}
+		desc, err := writeBlob(dir, ociLayerMediaType, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	desc, err := writeBlob(dir, ociManifestMediaType, bytes.NewReader(rawManifest))
+	if err != nil {
+		return err
+	}
+	desc.Annotations = map[string]string{ociRefNameAnnotation: refName}
+	index, err := json.Marshal(ociIndex{SchemaVersion: 2, Manifests: []ociDescriptor{desc}})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "index.json"), index, 0644)
+}
+
+// layerDescriptor describes the compressed layer without writing its blob
+func layerDescriptor(layer v1.Layer) (ociDescriptor, error) {
+	digest, err := layer.Digest()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	size, err := layer.Size()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	return ociDescriptor{MediaType: ociLayerMediaType, Digest: digest.String(), Size: size}, nil
+}
+
+// writeBlob writes the content read from r to the blobs of the OCI image layout in dir, named by its digest
+func writeBlob(dir, mediaType string, r io.Reader) (ociDescriptor, error) {
+	blobsDir := filepath.Join(dir, "blobs", "sha256")
+	tmp, err := ioutil.TempFile(blobsDir, ".blob.")
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(tmp.Name(), filepath.Join(blobsDir, digest)); err != nil {
+		return ociDescriptor{}, err
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: "sha256:" + digest, Size: size}, nil
+}
@@ -0,0 +1,126 @@
+package pack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// BuilderMetadataLabel describes a builder, including the platform API of its lifecycle
+const BuilderMetadataLabel = "io.buildpacks.builder.metadata"
+
+// legacyPlatformAPI is assumed for lifecycles that do not declare their platform API
+const legacyPlatformAPI = "0.1"
+
+// platformAPI holds the lifecycle arguments that differ between versions of the platform API
+type platformAPI struct {
+	Version string
+	// RunImageFlag names the run image for the exporter
+	RunImageFlag string
+	// CacheDirFlag names the layers cache dir for the restorer and cacher
+	CacheDirFlag string
+}
+
+// supportedPlatformAPIs lists the platform APIs this version of pack can drive, oldest first
+var supportedPlatformAPIs = []platformAPI{
+	{Version: "0.1", RunImageFlag: "-image", CacheDirFlag: "-path"},
+	{Version: "0.2", RunImageFlag: "-run-image", CacheDirFlag: "-cache-dir"},
+}
+
+type builderMetadata struct {
+	Lifecycle struct {
+		Version string `json:"version"`
+		API     struct {
+			Platform string `json:"platform"`
+		} `json:"api"`
+	} `json:"lifecycle"`
+}
+
+// imagePlatformAPI returns the platform API of the lifecycle in img, declared in its builder metadata
+func imagePlatformAPI(img image.Image, imageName string) (string, error) {
+	label, err := img.Label(BuilderMetadataLabel)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading label %s of %s", style.Symbol(BuilderMetadataLabel), style.Symbol(imageName))
+	}
+	if label == "" {
+		return legacyPlatformAPI, nil
+	}
+	var metadata builderMetadata
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return "", errors.Wrapf(err, "parsing label %s of %s", style.Symbol(BuilderMetadataLabel), style.Symbol(imageName))
+	}
+	if metadata.Lifecycle.API.Platform == "" {
+		return legacyPlatformAPI, nil
+	}
+	return metadata.Lifecycle.API.Platform, nil
+}
+
+// negotiatePlatformAPI returns the arguments for a lifecycle with platform API version, failing when pack
+// does not support it. The lifecycle is described by desc in errors.
+func negotiatePlatformAPI(version, desc string) (platformAPI, error) {
+	for _, api := range supportedPlatformAPIs {
+		if api.Version == version {
+			return api, nil
+		}
+	}
+	oldest, newest := supportedPlatformAPIs[0].Version, supportedPlatformAPIs[len(supportedPlatformAPIs)-1].Version
+	supported := fmt.Sprintf("pack supports %s to %s", style.Symbol(oldest), style.Symbol(newest))
+	if c, err := compareAPIVersions(version, newest); err != nil {
+		return platformAPI{}, fmt.Errorf("invalid platform API %s of %s: %s", style.Symbol(version), desc, err)
+	} else if c > 0 {
+		return platformAPI{}, fmt.Errorf("%s uses platform API %s, which is too new: %s, upgrade pack or use an older builder", desc, style.Symbol(version), supported)
+	}
+	return platformAPI{}, fmt.Errorf("%s uses platform API %s, which is not supported: %s, use a newer builder or --lifecycle-version", desc, style.Symbol(version), supported)
+}
+
+// compareAPIVersions compares API versions of the form major.minor, returning -1, 0 or 1
+func compareAPIVersions(a, b string) (int, error) {
+	pa, err := parseAPIVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseAPIVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] < pb[i] {
+			return -1, nil
+		}
+		if pa[i] > pb[i] {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseAPIVersion(v string) ([2]int, error) {
+	var parsed [2]int
+	parts := strings.Split(v, ".")
+	if len(parts) != 2 {
+		return parsed, fmt.Errorf("must be of the form 'major.minor'")
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("must be of the form 'major.minor'")
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// platform returns the platform API negotiated with the lifecycle, or the legacy API for configs not
+// created by BuildConfigFromFlags
+func (b *BuildConfig) platform() platformAPI {
+	if b.platformAPI.Version == "" {
+		return supportedPlatformAPIs[0]
+	}
+	return b.platformAPI
+}
@@ -0,0 +1,71 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// processTypeEnv selects the process the launcher starts when the image is run without a command
+const processTypeEnv = "PACK_PROCESS_TYPE"
+
+// launchMetadataPath is where the builder records the processes contributed by the buildpacks
+const launchMetadataPath = launchDir + "/config/metadata.toml"
+
+type launchMetadata struct {
+	Processes []struct {
+		Type    string `toml:"type"`
+		Command string `toml:"command"`
+	} `toml:"processes"`
+}
+
+// ReadProcessTypes reads the types of the processes declared by the buildpacks from the cache volume
+func (b *BuildConfig) ReadProcessTypes() ([]string, error) {
+	ctx := context.Background()
+	ctrID, err := createCacheContainer(ctx, b.Cli, b.CacheVolume, b.Builder)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	contents, err := b.readWorkspaceFile(ctx, ctrID, launchMetadataPath)
+	if err != nil {
+		return nil, err
+	}
+	var metadata launchMetadata
+	if _, err := toml.Decode(string(contents), &metadata); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", style.Symbol(launchMetadataPath))
+	}
+	var types []string
+	for _, p := range metadata.Processes {
+		types = append(types, p.Type)
+	}
+	return types, nil
+}
+
+// validateDefaultProcess checks that the buildpacks declared the process chosen with --default-process
+func (b *BuildConfig) validateDefaultProcess() error {
+	types, err := b.ReadProcessTypes()
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t == b.DefaultProcess {
+			return nil
+		}
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("default process %s was not declared, the buildpacks declared no processes", style.Symbol(b.DefaultProcess))
+	}
+	var declared []string
+	for _, t := range types {
+		declared = append(declared, style.Symbol(t))
+	}
+	return fmt.Errorf("default process %s was not declared, must be one of %s", style.Symbol(b.DefaultProcess), strings.Join(declared, ", "))
+}
@@ -0,0 +1,44 @@
+package pack
+
+import (
+	"io"
+	"time"
+
+	units "github.com/docker/go-units"
+
+	"github.com/buildpack/pack/logging"
+)
+
+// progressInterval is how often a progressReader logs the bytes read so far
+const progressInterval = 5 * time.Second
+
+// progressReader logs how much of a long copy, such as the upload of a large app, has been read. Copies
+// finishing within progressInterval log nothing.
+type progressReader struct {
+	io.Reader
+	logger  *logging.Logger
+	action  string
+	read    int64
+	started time.Time
+	logged  time.Time
+}
+
+func newProgressReader(r io.Reader, logger *logging.Logger, action string) *progressReader {
+	now := time.Now()
+	return &progressReader{Reader: r, logger: logger, action: action, started: now, logged: now}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.Reader.Read(b)
+	p.read += int64(n)
+	if now := time.Now(); now.Sub(p.logged) >= progressInterval {
+		p.logger.Info("%s: %s so far", p.action, units.HumanSize(float64(p.read)))
+		p.logged = now
+	}
+	return n, err
+}
+
+// done logs the total bytes read
+func (p *progressReader) done() {
+	p.logger.Verbose("%s: %s in %s", p.action, units.HumanSize(float64(p.read)), time.Since(p.started).Round(time.Millisecond))
+}
@@ -0,0 +1,104 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/style"
+)
+
+// ProjectDescriptorFile is read from the app dir when --descriptor is not given
+const ProjectDescriptorFile = "project.toml"
+
+// ProjectDescriptor describes how to build an app, so app repositories can carry their build configuration
+type ProjectDescriptor struct {
+	Project ProjectInfo  `toml:"project"`
+	Build   ProjectBuild `toml:"build"`
+}
+
+type ProjectInfo struct {
+	Name string `toml:"name"`
+}
+
+type ProjectBuild struct {
+	// Include and Exclude are gitignore-style patterns selecting the files of the app dir to build
+	Include    []string           `toml:"include"`
+	Exclude    []string           `toml:"exclude"`
+	Buildpacks []ProjectBuildpack `toml:"buildpacks"`
+	Env        []ProjectEnvVar    `toml:"env"`
+}
+
+type ProjectBuildpack struct {
+	ID      string `toml:"id"`
+	Version string `toml:"version"`
+	URI     string `toml:"uri"`
+}
+
+type ProjectEnvVar struct {
+	Name  string `toml:"name"`
+	Value string `toml:"value"`
+}
+
+// ReadProjectDescriptor reads a project descriptor. Relative buildpack URIs are interpreted
+// relative to the directory containing the descriptor.
+func ReadProjectDescriptor(path string) (*ProjectDescriptor, error) {
+	descriptor := &ProjectDescriptor{}
+	if _, err := toml.DecodeFile(path, descriptor); err != nil {
+		return nil, errors.Wrapf(err, "reading project descriptor %s", style.Symbol(path))
+	}
+	if len(descriptor.Build.Include) > 0 && len(descriptor.Build.Exclude) > 0 {
+		return nil, fmt.Errorf("project descriptor %s may not specify both include and exclude", style.Symbol(path))
+	}
+	for _, patterns := range [][]string{descriptor.Build.Include, descriptor.Build.Exclude} {
+		if _, err := fs.NewMatcher(patterns); err != nil {
+			return nil, errors.Wrapf(err, "reading project descriptor %s", style.Symbol(path))
+		}
+	}
+
+	dir := filepath.Dir(path)
+	for i, bp := range descriptor.Build.Buildpacks {
+		if bp.ID == "" && bp.URI == "" {
+			return nil, fmt.Errorf("project descriptor %s: buildpack %d must have an id or uri", style.Symbol(path), i+1)
+		}
+		if bp.URI != "" && !filepath.IsAbs(bp.URI) {
+			descriptor.Build.Buildpacks[i].URI = filepath.Join(dir, bp.URI)
+		}
+	}
+	return descriptor, nil
+}
+
+// projectDescriptor reads the descriptor given by --descriptor, or project.toml in the app dir when present
+func (bf *BuildFactory) projectDescriptor(path, appDir string) (*ProjectDescriptor, error) {
+	if path == "" {
+		if appDir == StdinAppDir {
+			return nil, nil
+		}
+		path = filepath.Join(appDir, ProjectDescriptorFile)
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+			return nil, nil
+		}
+	}
+	bf.Logger.Verbose("Using project descriptor %s", style.Symbol(path))
+	return ReadProjectDescriptor(path)
+}
+
+// buildpackRefs returns the buildpacks of the descriptor in the form accepted by --buildpack
+func (d *ProjectDescriptor) buildpackRefs() []string {
+	var refs []string
+	for _, bp := range d.Build.Buildpacks {
+		switch {
+		case bp.URI != "":
+			refs = append(refs, bp.URI)
+		case bp.Version != "":
+			refs = append(refs, bp.ID+"@"+bp.Version)
+		default:
+			refs = append(refs, bp.ID)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,94 @@
+package pack_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestProject(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "project", testProject, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testProject(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.project.test.")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	when("#ReadProjectDescriptor", func() {
+		it("reads buildpacks, env and file patterns", func() {
+			path := filepath.Join(tmpDir, "project.toml")
+			h.AssertNil(t, ioutil.WriteFile(path, []byte(`
+[project]
+name = "my-app"
+
+[build]
+exclude = ["node_modules", "*.log"]
+
+[[build.buildpacks]]
+id = "some/bp"
+version = "1.2.3"
+
+[[build.buildpacks]]
+uri = "buildpacks/local"
+
+[[build.env]]
+name = "SOME_VAR"
+value = "some-value"
+`), 0644))
+
+			descriptor, err := pack.ReadProjectDescriptor(path)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, descriptor.Project.Name, "my-app")
+			h.AssertEq(t, descriptor.Build.Exclude, []string{"node_modules", "*.log"})
+			h.AssertEq(t, descriptor.Build.Buildpacks, []pack.ProjectBuildpack{
+				{ID: "some/bp", Version: "1.2.3"},
+				{URI: filepath.Join(tmpDir, "buildpacks", "local")},
+			})
+			h.AssertEq(t, descriptor.Build.Env, []pack.ProjectEnvVar{{Name: "SOME_VAR", Value: "some-value"}})
+		})
+
+		it("rejects descriptors with both include and exclude", func() {
+			path := filepath.Join(tmpDir, "project.toml")
+			h.AssertNil(t, ioutil.WriteFile(path, []byte(`
+[build]
+include = ["src"]
+exclude = ["node_modules"]
+`), 0644))
+
+			_, err := pack.ReadProjectDescriptor(path)
+			h.AssertNotNil(t, err)
+			h.AssertContains(t, err.Error(), "may not specify both include and exclude")
+		})
+
+		it("rejects buildpacks without an id or uri", func() {
+			path := filepath.Join(tmpDir, "project.toml")
+			h.AssertNil(t, ioutil.WriteFile(path, []byte(`
+[[build.buildpacks]]
+version = "1.2.3"
+`), 0644))
+
+			_, err := pack.ReadProjectDescriptor(path)
+			h.AssertNotNil(t, err)
+			h.AssertContains(t, err.Error(), "buildpack 1 must have an id or uri")
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package pack
+
+import (
+	"os"
+	"strings"
+)
+
+// proxyEnvVars are the proxy settings passed to the containers running buildpacks
+var proxyEnvVars = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// proxyEnv returns the proxy settings of the host, falling back to the proxy in config.toml, in both
+// the upper and lower case spellings tools look for, so buildpacks can download dependencies behind a proxy
+func (b *BuildConfig) proxyEnv() []string {
+	var env []string
+	for _, name := range proxyEnvVars {
+		value := os.Getenv(name)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(name))
+		}
+		if value == "" {
+			value = b.configuredProxy(name)
+		}
+		if value != "" {
+			env = append(env, name+"="+value, strings.ToLower(name)+"="+value)
+		}
+	}
+	return env
+}
+
+func (b *BuildConfig) configuredProxy(name string) string {
+	if b.Config == nil {
+		return ""
+	}
+	switch name {
+	case "HTTP_PROXY":
+		return b.Config.Proxy.HTTP
+	case "HTTPS_PROXY":
+		return b.Config.Proxy.HTTPS
+	case "NO_PROXY":
+		return b.Config.Proxy.NoProxy
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package pack
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// headerAuthenticator authenticates with an authorization header, as returned by authHeader
+type headerAuthenticator string
+
+func (a headerAuthenticator) Authorization() (string, error) {
+	return string(a), nil
+}
+
+// CheckPushAccess fails when the registry credentials for repoName don't allow pushing to its repository.
+// It starts and then cancels a blob upload, the cheapest request that needs push access.
+func CheckPushAccess(repoName string) error {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	registry := ref.Context().RegistryStr()
+	header, err := authHeader(repoName)
+	if err != nil {
+		return errors.Wrapf(err, "reading credentials for registry %s", style.Symbol(registry))
+	}
+	tr, err := transport.New(ref.Context().Registry, headerAuthenticator(header), http.DefaultTransport, []string{ref.Scope(transport.PushScope)})
+	if err != nil {
+		return errors.Wrapf(err, "authenticating to registry %s", style.Symbol(registry))
+	}
+	client := &http.Client{Transport: tr}
+
+	uploads := &url.URL{
+		Scheme: ref.Context().Registry.Scheme(),
+		Host:   registry,
+		Path:   fmt.Sprintf("/v2/%s/blobs/uploads/", ref.Context().RepositoryStr()),
+	}
+	resp, err := client.Post(uploads.String(), "", nil)
+	if err != nil {
+		return errors.Wrapf(err, "checking push access to %s", style.Symbol(ref.Context().Name()))
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("the credentials for registry %s do not allow pushing to %s: run %s or set %s", style.Symbol(registry), style.Symbol(ref.Context().Name()), style.Symbol("docker login "+registry), style.Symbol("CNB_REGISTRY_AUTH"))
+	default:
+		return fmt.Errorf("checking push access to %s: registry responded %s", style.Symbol(ref.Context().Name()), resp.Status)
+	}
+
+	location, err := uploads.Parse(resp.Header.Get("Location"))
+	if err != nil || location.String() == uploads.String() {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, location.String(), nil)
+	if err != nil {
+		return nil
+	}
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// checkPushAccess checks push access to each repository of repoNames once, ignoring empty names
+func (bf *BuildFactory) checkPushAccess(repoNames ...string) error {
+	checked := map[string]bool{}
+	for _, repoName := range repoNames {
+		if repoName == "" {
+			continue
+		}
+		ref, err := name.ParseReference(repoName, name.WeakValidation)
+		if err != nil {
+			return err
+		}
+		if checked[ref.Context().Name()] {
+			continue
+		}
+		checked[ref.Context().Name()] = true
+		bf.Logger.Verbose("Checking push access to %s", style.Symbol(ref.Context().Name()))
+		if err := bf.CheckPushAccess(repoName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
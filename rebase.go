@@ -1,6 +1,7 @@
 package pack
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/buildpack/pack/logging"
@@ -15,31 +16,43 @@ import (
 type RebaseConfig struct {
 	Image        image.Image
 	NewBaseImage image.Image
+	Publish      bool
 }
 
 type RebaseFactory struct {
 	Logger       *logging.Logger
 	Config       *config.Config
 	ImageFactory ImageFactory
+	Cli          Docker
 }
 
 type RebaseFlags struct {
 	RepoName string
+	RunImage string
 	Publish  bool
-	NoPull   bool
+	// NoPull is deprecated, use PullPolicy never
+	NoPull     bool
+	PullPolicy string
 }
 
 func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig, error) {
-	var newImage func(string) (image.Image, error)
-	if flags.Publish {
-		newImage = f.ImageFactory.NewRemote
-	} else {
-		newImage = func(name string) (image.Image, error) {
-			return f.ImageFactory.NewLocal(name, !flags.NoPull)
+	if err := validateImageReference("image name", flags.RepoName); err != nil {
+		return RebaseConfig{}, err
+	}
+
+	pullPolicy, err := resolvePullPolicy(flags.PullPolicy, flags.NoPull, PullAlways)
+	if err != nil {
+		return RebaseConfig{}, err
+	}
+
+	newImage := func(kind, name string) (image.Image, error) {
+		if flags.Publish {
+			return f.ImageFactory.NewRemote(name)
 		}
+		return localImage(f.ImageFactory, f.Logger, kind, name, pullPolicy)
 	}
 
-	image, err := newImage(flags.RepoName)
+	image, err := newImage("app", flags.RepoName)
 	if err != nil {
 		return RebaseConfig{}, err
 	}
@@ -49,52 +62,62 @@ func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig,
 		return RebaseConfig{}, err
 	}
 
-	baseImageName, err := f.runImageName(stackID, flags.RepoName)
-	if err != nil {
+	baseImageName := flags.RunImage
+	if baseImageName == "" {
+		baseImageName, err = f.runImageName(stackID, flags.RepoName)
+		if err != nil {
+			return RebaseConfig{}, err
+		}
+	} else if err := validateImageReference("--run-image", baseImageName); err != nil {
 		return RebaseConfig{}, err
 	}
 
-	baseImage, err := newImage(baseImageName)
+	baseImage, err := newImage("run", baseImageName)
 	if err != nil {
 		return RebaseConfig{}, err
 	}
 	return RebaseConfig{
 		Image:        image,
 		NewBaseImage: baseImage,
+		Publish:      flags.Publish,
 	}, nil
 }
 
-func (f *RebaseFactory) Rebase(cfg RebaseConfig) error {
+// Rebase swaps the run image layers of cfg.Image for those of cfg.NewBaseImage and saves it
+func (f *RebaseFactory) Rebase(cfg RebaseConfig) (Identifier, error) {
 	label, err := cfg.Image.Label("io.buildpacks.lifecycle.metadata")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var metadata lifecycle.AppImageMetadata
 	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
-		return err
+		return nil, err
 	}
 	if err := cfg.Image.Rebase(metadata.RunImage.TopLayer, cfg.NewBaseImage); err != nil {
-		return err
+		return nil, err
 	}
 
 	metadata.RunImage.SHA, err = cfg.NewBaseImage.Digest()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	metadata.RunImage.TopLayer, err = cfg.NewBaseImage.TopLayer()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	newLabel, err := json.Marshal(metadata)
 	if err := cfg.Image.SetLabel("io.buildpacks.lifecycle.metadata", string(newLabel)); err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = cfg.Image.Save()
+	saved, err := cfg.Image.Save()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if cfg.Publish {
+		return remoteDigestReference(cfg.Image.Name(), saved)
 	}
-	return nil
+	return localImageID(context.Background(), f.Cli, cfg.Image.Name())
 }
 
 func (f *RebaseFactory) runImageName(stackID, repoName string) (string, error) {
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/buildpack/lifecycle"
+	dockertypes "github.com/docker/docker/api/types"
 	"github.com/golang/mock/gomock"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -31,6 +32,7 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 		var (
 			mockController   *gomock.Controller
 			mockImageFactory *mocks.MockImageFactory
+			mockDocker       *mocks.MockDocker
 			factory          pack.RebaseFactory
 			outBuf           bytes.Buffer
 			errBuff          bytes.Buffer
@@ -38,6 +40,7 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 		it.Before(func() {
 			mockController = gomock.NewController(t)
 			mockImageFactory = mocks.NewMockImageFactory(mockController)
+			mockDocker = mocks.NewMockDocker(mockController)
 
 			factory = pack.RebaseFactory{
 				Logger: logging.NewLogger(&outBuf, &errBuff, false, false),
@@ -57,6 +60,7 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 					},
 				},
 				ImageFactory: mockImageFactory,
+				Cli:          mockDocker,
 			}
 		})
 
@@ -105,6 +109,29 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 						h.AssertSameInstance(t, cfg.NewBaseImage, mockBaseImage)
 					})
 				})
+
+				when("pull-policy is if-not-present", func() {
+					it("uses the local images and pulls the missing ones", func() {
+						mockBaseImage := mocks.NewMockImage(mockController)
+						mockImage := mocks.NewMockImage(mockController)
+						mockImage.EXPECT().Found().Return(true, nil)
+						mockImageFactory.EXPECT().NewLocal("myorg/myrepo", false).Return(mockImage, nil)
+						mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.default.stack", nil)
+						mockMissingBaseImage := mocks.NewMockImage(mockController)
+						mockMissingBaseImage.EXPECT().Found().Return(false, nil)
+						mockImageFactory.EXPECT().NewLocal("default/run", false).Return(mockMissingBaseImage, nil)
+						mockImageFactory.EXPECT().NewLocal("default/run", true).Return(mockBaseImage, nil)
+
+						cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
+							RepoName:   "myorg/myrepo",
+							PullPolicy: pack.PullIfNotPresent,
+						})
+						h.AssertNil(t, err)
+
+						h.AssertSameInstance(t, cfg.Image, mockImage)
+						h.AssertSameInstance(t, cfg.NewBaseImage, mockBaseImage)
+					})
+				})
 			})
 
 			when("publish is true", func() {
@@ -128,6 +155,24 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 					})
 				})
 			})
+
+			when("run image is provided", func() {
+				it("rebases onto the provided run image", func() {
+					mockBaseImage := mocks.NewMockImage(mockController)
+					mockImage := mocks.NewMockImage(mockController)
+					mockImageFactory.EXPECT().NewLocal("some/other-run", true).Return(mockBaseImage, nil)
+					mockImageFactory.EXPECT().NewLocal("myorg/myrepo", true).Return(mockImage, nil)
+					mockImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.default.stack", nil)
+
+					cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
+						RepoName: "myorg/myrepo",
+						RunImage: "some/other-run",
+					})
+					h.AssertNil(t, err)
+
+					h.AssertSameInstance(t, cfg.NewBaseImage, mockBaseImage)
+				})
+			})
 		})
 
 		when("#Rebase", func() {
@@ -147,14 +192,65 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 						h.AssertEq(t, metadata.RunImage.SHA, "some-sha")
 						h.AssertEq(t, metadata.App.SHA, "data")
 					})
-				mockImage.EXPECT().Save().After(setLabel).Return("some-digest", nil)
+				save := mockImage.EXPECT().Save().After(setLabel).Return("sha256:some-id", nil)
+				mockImage.EXPECT().Name().Return("some/image").AnyTimes()
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/image").After(save).
+					Return(dockertypes.ImageInspect{ID: "sha256:some-id"}, nil, nil)
 
 				rebaseConfig := pack.RebaseConfig{
 					Image:        mockImage,
 					NewBaseImage: mockBaseImage,
 				}
-				err := factory.Rebase(rebaseConfig)
+				id, err := factory.Rebase(rebaseConfig)
 				h.AssertNil(t, err)
+				h.AssertEq(t, id, pack.Identifier(pack.LocalImageID("sha256:some-id")))
+				h.AssertEq(t, id.Kind(), pack.LocalImageIDKind)
+			})
+
+			it("identifies the image by the ID on the daemon when saving does not return it", func() {
+				mockBaseImage := mocks.NewMockImage(mockController)
+				mockBaseImage.EXPECT().TopLayer().Return("some-top-layer", nil)
+				mockBaseImage.EXPECT().Digest().Return("some-sha", nil)
+				mockImage := mocks.NewMockImage(mockController)
+				mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").
+					Return(`{"runimage":{"topLayer":"old-top-layer"}, "app":{"sha":"data"}}`, nil)
+				mockImage.EXPECT().Rebase("old-top-layer", mockBaseImage)
+				mockImage.EXPECT().SetLabel("io.buildpacks.lifecycle.metadata", gomock.Any())
+				mockImage.EXPECT().Save().Return("", nil)
+				mockImage.EXPECT().Name().Return("some/image").AnyTimes()
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/image").
+					Return(dockertypes.ImageInspect{ID: "sha256:some-id"}, nil, nil)
+
+				id, err := factory.Rebase(pack.RebaseConfig{
+					Image:        mockImage,
+					NewBaseImage: mockBaseImage,
+				})
+				h.AssertNil(t, err)
+				h.AssertEq(t, id.String(), "sha256:some-id")
+			})
+
+			when("publish is true", func() {
+				it("identifies the image by digest reference", func() {
+					mockBaseImage := mocks.NewMockImage(mockController)
+					mockBaseImage.EXPECT().TopLayer().Return("some-top-layer", nil)
+					mockBaseImage.EXPECT().Digest().Return("some-sha", nil)
+					mockImage := mocks.NewMockImage(mockController)
+					mockImage.EXPECT().Label("io.buildpacks.lifecycle.metadata").
+						Return(`{"runimage":{"topLayer":"old-top-layer"}, "app":{"sha":"data"}}`, nil)
+					mockImage.EXPECT().Rebase("old-top-layer", mockBaseImage)
+					mockImage.EXPECT().SetLabel("io.buildpacks.lifecycle.metadata", gomock.Any())
+					mockImage.EXPECT().Save().Return("sha256:some-digest", nil)
+					mockImage.EXPECT().Name().Return("registry.com/some/image:latest").AnyTimes()
+
+					id, err := factory.Rebase(pack.RebaseConfig{
+						Image:        mockImage,
+						NewBaseImage: mockBaseImage,
+						Publish:      true,
+					})
+					h.AssertNil(t, err)
+					h.AssertEq(t, id.String(), "registry.com/some/image@sha256:some-digest")
+					h.AssertEq(t, id.Kind(), pack.RemoteDigestReferenceKind)
+				})
 			})
 		})
 	})
@@ -0,0 +1,252 @@
+package pack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/buildpack/lifecycle/image"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+const BuildMetadataLabel = "io.buildpacks.pack.build"
+
+// Version of pack recorded on built images, set by the CLI
+var Version = "0.0.0"
+
+// BuildMetadata records how an image was built so it can be inspected and rebuilt later.
+// Only the names of environment variables and bindings are recorded, never their values.
+type BuildMetadata struct {
+	PackVersion   string           `json:"packVersion"`
+	AppDir        string           `json:"appDir"`
+	SourceCommit  string           `json:"sourceCommit,omitempty"`
+	Builder       string           `json:"builder"`
+	BuilderID     string           `json:"builderId,omitempty"`
+	BuilderDigest string           `json:"builderDigest,omitempty"`
+	RunImage      string           `json:"runImage"`
+	Buildpacks    []string         `json:"buildpacks,omitempty"`
+	Env           []string         `json:"env,omitempty"`
+	LaunchEnv     []string         `json:"launchEnv,omitempty"`
+	Bindings      []string         `json:"bindings,omitempty"`
+	Flags         BuildFlagSummary `json:"flags"`
+}
+
+type BuildFlagSummary struct {
+	Publish        bool   `json:"publish,omitempty"`
+	NoPull         bool   `json:"noPull,omitempty"`
+	ClearCache     bool   `json:"clearCache,omitempty"`
+	LifecycleImage string `json:"lifecycleImage,omitempty"`
+	DefaultProcess string `json:"defaultProcess,omitempty"`
+}
+
+type RebuildFlags struct {
+	RepoName string
+	AppDir   string
+	Publish  bool
+	// NoPull is deprecated, use PullPolicy never
+	NoPull     bool
+	PullPolicy string
+}
+
+func (b *BuildConfig) buildMetadata() BuildMetadata {
+	var env []string
+	for k := range b.EnvFile {
+		env = append(env, k)
+	}
+	sort.Strings(env)
+	var launchEnv []string
+	for k := range b.LaunchEnv {
+		launchEnv = append(launchEnv, k)
+	}
+	sort.Strings(launchEnv)
+	var sourceCommit string
+	if b.ProjectSource != nil {
+		sourceCommit = b.ProjectSource.Version.Commit
+	} else {
+		sourceCommit = gitCommit(b.AppDir)
+	}
+	metadata := BuildMetadata{
+		PackVersion:  Version,
+		AppDir:       b.AppDir,
+		SourceCommit: sourceCommit,
+		Builder:      b.Builder,
+		RunImage:     b.RunImage,
+		Buildpacks:   b.Buildpacks,
+		Env:          env,
+		LaunchEnv:    launchEnv,
+		Bindings:     b.bindingNames(),
+		Flags: BuildFlagSummary{
+			Publish:        b.Publish,
+			NoPull:         b.NoPull,
+			ClearCache:     b.ClearCache,
+			LifecycleImage: b.LifecycleImage,
+			DefaultProcess: b.DefaultProcess,
+		},
+	}
+	if i, _, err := b.Cli.ImageInspectWithRaw(context.Background(), b.Builder); err == nil {
+		metadata.BuilderID = i.ID
+		if len(i.RepoDigests) > 0 {
+			metadata.BuilderDigest = i.RepoDigests[0]
+		}
+	}
+	return metadata
+}
+
+// gitCommit returns the commit checked out in dir, or an empty string when dir is not in a git repository
+func gitCommit(dir string) string {
+	return gitOutput(dir, "rev-parse", "HEAD")
+}
+
+// SetBuildMetadata labels the exported image with the settings used to build it and the labels of the build
+func (b *BuildConfig) SetBuildMetadata() error {
+	var img image.Image
+	var err error
+	if b.Publish {
+		img, err = b.ImageFactory.NewRemote(b.RepoName)
+	} else {
+		img, err = b.ImageFactory.NewLocal(b.RepoName, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(b.buildMetadata())
+	if err != nil {
+		return err
+	}
+	if err := img.SetLabel(BuildMetadataLabel, string(metadata)); err != nil {
+		return errors.Wrapf(err, "setting label %s", style.Symbol(BuildMetadataLabel))
+	}
+	if err := b.setProjectMetadata(img); err != nil {
+		return err
+	}
+	for k, v := range b.Labels {
+		if err := img.SetLabel(k, v); err != nil {
+			return errors.Wrapf(err, "setting label %s", style.Symbol(k))
+		}
+	}
+	for k, v := range b.LaunchEnv {
+		if err := img.SetEnv(k, v); err != nil {
+			return errors.Wrapf(err, "setting launch env %s", style.Symbol(k))
+		}
+	}
+	if len(b.Bindings) > 0 {
+		if err := img.SetEnv(serviceBindingRootEnv, bindingsDir); err != nil {
+			return errors.Wrapf(err, "setting %s", style.Symbol(serviceBindingRootEnv))
+		}
+	}
+	if b.DefaultProcess != "" {
+		if err := img.SetEnv(processTypeEnv, b.DefaultProcess); err != nil {
+			return errors.Wrapf(err, "setting default process %s", style.Symbol(b.DefaultProcess))
+		}
+	}
+	saved, err := img.Save()
+	if err != nil {
+		return errors.Wrapf(err, "saving image %s", style.Symbol(b.RepoName))
+	}
+	return b.identify(saved)
+}
+
+// RebuildConfigFromFlags creates a BuildConfig from the build metadata recorded on an existing image.
+// Recorded environment variables take their values from the current environment, and must all be set.
+func (bf *BuildFactory) RebuildConfigFromFlags(f *RebuildFlags) (*BuildConfig, error) {
+	pullPolicy, err := resolvePullPolicy(f.PullPolicy, f.NoPull, PullIfChanged)
+	if err != nil {
+		return nil, FlagError{err}
+	}
+	var img image.Image
+	if f.Publish {
+		img, err = bf.ImageFactory.NewRemote(f.RepoName)
+	} else {
+		img, err = localImage(bf.ImageFactory, bf.Logger, "app", f.RepoName, pullPolicy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := readBuildMetadata(img, f.RepoName)
+	if err != nil {
+		return nil, err
+	}
+
+	appDir := metadata.AppDir
+	if f.AppDir != "" {
+		appDir = f.AppDir
+	}
+	bf.Logger.Verbose("Rebuilding from %s with builder %s and run image %s", style.Symbol(appDir), style.Symbol(metadata.Builder), style.Symbol(metadata.RunImage))
+
+	b, err := bf.BuildConfigFromFlags(&BuildFlags{
+		AppDir:         appDir,
+		Builder:        metadata.Builder,
+		RunImage:       metadata.RunImage,
+		RepoName:       f.RepoName,
+		Publish:        f.Publish,
+		PullPolicy:     pullPolicy,
+		Buildpacks:     metadata.Buildpacks,
+		DefaultProcess: metadata.Flags.DefaultProcess,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metadata.Env) > 0 {
+		b.EnvFile = map[string]string{}
+		var missing []string
+		for _, k := range metadata.Env {
+			v, ok := os.LookupEnv(k)
+			if !ok {
+				missing = append(missing, style.Symbol(k))
+				continue
+			}
+			b.EnvFile[k] = v
+		}
+		if len(missing) > 0 {
+			return nil, FlagError{fmt.Errorf("image %s was built with env %s, which must be set to rebuild it", style.Symbol(f.RepoName), strings.Join(missing, ", "))}
+		}
+	}
+	// launch env is kept in the image, so it is carried over from the image being rebuilt
+	if len(metadata.LaunchEnv) > 0 {
+		b.LaunchEnv = map[string]string{}
+		for _, k := range metadata.LaunchEnv {
+			if b.LaunchEnv[k], err = img.Env(k); err != nil {
+				return nil, errors.Wrapf(err, "reading launch env %s of %s", style.Symbol(k), style.Symbol(f.RepoName))
+			}
+		}
+	}
+	return b, nil
+}
+
+// InspectImage returns the build metadata recorded on an image in the daemon, or in the registry when remote is true
+func (bf *BuildFactory) InspectImage(repoName string, remote bool) (*BuildMetadata, error) {
+	var img image.Image
+	var err error
+	if remote {
+		img, err = bf.ImageFactory.NewRemote(repoName)
+	} else {
+		img, err = bf.ImageFactory.NewLocal(repoName, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return readBuildMetadata(img, repoName)
+}
+
+func readBuildMetadata(img image.Image, repoName string) (*BuildMetadata, error) {
+	label, err := img.Label(BuildMetadataLabel)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		return nil, fmt.Errorf("image %s has no build metadata, it was not built by this version of pack", style.Symbol(repoName))
+	}
+	metadata := &BuildMetadata{}
+	if err := json.Unmarshal([]byte(label), metadata); err != nil {
+		return nil, errors.Wrapf(err, "parsing label %s", style.Symbol(BuildMetadataLabel))
+	}
+	return metadata, nil
+}
@@ -0,0 +1,167 @@
+package pack_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestRebuild(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "rebuild", testRebuild, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testRebuild(t *testing.T, when spec.G, it spec.S) {
+	when("#RebuildConfigFromFlags", func() {
+		var (
+			factory          *pack.BuildFactory
+			mockController   *gomock.Controller
+			mockImageFactory *mocks.MockImageFactory
+			outBuf           bytes.Buffer
+			errBuf           bytes.Buffer
+		)
+
+		it.Before(func() {
+			mockController = gomock.NewController(t)
+			mockImageFactory = mocks.NewMockImageFactory(mockController)
+
+			factory = &pack.BuildFactory{
+				ImageFactory: mockImageFactory,
+				Config: &config.Config{
+					DefaultBuilder:  "default/builder",
+					TrustedBuilders: []string{"recorded/builder"},
+					Stacks: []config.Stack{
+						{
+							ID:        "some.stack.id",
+							RunImages: []string{"default/run"},
+						},
+					},
+				},
+				Logger: logging.NewLogger(&outBuf, &errBuf, true, false),
+			}
+		})
+
+		it.After(func() {
+			mockController.Finish()
+		})
+
+		it("uses the builder, run image, buildpacks and env recorded on the image", func() {
+			mockAppImage := mocks.NewMockImage(mockController)
+			mockAppImage.EXPECT().Label("io.buildpacks.pack.build").Return(`{"appDir":"/some/app/dir","builder":"recorded/builder","runImage":"recorded/run","buildpacks":["some.bp@1.2.3"],"env":["PATH"]}`, nil)
+			mockImageFactory.EXPECT().NewLocal("some/app", true).Return(mockAppImage, nil)
+
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("recorded/builder", true).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("recorded/run", false).Return(mockRunImage, nil)
+			mockImageFactory.EXPECT().NewLocal("recorded/run", true).Return(mockRunImage, nil)
+
+			config, err := factory.RebuildConfigFromFlags(&pack.RebuildFlags{
+				RepoName: "some/app",
+			})
+			h.AssertNil(t, err)
+			h.AssertEq(t, config.AppDir, "/some/app/dir")
+			h.AssertEq(t, config.Builder, "recorded/builder")
+			h.AssertEq(t, config.RunImage, "recorded/run")
+			h.AssertEq(t, config.Buildpacks, []string{"some.bp@1.2.3"})
+			h.AssertEq(t, config.EnvFile, map[string]string{"PATH": os.Getenv("PATH")})
+		})
+
+		it("fails naming the recorded env that is not set", func() {
+			mockAppImage := mocks.NewMockImage(mockController)
+			mockAppImage.EXPECT().Label("io.buildpacks.pack.build").Return(`{"appDir":"/some/app/dir","builder":"recorded/builder","runImage":"recorded/run","env":["PATH","PACK_REBUILD_TEST_UNSET"]}`, nil)
+			mockImageFactory.EXPECT().NewLocal("some/app", true).Return(mockAppImage, nil)
+
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("recorded/builder", true).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("recorded/run", false).Return(mockRunImage, nil)
+			mockImageFactory.EXPECT().NewLocal("recorded/run", true).Return(mockRunImage, nil)
+
+			h.AssertNil(t, os.Unsetenv("PACK_REBUILD_TEST_UNSET"))
+			_, err := factory.RebuildConfigFromFlags(&pack.RebuildFlags{
+				RepoName: "some/app",
+			})
+			h.AssertError(t, err, "image 'some/app' was built with env 'PACK_REBUILD_TEST_UNSET', which must be set to rebuild it")
+		})
+
+		it("uses the images on the daemon with --pull-policy never", func() {
+			mockAppImage := mocks.NewMockImage(mockController)
+			mockAppImage.EXPECT().Label("io.buildpacks.pack.build").Return(`{"appDir":"/some/app/dir","builder":"recorded/builder","runImage":"recorded/run"}`, nil)
+			mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockAppImage, nil)
+
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("recorded/builder", false).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockImageFactory.EXPECT().NewLocal("recorded/run", false).Return(mockRunImage, nil)
+
+			config, err := factory.RebuildConfigFromFlags(&pack.RebuildFlags{
+				RepoName:   "some/app",
+				PullPolicy: pack.PullNever,
+			})
+			h.AssertNil(t, err)
+			h.AssertEq(t, config.NoPull, true)
+		})
+
+		it("returns an error for an unknown pull policy", func() {
+			_, err := factory.RebuildConfigFromFlags(&pack.RebuildFlags{
+				RepoName:   "some/app",
+				PullPolicy: "sometimes",
+			})
+			h.AssertError(t, err, "invalid pull policy 'sometimes'")
+		})
+
+		it("returns an error when the image has no build metadata", func() {
+			mockAppImage := mocks.NewMockImage(mockController)
+			mockAppImage.EXPECT().Label("io.buildpacks.pack.build").Return("", nil)
+			mockImageFactory.EXPECT().NewRemote("some/app").Return(mockAppImage, nil)
+
+			_, err := factory.RebuildConfigFromFlags(&pack.RebuildFlags{
+				RepoName: "some/app",
+				Publish:  true,
+			})
+			h.AssertError(t, err, "image 'some/app' has no build metadata, it was not built by this version of pack")
+		})
+
+		when("#InspectImage", func() {
+			it("returns the build metadata recorded on a daemon image", func() {
+				mockAppImage := mocks.NewMockImage(mockController)
+				mockAppImage.EXPECT().Label("io.buildpacks.pack.build").Return(`{"packVersion":"1.2.3","builder":"recorded/builder","builderDigest":"recorded/builder@sha256:abc","sourceCommit":"0123abcd","flags":{"publish":true}}`, nil)
+				mockImageFactory.EXPECT().NewLocal("some/app", false).Return(mockAppImage, nil)
+
+				metadata, err := factory.InspectImage("some/app", false)
+				h.AssertNil(t, err)
+				h.AssertEq(t, metadata.PackVersion, "1.2.3")
+				h.AssertEq(t, metadata.Builder, "recorded/builder")
+				h.AssertEq(t, metadata.BuilderDigest, "recorded/builder@sha256:abc")
+				h.AssertEq(t, metadata.SourceCommit, "0123abcd")
+				h.AssertEq(t, metadata.Flags.Publish, true)
+			})
+		})
+	})
+}
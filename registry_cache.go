@@ -0,0 +1,207 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+const (
+	CacheTypeVolume   = "volume"
+	CacheTypeRegistry = "registry"
+)
+
+// CacheOptions select where the build cache is kept, parsed from values such as
+// "type=registry,ref=registry.com/some/app-cache". The cache volume is always used during the build;
+// a registry cache is restored into it before detecting and published from it after exporting.
+// A name, given as "name=some-cache" or just "some-cache", selects a cache volume that may be
+// shared by several images instead of the one derived from the image name.
+type CacheOptions struct {
+	Type string
+	Ref  string
+	Name string
+}
+
+// volumeNameRegexp matches the volume names accepted by the Docker daemon
+var volumeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+func ParseCacheOptions(s string) (CacheOptions, error) {
+	opts := CacheOptions{Type: CacheTypeVolume}
+	if s == "" {
+		return opts, nil
+	}
+	if !strings.ContainsAny(s, "=,") {
+		s = "name=" + s
+	}
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return CacheOptions{}, fmt.Errorf("invalid cache option %s: expected key=value", style.Symbol(field))
+		}
+		switch kv[0] {
+		case "type":
+			opts.Type = kv[1]
+		case "ref":
+			opts.Ref = kv[1]
+		case "name":
+			opts.Name = kv[1]
+		default:
+			return CacheOptions{}, fmt.Errorf("unknown cache option %s", style.Symbol(kv[0]))
+		}
+	}
+
+	if opts.Name != "" && !volumeNameRegexp.MatchString(opts.Name) {
+		return CacheOptions{}, fmt.Errorf("invalid cache name %s: must only contain letters, digits, '_', '.' and '-'", style.Symbol(opts.Name))
+	}
+
+	switch opts.Type {
+	case CacheTypeVolume:
+		if opts.Ref != "" {
+			return CacheOptions{}, fmt.Errorf("cache option %s is only supported with %s", style.Symbol("ref"), style.Symbol("type="+CacheTypeRegistry))
+		}
+	case CacheTypeRegistry:
+		if opts.Ref == "" {
+			return CacheOptions{}, fmt.Errorf("cache type %s requires a %s", style.Symbol(CacheTypeRegistry), style.Symbol("ref"))
+		}
+		if err := validateImageReference("cache ref", opts.Ref); err != nil {
+			return CacheOptions{}, err
+		}
+	default:
+		return CacheOptions{}, fmt.Errorf("unknown cache type %s: must be %s or %s", style.Symbol(opts.Type), style.Symbol(CacheTypeVolume), style.Symbol(CacheTypeRegistry))
+	}
+	return opts, nil
+}
+
+// RestoreRegistryCache copies the layers of the cache image into the cache volume.
+// A missing cache image is expected on first builds and is not an error.
+func (b *BuildConfig) RestoreRegistryCache() error {
+	ref, err := name.ParseReference(b.CacheImage, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		b.Logger.Verbose("No cache restored from %s: %s", style.Symbol(b.CacheImage), err)
+		return nil
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return errors.Wrapf(err, "reading cache image %s", style.Symbol(b.CacheImage))
+	}
+
+	ctx := context.Background()
+	if err := b.createCacheVolume(ctx); err != nil {
+		return err
+	}
+	ctrID, err := createVolumesContainer(ctx, b.Cli, b.Builder, cacheBinds(b.CacheVolume))
+	if err != nil {
+		return err
+	}
+	defer b.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	for _, layer := range layers {
+		if err := copyLayerToContainer(ctx, b.Cli, ctrID, layer.Uncompressed); err != nil {
+			return errors.Wrapf(err, "restoring cache from %s", style.Symbol(b.CacheImage))
+		}
+	}
+	b.Logger.Verbose("Restored cache from %s", style.Symbol(b.CacheImage))
+	return nil
+}
+
+// PublishRegistryCache pushes the contents of the cache volume to the cache image as a single layer
+func (b *BuildConfig) PublishRegistryCache() error {
+	ref, err := name.ParseReference(b.CacheImage, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctrID, err := createVolumesContainer(ctx, b.Cli, b.Builder, cacheBinds(b.CacheVolume))
+	if err != nil {
+		return err
+	}
+	defer b.Cli.ContainerRemove(ctx, ctrID, dockertypes.ContainerRemoveOptions{})
+
+	layerFile, err := ioutil.TempFile("", "pack-cache-layer")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(layerFile.Name())
+	defer layerFile.Close()
+
+	rc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, launchDir)
+	if err != nil {
+		return errors.Wrapf(err, "reading cache volume %s", style.Symbol(b.CacheVolume))
+	}
+	defer rc.Close()
+	if _, err := io.Copy(layerFile, rc); err != nil {
+		return errors.Wrap(err, "writing cache layer")
+	}
+	if err := layerFile.Close(); err != nil {
+		return errors.Wrap(err, "writing cache layer")
+	}
+
+	layer, err := tarball.LayerFromFile(layerFile.Name())
+	if err != nil {
+		return err
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return err
+	}
+	auth, err := authn.DefaultKeychain.Resolve(ref.Context().Registry)
+	if err != nil {
+		return err
+	}
+	if err := remote.Write(ref, img, auth, http.DefaultTransport); err != nil {
+		return errors.Wrapf(err, "publishing cache to %s", style.Symbol(b.CacheImage))
+	}
+	b.Logger.Verbose("Published cache to %s", style.Symbol(b.CacheImage))
+	return nil
+}
+
+func copyLayerToContainer(ctx context.Context, cli Docker, ctrID string, open func() (io.ReadCloser, error)) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return cli.CopyToContainer(ctx, ctrID, "/", rc, dockertypes.CopyToContainerOptions{})
+}
+
+// createCacheContainer creates, but does not start, a container with the cache volume mounted where builds mount it,
+// so its contents can be copied in and out with paths that match between hosts
+func createCacheContainer(ctx context.Context, cli Docker, volume, image string) (string, error) {
+	return createVolumesContainer(ctx, cli, image, []string{volume + ":" + launchDir})
+}
+
+// createVolumesContainer creates, but does not start, a container with binds, see createCacheContainer
+func createVolumesContainer(ctx context.Context, cli Docker, image string, binds []string) (string, error) {
+	ctr, err := cli.ContainerCreate(ctx, &container.Config{
+		Image: image,
+		Cmd:   []string{"true"},
+	}, &container.HostConfig{
+		Binds: binds,
+	}, nil, "")
+	if err != nil {
+		return "", errors.Wrap(err, "create cache container")
+	}
+	return ctr.ID, nil
+}
@@ -0,0 +1,68 @@
+package pack_test
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestRegistryCache(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "registry-cache", testRegistryCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testRegistryCache(t *testing.T, when spec.G, it spec.S) {
+	when("#ParseCacheOptions", func() {
+		it("defaults to a volume cache", func() {
+			opts, err := pack.ParseCacheOptions("")
+			h.AssertNil(t, err)
+			h.AssertEq(t, opts, pack.CacheOptions{Type: pack.CacheTypeVolume})
+		})
+
+		it("parses a registry cache", func() {
+			opts, err := pack.ParseCacheOptions("type=registry,ref=registry.com/some/app-cache")
+			h.AssertNil(t, err)
+			h.AssertEq(t, opts, pack.CacheOptions{Type: pack.CacheTypeRegistry, Ref: "registry.com/some/app-cache"})
+		})
+
+		it("requires a ref for a registry cache", func() {
+			_, err := pack.ParseCacheOptions("type=registry")
+			h.AssertError(t, err, "cache type 'registry' requires a 'ref'")
+		})
+
+		it("rejects unknown types", func() {
+			_, err := pack.ParseCacheOptions("type=bucket,ref=some/cache")
+			h.AssertError(t, err, "unknown cache type 'bucket': must be 'volume' or 'registry'")
+		})
+
+		it("rejects malformed options", func() {
+			_, err := pack.ParseCacheOptions("type=registry,registry")
+			h.AssertError(t, err, "invalid cache option 'registry': expected key=value")
+		})
+
+		it("parses a named cache volume", func() {
+			opts, err := pack.ParseCacheOptions("shared-cache")
+			h.AssertNil(t, err)
+			h.AssertEq(t, opts, pack.CacheOptions{Type: pack.CacheTypeVolume, Name: "shared-cache"})
+
+			opts, err = pack.ParseCacheOptions("type=registry,ref=registry.com/some/app-cache,name=shared-cache")
+			h.AssertNil(t, err)
+			h.AssertEq(t, opts, pack.CacheOptions{Type: pack.CacheTypeRegistry, Ref: "registry.com/some/app-cache", Name: "shared-cache"})
+		})
+
+		it("rejects invalid cache names", func() {
+			_, err := pack.ParseCacheOptions("name=some/cache")
+			h.AssertError(t, err, "invalid cache name 'some/cache': must only contain letters, digits, '_', '.' and '-'")
+		})
+
+		it("rejects malformed refs", func() {
+			_, err := pack.ParseCacheOptions("type=registry,ref=Some/Cache")
+			h.AssertContains(t, err.Error(), "invalid cache ref 'Some/Cache'")
+		})
+	})
+}
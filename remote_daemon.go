@@ -0,0 +1,17 @@
+package pack
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// compressed returns the tar r as it is copied to a container. A remote daemon receives it compressed with
+// gzip, which the daemon decompresses, as the network is much slower than compressing it. The app and
+// buildpacks are already copied once per build, to the workspace volume and the ephemeral builder, which
+// every phase reuses. The returned reader must be closed.
+func (b *BuildConfig) compressed(r io.Reader) io.ReadCloser {
+	if !b.RemoteDaemon {
+		return ioutil.NopCloser(r)
+	}
+	return b.FS.Gzip(r)
+}
@@ -0,0 +1,83 @@
+package pack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// BuildReport describes a built image for tools that consume it downstream, such as CI pipelines
+// pinning the digest of a published image
+type BuildReport struct {
+	Image      string                 `toml:"image" json:"image"`
+	Tags       []string               `toml:"tags,omitempty" json:"tags,omitempty"`
+	ImageID    string                 `toml:"image-id,omitempty" json:"imageId,omitempty"`
+	Digest     string                 `toml:"digest,omitempty" json:"digest,omitempty"`
+	RunImage   string                 `toml:"run-image" json:"runImage"`
+	Buildpacks []BuildReportBuildpack `toml:"buildpacks" json:"buildpacks"`
+}
+
+type BuildReportBuildpack struct {
+	ID      string `toml:"id" json:"id"`
+	Version string `toml:"version" json:"version"`
+}
+
+// Report describes the image produced by Run, with the buildpacks of the group chosen by the detector
+func (b *BuildConfig) Report() (*BuildReport, error) {
+	result, err := b.ReadDetectResult()
+	if err != nil {
+		return nil, err
+	}
+	report := &BuildReport{
+		Image:      b.RepoName,
+		Tags:       b.Tags,
+		RunImage:   b.RunImage,
+		Buildpacks: []BuildReportBuildpack{},
+	}
+	switch id := b.Identifier.(type) {
+	case LocalImageID:
+		report.ImageID = id.String()
+	case RemoteDigestReference:
+		report.Digest = id.Digest
+	}
+	for _, bp := range result.Group.Buildpacks {
+		report.Buildpacks = append(report.Buildpacks, BuildReportBuildpack{ID: bp.ID, Version: bp.Version})
+	}
+	return report, nil
+}
+
+// writeReport writes the report of the build to path, as JSON when it has a .json extension and TOML otherwise
+func (b *BuildConfig) writeReport(path string) error {
+	report, err := b.Report()
+	if err != nil {
+		return err
+	}
+	return writeDocument(path, "report", report)
+}
+
+// writeDocument writes v to path, as JSON when it has a .json extension and TOML otherwise
+func writeDocument(path, kind string, v interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "creating %s %s", kind, style.Symbol(path))
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(v)
+	} else {
+		err = toml.NewEncoder(f).Encode(v)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing %s %s", kind, style.Symbol(path))
+	}
+	return f.Close()
+}
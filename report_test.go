@@ -0,0 +1,93 @@
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/fatih/color"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestBuildReport(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "build-report", testBuildReport, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuildReport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject        *pack.BuildConfig
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		outBuf         bytes.Buffer
+		ctr            container.ContainerCreateCreatedBody
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		subject = &pack.BuildConfig{
+			RepoName:    "registry.com/some/app",
+			RunImage:    "some/run",
+			Builder:     "some/builder",
+			CacheVolume: "some-cache-volume",
+			Cli:         mockDocker,
+			Logger:      logging.NewLogger(&outBuf, &outBuf, false, false),
+		}
+		ctr = container.ContainerCreateCreatedBody{ID: "some-container-id"}
+
+		singleFileTar := func(name, contents string) *bytes.Buffer {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}))
+			_, err := tw.Write([]byte(contents))
+			h.AssertNil(t, err)
+			h.AssertNil(t, tw.Close())
+			return &buf
+		}
+		mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").Return(ctr, nil)
+		mockDocker.EXPECT().ContainerRemove(gomock.Any(), ctr.ID, dockertypes.ContainerRemoveOptions{}).Return(nil)
+		mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/group.toml").
+			Return(ioutil.NopCloser(singleFileTar("group.toml", "[[buildpacks]]\nid = \"some.bp\"\nversion = \"1.2.3\"\n")), dockertypes.ContainerPathStat{}, nil)
+		mockDocker.EXPECT().CopyFromContainer(gomock.Any(), ctr.ID, "/workspace/plan.toml").
+			Return(ioutil.NopCloser(singleFileTar("plan.toml", "")), dockertypes.ContainerPathStat{}, nil)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#Report", func() {
+		it("reports the image ID of a daemon image", func() {
+			subject.Identifier = pack.LocalImageID("sha256:some-image-id")
+
+			r, err := subject.Report()
+			h.AssertNil(t, err)
+			h.AssertEq(t, r, &pack.BuildReport{
+				Image:      "registry.com/some/app",
+				ImageID:    "sha256:some-image-id",
+				RunImage:   "some/run",
+				Buildpacks: []pack.BuildReportBuildpack{{ID: "some.bp", Version: "1.2.3"}},
+			})
+		})
+
+		it("reports the digest of a published image", func() {
+			subject.Identifier = pack.RemoteDigestReference{Repository: "registry.com/some/app", Digest: "sha256:some-digest"}
+
+			r, err := subject.Report()
+			h.AssertNil(t, err)
+			h.AssertEq(t, r.Digest, "sha256:some-digest")
+			h.AssertEq(t, r.ImageID, "")
+		})
+	})
+}
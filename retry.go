@@ -0,0 +1,101 @@
+package pack
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const phaseOutputTailSize = 4096
+
+// retryBackoff is the delay before the first retry of a failed phase; it doubles on every attempt.
+var retryBackoff = 2 * time.Second
+
+// transientErrorPatterns identify daemon and registry failures that are likely to succeed when retried.
+// Deterministic failures (e.g. no buildpacks passing detection) never match these.
+var transientErrorPatterns = []string{
+	"connection reset by peer",
+	"connection refused",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"unexpected EOF",
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+	"429 Too Many Requests",
+}
+
+// phaseError is the failure of a lifecycle phase, e.g. "detector", with the tail of its output
+type phaseError struct {
+	error
+	phase  string
+	output string
+}
+
+// FailedPhase returns the lifecycle phase that failed the build with err, e.g. "detector", "builder" or
+// "exporter", or "" when the build failed for another reason. Failures to save the image to the daemon or
+// push its tags are attributed to the exporter.
+func FailedPhase(err error) string {
+	if pe, ok := errors.Cause(err).(*phaseError); ok {
+		return pe.phase
+	}
+	return ""
+}
+
+// registryPhases are the lifecycle phases that read and write images in registries. Only their output is
+// matched against transientErrorPatterns: the output of the phases running buildpacks is the buildpacks'
+// own, e.g. a test suite run by a buildpack may well print "connection refused".
+var registryPhases = map[string]bool{
+	"analyzer": true,
+	"exporter": true,
+}
+
+func isTransient(err error) bool {
+	text := err.Error()
+	if pe, ok := errors.Cause(err).(*phaseError); ok && registryPhases[pe.phase] {
+		text += "\n" + pe.output
+	}
+	for _, pattern := range transientErrorPatterns {
+		if strings.Contains(text, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BuildConfig) withRetries(phase string, f func() error) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt > b.Retries || !isTransient(err) || b.context().Err() != nil {
+			return err
+		}
+		b.Logger.Info("Phase %s failed with a transient error, retrying in %s (attempt %d of %d): %s", phase, backoff, attempt, b.Retries, err)
+		select {
+		case <-b.context().Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// tailWriter retains the last max bytes written to it
+type tailWriter struct {
+	max int
+	buf []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	return string(w.buf)
+}
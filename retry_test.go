@@ -0,0 +1,184 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestRetry(t *testing.T) {
+	color.NoColor = true
+	retryBackoff = time.Millisecond
+	spec.Run(t, "retry", testRetry, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// TestRetryCanceled runs after the parallel TestRetry specs, as it changes retryBackoff
+func TestRetryCanceled(t *testing.T) {
+	color.NoColor = true
+	retryBackoff = time.Hour
+	defer func() { retryBackoff = time.Millisecond }()
+
+	var outBuf, errBuf bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BuildConfig{
+		Retries: 3,
+		Logger:  logging.NewLogger(&outBuf, &errBuf, true, false),
+		ctx:     ctx,
+	}
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		done <- b.withRetries("analyze", func() error {
+			attempts++
+			return errors.New("connection refused")
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		h.AssertError(t, err, "connection refused")
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting to retry after the build was canceled")
+	}
+	h.AssertEq(t, attempts, 1)
+}
+
+func testRetry(t *testing.T, when spec.G, it spec.S) {
+	when("#isTransient", func() {
+		it("matches daemon and registry failures that may pass when retried", func() {
+			for _, err := range []error{
+				errors.New("Error response from daemon: 503 Service Unavailable"),
+				errors.Wrap(errors.New("read tcp 10.0.0.1:443: connection reset by peer"), "pulling image"),
+				errors.Wrap(&phaseError{
+					error:  errors.New("failed with status code: 1"),
+					phase:  "exporter",
+					output: "*** Images:\n      registry.com/some/app - PUT https://registry.com/v2/: 502 Bad Gateway\n",
+				}, "run export container"),
+			} {
+				h.AssertEq(t, isTransient(err), true)
+			}
+		})
+
+		it("does not match deterministic failures", func() {
+			for _, err := range []error{
+				errors.New("invalid reference format"),
+				errors.Wrap(&phaseError{
+					error:  errors.New("failed with status code: 6"),
+					phase:  "detector",
+					output: "Error: failed to detect: no buildpacks participating\n",
+				}, "run detect container"),
+				errors.Wrap(&phaseError{
+					error:  errors.New("failed with status code: 7"),
+					phase:  "builder",
+					output: "FAIL: TestDB: dial tcp 127.0.0.1:5432: connect: connection refused\n",
+				}, "run build container"),
+			} {
+				h.AssertEq(t, isTransient(err), false)
+			}
+		})
+	})
+
+	when("#FailedPhase", func() {
+		it("returns the phase of a wrapped phase error", func() {
+			err := errors.Wrap(&phaseError{error: errors.New("failed with status code: 7"), phase: "builder"}, "run build container")
+			h.AssertEq(t, FailedPhase(err), "builder")
+		})
+
+		it("returns an empty phase for other errors", func() {
+			h.AssertEq(t, FailedPhase(errors.New("daemon unreachable")), "")
+		})
+	})
+
+	when("#withRetries", func() {
+		var (
+			b              *BuildConfig
+			outBuf, errBuf bytes.Buffer
+			attempts       int
+		)
+
+		it.Before(func() {
+			attempts = 0
+			b = &BuildConfig{
+				Retries: 3,
+				Logger:  logging.NewLogger(&outBuf, &errBuf, true, false),
+			}
+		})
+
+		failing := func(times int, err error) func() error {
+			return func() error {
+				attempts++
+				if attempts <= times {
+					return err
+				}
+				return nil
+			}
+		}
+
+		it("retries transient failures with a doubling backoff", func() {
+			h.AssertNil(t, b.withRetries("analyze", failing(2, errors.New("503 Service Unavailable"))))
+
+			h.AssertEq(t, attempts, 3)
+			h.AssertContains(t, outBuf.String(), "Phase analyze failed with a transient error, retrying in 1ms (attempt 1 of 3): 503 Service Unavailable")
+			h.AssertContains(t, outBuf.String(), "Phase analyze failed with a transient error, retrying in 2ms (attempt 2 of 3): 503 Service Unavailable")
+		})
+
+		it("returns the last error once the retries are used up", func() {
+			err := b.withRetries("export", failing(10, errors.New("i/o timeout")))
+
+			h.AssertError(t, err, "i/o timeout")
+			h.AssertEq(t, attempts, 4)
+		})
+
+		it("does not retry deterministic failures", func() {
+			err := b.withRetries("detect", failing(1, &phaseError{error: errors.New("failed with status code: 6"), phase: "detector"}))
+
+			h.AssertEq(t, FailedPhase(err), "detector")
+			h.AssertEq(t, attempts, 1)
+		})
+
+		it("does not retry without --retries", func() {
+			b.Retries = 0
+
+			h.AssertError(t, b.withRetries("analyze", failing(1, errors.New("connection refused"))), "connection refused")
+			h.AssertEq(t, attempts, 1)
+		})
+
+		it("does not retry once the build is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			b.ctx = ctx
+
+			h.AssertError(t, b.withRetries("analyze", failing(1, errors.New("connection refused"))), "connection refused")
+			h.AssertEq(t, attempts, 1)
+		})
+	})
+
+	when("#tailWriter", func() {
+		it("keeps the last max bytes written", func() {
+			w := &tailWriter{max: 5}
+			for _, s := range []string{"abc", "defgh", "ij"} {
+				n, err := w.Write([]byte(s))
+				h.AssertNil(t, err)
+				h.AssertEq(t, n, len(s))
+			}
+			h.AssertEq(t, w.String(), "fghij")
+		})
+
+		it("keeps all the output shorter than max", func() {
+			w := &tailWriter{max: phaseOutputTailSize}
+			_, err := w.Write([]byte("ERROR: No buildpack groups passed detection.\n"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, w.String(), "ERROR: No buildpack groups passed detection.\n")
+		})
+	})
+}
@@ -1,43 +1,76 @@
 package pack
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/style"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
 	"github.com/pkg/errors"
 )
 
+var portMappingPollInterval = 100 * time.Millisecond
+
 type RunFlags struct {
 	BuildFlags BuildFlags
 	Ports      []string
+	PublishAll bool
+	Detach     bool
+	Memory     string
+	CPUs       float64
 }
 
 type RunConfig struct {
-	Ports []string
-	Build Task
+	Ports      []string
+	PublishAll bool
+	Detach     bool
+	Resources  container.Resources
+	Build      Task
+	// Env holds the variables of --env and --env-file, which the app gets as well as the buildpacks,
+	// in the form 'VAR=VALUE'
+	Env []string
 	// All below are from BuildConfig
 	RepoName string
+	Bindings []string
+	Volumes  []string
 	Cli      Docker
 	Logger   *logging.Logger
 }
 
 func (bf *BuildFactory) RunConfigFromFlags(f *RunFlags) (*RunConfig, error) {
+	resources, err := parseResources(f.Memory, f.CPUs)
+	if err != nil {
+		return nil, FlagError{err}
+	}
 	bc, err := bf.BuildConfigFromFlags(&f.BuildFlags)
 	if err != nil {
 		return nil, err
 	}
+	env := map[string]string{}
+	if err := mergeEnv(env, f.BuildFlags.EnvFiles, f.BuildFlags.Env); err != nil {
+		return nil, FlagError{err}
+	}
 	rc := &RunConfig{
-		Build: bc,
-		Ports: f.Ports,
+		Build:      bc,
+		Ports:      f.Ports,
+		PublishAll: f.PublishAll,
+		Detach:     f.Detach,
+		Resources:  resources,
+		Env:        containerEnv(env),
 		// All below are from BuildConfig
 		RepoName: bc.RepoName,
+		Bindings: bc.Bindings,
+		Volumes:  bc.Volumes,
 		Cli:      bc.Cli,
 		Logger:   bc.Logger,
 	}
@@ -73,7 +106,7 @@ func (r *RunConfig) Run(makeStopCh func() <-chan struct{}) error {
 	}
 
 	r.Logger.Verbose(style.Step("RUNNING"))
-	if r.Ports == nil {
+	if r.Ports == nil && !r.PublishAll {
 		r.Ports, err = r.exposedPorts(ctx, r.RepoName)
 		if err != nil {
 			return err
@@ -88,12 +121,36 @@ func (r *RunConfig) Run(makeStopCh func() <-chan struct{}) error {
 		AttachStdout: true,
 		AttachStderr: true,
 		ExposedPorts: exposedPorts,
+		Env:          r.Env,
 	}, &container.HostConfig{
-		AutoRemove:   true,
-		PortBindings: portBindings,
+		Binds:           append(r.Bindings, r.Volumes...),
+		AutoRemove:      true,
+		PortBindings:    portBindings,
+		PublishAllPorts: r.PublishAll,
+		Resources:       r.Resources,
 	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "create container")
+	}
+	if r.Detach {
+		return r.runDetached(ctx, ctr.ID, portBindings)
+	}
 
-	logContainerListening(r.Logger, portBindings)
+	reportDone := make(chan struct{})
+	reportCtx, cancelReport := context.WithCancel(ctx)
+	defer func() {
+		cancelReport()
+		<-reportDone
+	}()
+	if r.PublishAll {
+		go func() {
+			defer close(reportDone)
+			r.logPortMappings(reportCtx, ctr.ID)
+		}()
+	} else {
+		close(reportDone)
+		logContainerListening(r.Logger, portBindings)
+	}
 	running := true
 	stopCh := makeStopCh()
 	go func() {
@@ -110,6 +167,31 @@ func (r *RunConfig) Run(makeStopCh func() <-chan struct{}) error {
 	return nil
 }
 
+// runDetached starts the container and returns once it is running, printing its name and the ports it is
+// reachable at. The container is removed when it stops.
+func (r *RunConfig) runDetached(ctx context.Context, ctrID string, portBindings nat.PortMap) error {
+	if err := r.Cli.ContainerStart(ctx, ctrID, types.ContainerStartOptions{}); err != nil {
+		return errors.Wrap(err, "container start")
+	}
+	ctr, err := r.Cli.ContainerInspect(ctx, ctrID)
+	if err != nil {
+		return errors.Wrap(err, "inspect container")
+	}
+	name := ctrID
+	if ctr.ContainerJSONBase != nil && ctr.Name != "" {
+		name = strings.TrimPrefix(ctr.Name, "/")
+	}
+	r.Logger.Info("Started container %s (%s), stop it with %s", style.Symbol(name), ctrID, style.Symbol("docker stop "+name))
+	if r.PublishAll {
+		if ctr.NetworkSettings != nil {
+			logPortTable(r.Logger, ctr.NetworkSettings.Ports)
+		}
+	} else {
+		logContainerListening(r.Logger, portBindings)
+	}
+	return nil
+}
+
 func (r *RunConfig) exposedPorts(ctx context.Context, imageID string) ([]string, error) {
 	i, _, err := r.Cli.ImageInspectWithRaw(ctx, imageID)
 	if err != nil {
@@ -122,6 +204,84 @@ func (r *RunConfig) exposedPorts(ctx context.Context, imageID string) ([]string,
 	return ports, nil
 }
 
+// logPortMappings waits for the container to be running and prints the host address of each published port
+func (r *RunConfig) logPortMappings(ctx context.Context, ctrID string) {
+	ticker := time.NewTicker(portMappingPollInterval)
+	defer ticker.Stop()
+	for {
+		ctr, err := r.Cli.ContainerInspect(ctx, ctrID)
+		if err == nil && ctr.ContainerJSONBase != nil && ctr.State != nil && ctr.State.Running && ctr.NetworkSettings != nil {
+			logPortTable(r.Logger, ctr.NetworkSettings.Ports)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func logPortTable(logger *logging.Logger, ports nat.PortMap) {
+	var containerPorts []nat.Port
+	for port, bindings := range ports {
+		if len(bindings) > 0 {
+			containerPorts = append(containerPorts, port)
+		}
+	}
+	if len(containerPorts) == 0 {
+		logger.Info("Container is running with no published ports")
+		return
+	}
+	sort.Slice(containerPorts, func(i, j int) bool {
+		if containerPorts[i].Int() != containerPorts[j].Int() {
+			return containerPorts[i].Int() < containerPorts[j].Int()
+		}
+		return containerPorts[i].Proto() < containerPorts[j].Proto()
+	})
+
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER PORT\tHOST ADDRESS")
+	for _, port := range containerPorts {
+		for _, binding := range ports[port] {
+			host := binding.HostIP
+			if host == "" || host == "0.0.0.0" || host == "127.0.0.1" {
+				host = "localhost"
+			}
+			fmt.Fprintf(tw, "%s\t%s:%s\n", port, host, binding.HostPort)
+		}
+	}
+	tw.Flush()
+	logger.Info("Container is running with published ports:\n%s", buf.String())
+}
+
+// containerEnv returns env in the form of a container's config, sorted by name
+func containerEnv(env map[string]string) []string {
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+func parseResources(memory string, cpus float64) (container.Resources, error) {
+	var resources container.Resources
+	if memory != "" {
+		limit, err := units.RAMInBytes(memory)
+		if err != nil {
+			return container.Resources{}, errors.Wrapf(err, "invalid memory limit %s", style.Symbol(memory))
+		}
+		resources.Memory = limit
+	}
+	if cpus < 0 {
+		return container.Resources{}, fmt.Errorf("invalid cpus limit %s: must not be negative", style.Symbol(fmt.Sprint(cpus)))
+	}
+	resources.NanoCPUs = int64(cpus * 1e9)
+	return resources, nil
+}
+
 func parsePorts(ports []string) (nat.PortSet, nat.PortMap, error) {
 	for i, p := range ports {
 		p = strings.TrimSpace(p)
@@ -8,7 +8,9 @@ import (
 	"github.com/buildpack/pack/logging"
 	"github.com/fatih/color"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -71,6 +73,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				FS:           &fs.FS{},
 				ImageFactory: mockImageFactory,
 				Config: &config.Config{
+					TrustedBuilders: []string{"some/builder"},
 					Stacks: []config.Stack{
 						{
 							ID:        "some.stack.id",
@@ -89,10 +92,13 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 		it("creates a RunConfig derived from a BuildConfig", func() {
 			mockBuilderImage := mocks.NewMockImage(mockController)
 			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
 			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
 
 			mockRunImage := mocks.NewMockImage(mockController)
 			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
 			mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
 
 			run, err := factory.RunConfigFromFlags(&pack.RunFlags{
@@ -125,6 +131,79 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("sets resource limits on the RunConfig", func() {
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+			run, err := factory.RunConfigFromFlags(&pack.RunFlags{
+				BuildFlags: pack.BuildFlags{
+					AppDir:   "acceptance/testdata/node_app",
+					Builder:  "some/builder",
+					RunImage: "some/run",
+				},
+				Memory: "512m",
+				CPUs:   1.5,
+			})
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, run.Resources.Memory, int64(512*1024*1024))
+			h.AssertEq(t, run.Resources.NanoCPUs, int64(1500000000))
+		})
+
+		it("passes --env and --env-file to the app container", func() {
+			mockBuilderImage := mocks.NewMockImage(mockController)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockBuilderImage.EXPECT().Label("io.buildpacks.builder.metadata").Return("", nil).AnyTimes()
+			mockImageFactory.EXPECT().NewLocal("some/builder", true).Return(mockBuilderImage, nil)
+
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockRunImage.EXPECT().Label("io.buildpacks.stack.id").Return("some.stack.id", nil)
+			mockRunImage.EXPECT().Found().Return(false, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", false).Return(mockRunImage, nil)
+			mockImageFactory.EXPECT().NewLocal("some/run", true).Return(mockRunImage, nil)
+
+			envFile, err := ioutil.TempFile("", "pack.run.env.")
+			h.AssertNil(t, err)
+			defer os.Remove(envFile.Name())
+			_, err = envFile.WriteString("DATABASE_URL=postgres://localhost/dev\nLOG_LEVEL=info\n")
+			h.AssertNil(t, err)
+			h.AssertNil(t, envFile.Close())
+
+			run, err := factory.RunConfigFromFlags(&pack.RunFlags{
+				BuildFlags: pack.BuildFlags{
+					AppDir:   "acceptance/testdata/node_app",
+					Builder:  "some/builder",
+					RunImage: "some/run",
+					EnvFiles: []string{envFile.Name()},
+					Env:      []string{"LOG_LEVEL=debug"},
+				},
+			})
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, run.Env, []string{"DATABASE_URL=postgres://localhost/dev", "LOG_LEVEL=debug"})
+			build := run.Build.(*pack.BuildConfig)
+			h.AssertEq(t, build.EnvFile, map[string]string{"DATABASE_URL": "postgres://localhost/dev", "LOG_LEVEL": "debug"})
+		})
+
+		it("returns an error for an invalid memory limit", func() {
+			_, err := factory.RunConfigFromFlags(&pack.RunFlags{
+				BuildFlags: pack.BuildFlags{
+					AppDir:   "acceptance/testdata/node_app",
+					Builder:  "some/builder",
+					RunImage: "some/run",
+				},
+				Memory: "lots",
+			})
+			h.AssertContains(t, err.Error(), "invalid memory limit 'lots'")
+		})
 	})
 
 	when("#Run", func() {
@@ -246,6 +325,183 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, err)
 			})
 		})
+		when("publish all is set", func() {
+			it.Before(func() {
+				subject.Ports = nil
+				subject.PublishAll = true
+			})
+
+			it("publishes exposed ports to random host ports and prints the mapping", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+					Image:        subject.RepoName,
+					AttachStdout: true,
+					AttachStderr: true,
+					ExposedPorts: nat.PortSet{},
+				}, &container.HostConfig{
+					AutoRemove:      true,
+					PortBindings:    nat.PortMap{},
+					PublishAllPorts: true,
+				}, nil, "").Return(ctr, nil)
+
+				inspected := make(chan struct{})
+				mockDocker.EXPECT().ContainerInspect(gomock.Any(), ctr.ID).DoAndReturn(func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					defer close(inspected)
+					return types.ContainerJSON{
+						ContainerJSONBase: &types.ContainerJSONBase{
+							State: &types.ContainerState{Running: true},
+						},
+						NetworkSettings: &types.NetworkSettings{
+							NetworkSettingsBase: types.NetworkSettingsBase{
+								Ports: nat.PortMap{
+									"8080/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}},
+									"443/tcp":  []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "32769"}},
+								},
+							},
+						},
+					}, nil
+				})
+				mockDocker.EXPECT().RunContainer(gomock.Any(), ctr.ID, gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, id string, stdout io.Writer, stderr io.Writer) error {
+					<-inspected
+					return nil
+				})
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, outBuf.String(), "443/tcp         localhost:32769")
+				h.AssertContains(t, outBuf.String(), "8080/tcp        localhost:32768")
+			})
+		})
+
+		when("detach is set", func() {
+			it.Before(func() {
+				subject.Detach = true
+			})
+
+			it("starts the container and returns without streaming its logs", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				exposedPorts, portBindings, _ := nat.ParsePortSpecs([]string{"127.0.0.1:1370:1370/tcp"})
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+					Image:        subject.RepoName,
+					AttachStdout: true,
+					AttachStderr: true,
+					ExposedPorts: exposedPorts,
+				}, &container.HostConfig{
+					AutoRemove:   true,
+					PortBindings: portBindings,
+				}, nil, "").Return(ctr, nil)
+				mockDocker.EXPECT().ContainerStart(gomock.Any(), ctr.ID, types.ContainerStartOptions{}).Return(nil)
+				mockDocker.EXPECT().ContainerInspect(gomock.Any(), ctr.ID).Return(types.ContainerJSON{
+					ContainerJSONBase: &types.ContainerJSONBase{Name: "/some-name"},
+				}, nil)
+				mockDocker.EXPECT().RunContainer(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, outBuf.String(), "Started container 'some-name' (29aef5a011dd), stop it with 'docker stop some-name'")
+				h.AssertContains(t, outBuf.String(), "Starting container listening at http://localhost:1370/")
+			})
+
+			it("prints the host ports when publishing all ports", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				subject.Ports = nil
+				subject.PublishAll = true
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").Return(ctr, nil)
+				mockDocker.EXPECT().ContainerStart(gomock.Any(), ctr.ID, types.ContainerStartOptions{}).Return(nil)
+				mockDocker.EXPECT().ContainerInspect(gomock.Any(), ctr.ID).Return(types.ContainerJSON{
+					ContainerJSONBase: &types.ContainerJSONBase{Name: "/some-name"},
+					NetworkSettings: &types.NetworkSettings{
+						NetworkSettingsBase: types.NetworkSettingsBase{
+							Ports: nat.PortMap{"8080/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}}},
+						},
+					},
+				}, nil)
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, outBuf.String(), "8080/tcp        localhost:32768")
+			})
+		})
+
+		when("volumes are set", func() {
+			it("mounts them into the container after the bindings", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				subject.Bindings = []string{"/some/bindings/db:/platform/bindings/db:ro"}
+				subject.Volumes = []string{"/some/data:/data:ro", "/some/public:/srv/public:rw"}
+				exposedPorts, portBindings, _ := nat.ParsePortSpecs([]string{"127.0.0.1:1370:1370/tcp"})
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+					Image:        subject.RepoName,
+					AttachStdout: true,
+					AttachStderr: true,
+					ExposedPorts: exposedPorts,
+				}, &container.HostConfig{
+					Binds:        []string{"/some/bindings/db:/platform/bindings/db:ro", "/some/data:/data:ro", "/some/public:/srv/public:rw"},
+					AutoRemove:   true,
+					PortBindings: portBindings,
+				}, nil, "").Return(ctr, nil)
+
+				mockDocker.EXPECT().RunContainer(gomock.Any(), ctr.ID, gomock.Any(), gomock.Any()).Return(nil)
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+			})
+		})
+
+		when("env is set", func() {
+			it("sets it on the container", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				subject.Env = []string{"LOG_LEVEL=debug"}
+				exposedPorts, portBindings, _ := nat.ParsePortSpecs([]string{"127.0.0.1:1370:1370/tcp"})
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+					Image:        subject.RepoName,
+					AttachStdout: true,
+					AttachStderr: true,
+					ExposedPorts: exposedPorts,
+					Env:          []string{"LOG_LEVEL=debug"},
+				}, &container.HostConfig{
+					AutoRemove:   true,
+					PortBindings: portBindings,
+				}, nil, "").Return(ctr, nil)
+
+				mockDocker.EXPECT().RunContainer(gomock.Any(), ctr.ID, gomock.Any(), gomock.Any()).Return(nil)
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+			})
+		})
+
+		when("resource limits are set", func() {
+			it("applies them to the container", func() {
+				mockBuild.EXPECT().Run().Return(nil)
+
+				subject.Resources = container.Resources{Memory: 1024 * 1024 * 1024, NanoCPUs: 2000000000}
+				exposedPorts, portBindings, _ := nat.ParsePortSpecs([]string{"127.0.0.1:1370:1370/tcp"})
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), &container.Config{
+					Image:        subject.RepoName,
+					AttachStdout: true,
+					AttachStderr: true,
+					ExposedPorts: exposedPorts,
+				}, &container.HostConfig{
+					AutoRemove:   true,
+					PortBindings: portBindings,
+					Resources:    container.Resources{Memory: 1024 * 1024 * 1024, NanoCPUs: 2000000000},
+				}, nil, "").Return(ctr, nil)
+
+				mockDocker.EXPECT().RunContainer(gomock.Any(), ctr.ID, gomock.Any(), gomock.Any()).Return(nil)
+
+				err := subject.Run(makeStopCh)
+				h.AssertNil(t, err)
+			})
+		})
+
 		when("custom ports bindings are defined", func() {
 			it("binds simple ports from localhost to the container on the same port", func() {
 				mockBuild.EXPECT().Run().Return(nil)
@@ -0,0 +1,50 @@
+package pack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/buildpack/pack/style"
+)
+
+// phaseHostConfig is the host config of the detector and builder containers, which run the code of the
+// builder's buildpacks. With Sandbox set they run without network access, on a read-only root filesystem
+// with a temporary /tmp, and without capabilities or a way to gain privileges, so a third-party builder
+// can only write to the workspace. The buildpacks and platform dirs, which pack copies files to, are
+// anonymous volumes filled from the builder, as files can't be copied to a read-only root filesystem.
+func (b *BuildConfig) phaseHostConfig() *container.HostConfig {
+	hostConfig := &container.HostConfig{
+		Binds:       b.buildpackBinds(),
+		Resources:   b.Resources,
+		NetworkMode: container.NetworkMode(b.Network),
+	}
+	if b.Sandbox {
+		hostConfig.NetworkMode = "none"
+		hostConfig.ReadonlyRootfs = true
+		hostConfig.Tmpfs = map[string]string{"/tmp": "rw,exec"}
+		hostConfig.CapDrop = []string{"ALL"}
+		hostConfig.SecurityOpt = []string{"no-new-privileges"}
+		hostConfig.Mounts = []mount.Mount{
+			{Type: mount.TypeVolume, Target: buildpacksDir},
+			{Type: mount.TypeVolume, Target: platformDir},
+		}
+	}
+	return hostConfig
+}
+
+// validateSandbox rejects the flags that would let sandboxed buildpacks reach the network or the host's
+// docker daemon
+func validateSandbox(f *BuildFlags) error {
+	if f.Network != "" && f.Network != "none" {
+		return fmt.Errorf("%s cannot be used with %s, which runs buildpacks without network access", style.Symbol("--network "+f.Network), style.Symbol("--sandbox"))
+	}
+	for _, v := range f.Volumes {
+		if strings.HasSuffix(strings.SplitN(v, ":", 2)[0], "docker.sock") {
+			return fmt.Errorf("volume %s cannot be used with %s, buildpacks must not reach the docker daemon", style.Symbol(v), style.Symbol("--sandbox"))
+		}
+	}
+	return nil
+}
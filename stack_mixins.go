@@ -0,0 +1,63 @@
+package pack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// StackMixinsLabel lists, as a JSON array, the mixins of an image: the packages it has beyond those of its
+// stack. Mixins prefixed 'build:' are only needed to build, those prefixed 'run:' only at launch.
+const StackMixinsLabel = "io.buildpacks.stack.mixins"
+
+// validateMixins checks that the run image has the mixins of the builder the app may need at launch, so
+// the image doesn't fail to start for lack of a package. Images without the label are not checked.
+func (b *BuildConfig) validateMixins(builder, run types.ImageInspect) error {
+	builderMixins, err := imageMixins(builder, "builder", b.Builder)
+	if err != nil || builderMixins == nil {
+		return err
+	}
+	runMixins, err := imageMixins(run, "run", b.RunImage)
+	if err != nil || runMixins == nil {
+		return err
+	}
+
+	provided := map[string]bool{}
+	for _, m := range runMixins {
+		provided[strings.TrimPrefix(m, "run:")] = true
+	}
+	var missing []string
+	for _, m := range builderMixins {
+		if strings.HasPrefix(m, "build:") {
+			continue
+		}
+		if m = strings.TrimPrefix(m, "run:"); !provided[m] {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid stack: run image %s is missing mixins %s of builder %s", style.Symbol(b.RunImage), style.Symbol(strings.Join(missing, ", ")), style.Symbol(b.Builder))
+	}
+	return nil
+}
+
+// imageMixins returns the mixins of an image, or nil when it has no StackMixinsLabel
+func imageMixins(img types.ImageInspect, kind, repoName string) ([]string, error) {
+	if img.Config == nil {
+		return nil, nil
+	}
+	label, ok := img.Config.Labels[StackMixinsLabel]
+	if !ok {
+		return nil, nil
+	}
+	mixins := []string{}
+	if err := json.Unmarshal([]byte(label), &mixins); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s image %s: label %s", kind, style.Symbol(repoName), style.Symbol(StackMixinsLabel))
+	}
+	return mixins, nil
+}
@@ -0,0 +1,72 @@
+package pack
+
+import (
+	"context"
+	"time"
+
+	"github.com/buildpack/lifecycle/image"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// staleBuilderAge is how much older than the builder in its registry a local builder may be before builds
+// using it without pulling warn about it
+const staleBuilderAge = 30 * 24 * time.Hour
+
+// RegistryImageCreated returns the created time of the image repoName in its registry
+func RegistryImageCreated(repoName string) (time.Time, error) {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return time.Time{}, err
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "reading image %s", style.Symbol(repoName))
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return config.Created.Time, nil
+}
+
+// warnIfStaleBuilder warns when the builder on the daemon, used without pulling, was created well before the
+// builder in its registry: its buildpacks may behave differently from those other builds use. Builders that
+// were never pulled, and failures to reach the registry, are ignored.
+func (bf *BuildFactory) warnIfStaleBuilder(builder string, local image.Image) {
+	if bf.RegistryImageCreated == nil {
+		return
+	}
+	localDigest, err := local.Digest()
+	if err != nil || localDigest == "" {
+		return
+	}
+	remoteImage, err := bf.ImageFactory.NewRemote(builder)
+	if err != nil {
+		return
+	}
+	if remoteDigest, err := remoteImage.Digest(); err != nil || remoteDigest == localDigest {
+		return
+	}
+	inspect, _, err := bf.Cli.ImageInspectWithRaw(context.Background(), builder)
+	if err != nil {
+		return
+	}
+	localCreated, err := time.Parse(time.RFC3339Nano, inspect.Created)
+	if err != nil {
+		return
+	}
+	remoteCreated, err := bf.RegistryImageCreated(builder)
+	if err != nil {
+		return
+	}
+	if behind := remoteCreated.Sub(localCreated); behind >= staleBuilderAge {
+		bf.Logger.Warn("Builder %s is %d days older than the builder in its registry, pull it with %s to build with current buildpacks", style.Symbol(builder), int(behind.Hours()/24), style.Symbol("--pull-policy always"))
+		return
+	}
+	bf.Logger.Verbose("Builder %s differs from the builder in its registry", style.Symbol(builder))
+}
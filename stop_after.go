@@ -0,0 +1,37 @@
+package pack
+
+import (
+	"fmt"
+
+	"github.com/buildpack/pack/style"
+)
+
+// Phases a build can stop after with --stop-after
+const (
+	StopAfterDetect  = "detect"
+	StopAfterAnalyze = "analyze"
+	StopAfterBuild   = "build"
+)
+
+func validateStopAfter(f *BuildFlags) error {
+	switch f.StopAfter {
+	case "", StopAfterDetect, StopAfterAnalyze, StopAfterBuild:
+	default:
+		return fmt.Errorf("invalid %s %s: must be one of %s, %s or %s", style.Symbol("--stop-after"), style.Symbol(f.StopAfter), style.Symbol(StopAfterDetect), style.Symbol(StopAfterAnalyze), style.Symbol(StopAfterBuild))
+	}
+	if f.StopAfter != "" && f.DetectOnly {
+		return fmt.Errorf("%s cannot be used with %s", style.Symbol("--stop-after"), style.Symbol("--detect-only"))
+	}
+	return nil
+}
+
+// stopsAfter reports whether the build stops after phase, leaving the workspace as the phase left it
+func (b *BuildConfig) stopsAfter(phase string) bool {
+	if b.StopAfter != phase {
+		return false
+	}
+	b.Logger.Info("Stopped after the %s phase, the workspace is kept in volume %s", phase, style.Symbol(b.CacheVolume))
+	b.Logger.Info("Inspect it with: docker run --rm -it --user root -v %s:%s -v %s:%s --entrypoint /bin/sh %s",
+		b.CacheVolume, launchDir, LayersCacheVolume(b.CacheVolume), layersCacheDir, b.Builder)
+	return true
+}
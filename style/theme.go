@@ -0,0 +1,134 @@
+package style
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Theme names the colors used for each kind of styled output.
+// A color is a name such as "cyan", optionally prefixed by "hi-" and/or "bold-", e.g. "bold-hi-blue".
+type Theme struct {
+	Step   string
+	Symbol string
+	Tip    string
+	Error  string
+	Warn   string
+	Prefix string
+}
+
+var Themes = map[string]Theme{
+	"dark": {
+		Step:   "cyan",
+		Symbol: "magenta",
+		Tip:    "bold-green",
+		Error:  "bold-red",
+		Warn:   "bold-yellow",
+		Prefix: "cyan",
+	},
+	"light": {
+		Step:   "bold-blue",
+		Symbol: "magenta",
+		Tip:    "bold-green",
+		Error:  "bold-red",
+		Warn:   "bold-magenta",
+		Prefix: "blue",
+	},
+}
+
+var colors = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+// ApplyTheme starts from the named built-in theme ("dark" when empty) and overrides any color set in overrides.
+func ApplyTheme(name string, overrides Theme) error {
+	if name == "" {
+		name = "dark"
+	}
+	theme, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown color theme %s", Symbol(name))
+	}
+	theme = theme.merge(overrides)
+
+	step, err := parseColor(theme.Step)
+	if err != nil {
+		return err
+	}
+	symbol, err := parseColor(theme.Symbol)
+	if err != nil {
+		return err
+	}
+	tip, err := parseColor(theme.Tip)
+	if err != nil {
+		return err
+	}
+	errColor, err := parseColor(theme.Error)
+	if err != nil {
+		return err
+	}
+	warn, err := parseColor(theme.Warn)
+	if err != nil {
+		return err
+	}
+	prefix, err := parseColor(theme.Prefix)
+	if err != nil {
+		return err
+	}
+
+	Key = symbol.SprintfFunc()
+	Tip = tip.SprintfFunc()
+	Error = errColor.SprintfFunc()
+	Warn = warn.SprintfFunc()
+	Prefix = prefix.SprintfFunc()
+	Step = func(format string, a ...interface{}) string {
+		return step.Sprintf("===> "+format, a...)
+	}
+	return nil
+}
+
+func (t Theme) merge(overrides Theme) Theme {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&t.Step, overrides.Step},
+		{&t.Symbol, overrides.Symbol},
+		{&t.Tip, overrides.Tip},
+		{&t.Error, overrides.Error},
+		{&t.Warn, overrides.Warn},
+		{&t.Prefix, overrides.Prefix},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+	return t
+}
+
+func parseColor(s string) (*color.Color, error) {
+	var attrs []color.Attribute
+	name := strings.ToLower(s)
+	if strings.HasPrefix(name, "bold-") {
+		attrs = append(attrs, color.Bold)
+		name = strings.TrimPrefix(name, "bold-")
+	}
+	offset := color.Attribute(0)
+	if strings.HasPrefix(name, "hi-") {
+		offset = color.FgHiBlack - color.FgBlack
+		name = strings.TrimPrefix(name, "hi-")
+	}
+	fg, ok := colors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown color %s", Symbol(s))
+	}
+	return color.New(append(attrs, fg+offset)...), nil
+}
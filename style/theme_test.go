@@ -0,0 +1,89 @@
+package style
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestTheme(t *testing.T) {
+	// not parallel, themes are applied to the package's style funcs
+	spec.Run(t, "theme", testTheme, spec.Report(report.Terminal{}))
+}
+
+func testTheme(t *testing.T, when spec.G, it spec.S) {
+	var noColor bool
+
+	it.Before(func() {
+		noColor = color.NoColor
+		color.NoColor = false
+	})
+
+	it.After(func() {
+		h.AssertNil(t, ApplyTheme("dark", Theme{}))
+		color.NoColor = noColor
+	})
+
+	when("#parseColor", func() {
+		it("parses color names with bold and hi prefixes", func() {
+			for name, expected := range map[string]*color.Color{
+				"cyan":         color.New(color.FgCyan),
+				"Bold-Green":   color.New(color.Bold, color.FgGreen),
+				"hi-black":     color.New(color.FgHiBlack),
+				"bold-hi-blue": color.New(color.Bold, color.FgHiBlue),
+			} {
+				c, err := parseColor(name)
+				h.AssertNil(t, err)
+				h.AssertEq(t, c.Sprint("text"), expected.Sprint("text"))
+			}
+		})
+
+		it("returns an error for unknown color names", func() {
+			for _, name := range []string{"purple", "hi-bold-red", "bold-"} {
+				_, err := parseColor(name)
+				h.AssertError(t, err, "unknown color")
+				h.AssertError(t, err, name)
+			}
+		})
+	})
+
+	when("#ApplyTheme", func() {
+		it("applies the dark theme by default", func() {
+			h.AssertNil(t, ApplyTheme("", Theme{}))
+			h.AssertEq(t, Step("step"), color.New(color.FgCyan).Sprint("===> step"))
+			h.AssertEq(t, Warn("warning"), color.New(color.Bold, color.FgYellow).Sprint("warning"))
+		})
+
+		it("applies the built-in light theme", func() {
+			h.AssertNil(t, ApplyTheme("light", Theme{}))
+			h.AssertEq(t, Step("step"), color.New(color.Bold, color.FgBlue).Sprint("===> step"))
+			h.AssertEq(t, Key("symbol"), color.New(color.FgMagenta).Sprint("symbol"))
+			h.AssertEq(t, Tip("tip"), color.New(color.Bold, color.FgGreen).Sprint("tip"))
+			h.AssertEq(t, Error("error"), color.New(color.Bold, color.FgRed).Sprint("error"))
+			h.AssertEq(t, Warn("warning"), color.New(color.Bold, color.FgMagenta).Sprint("warning"))
+			h.AssertEq(t, Prefix("prefix"), color.New(color.FgBlue).Sprint("prefix"))
+		})
+
+		it("overrides the colors of the theme that are set", func() {
+			h.AssertNil(t, ApplyTheme("light", Theme{Step: "hi-cyan", Prefix: "white"}))
+			h.AssertEq(t, Step("step"), color.New(color.FgHiCyan).Sprint("===> step"))
+			h.AssertEq(t, Prefix("prefix"), color.New(color.FgWhite).Sprint("prefix"))
+			h.AssertEq(t, Warn("warning"), color.New(color.Bold, color.FgMagenta).Sprint("warning"))
+		})
+
+		it("returns an error for an unknown theme", func() {
+			err := ApplyTheme("solarized", Theme{})
+			h.AssertError(t, err, "unknown color theme")
+			h.AssertError(t, err, "solarized")
+		})
+
+		it("returns an error for an unknown color and keeps the current theme", func() {
+			h.AssertError(t, ApplyTheme("light", Theme{Tip: "purple"}), "unknown color")
+			h.AssertEq(t, Step("step"), color.New(color.FgCyan).Sprint("===> step"))
+		})
+	})
+}
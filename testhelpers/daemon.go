@@ -0,0 +1,124 @@
+package testhelpers
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dgodd/dockerdial"
+)
+
+// DockerDaemon describes how tests reach ports published by the docker daemon.
+//
+// With a local daemon published ports are reachable on localhost. With a remote daemon (DOCKER_HOST is set,
+// e.g. Docker Machine or docker-in-docker on CI) ports are published on another host, so ExposePort proxies them
+// to the same port on localhost, keeping names like localhost:5000/some/repo valid for both pack and the daemon,
+// and HTTP requests are dialed from the daemon's host.
+type DockerDaemon struct {
+	host string
+
+	mu      sync.Mutex
+	proxies map[string]net.Listener
+}
+
+var daemon = NewDockerDaemon(os.Getenv("DOCKER_HOST"))
+
+// Daemon returns the daemon described by DOCKER_HOST
+func Daemon() *DockerDaemon {
+	return daemon
+}
+
+func NewDockerDaemon(dockerHost string) *DockerDaemon {
+	return &DockerDaemon{host: dockerHost, proxies: map[string]net.Listener{}}
+}
+
+func (d *DockerDaemon) IsRemote() bool {
+	return d.host != ""
+}
+
+// Hostname returns the host the daemon publishes ports on
+func (d *DockerDaemon) Hostname() string {
+	if !d.IsRemote() {
+		return "localhost"
+	}
+	u, err := url.Parse(d.host)
+	if err != nil || u.Scheme == "unix" || u.Scheme == "npipe" || u.Hostname() == "" {
+		return "localhost"
+	}
+	return u.Hostname()
+}
+
+// Addr returns the local address of a port published by the daemon, see ExposePort
+func (d *DockerDaemon) Addr(port string) string {
+	return "localhost:" + port
+}
+
+// ExposePort makes a port published by a remote daemon reachable on the same port on localhost
+func (d *DockerDaemon) ExposePort(t *testing.T, port string) {
+	t.Helper()
+	if !d.IsRemote() {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.proxies[port]; ok {
+		return
+	}
+	ln, err := net.Listen("tcp", ":"+port)
+	AssertNil(t, err)
+	d.proxies[port] = ln
+	go d.proxy(ln, port)
+}
+
+func (d *DockerDaemon) proxy(ln net.Listener, port string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return // listener closed
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			c, err := d.Dial("tcp", "localhost:"+port)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+
+			go io.Copy(c, conn)
+			io.Copy(conn, c)
+		}(conn)
+	}
+}
+
+// Dial connects to addr as seen from the daemon's host
+func (d *DockerDaemon) Dial(network, addr string) (net.Conn, error) {
+	if !d.IsRemote() {
+		return net.Dial(network, addr)
+	}
+	return dockerdial.Dial(network, addr)
+}
+
+// HTTPClient returns a client making requests as seen from the daemon's host
+func (d *DockerDaemon) HTTPClient() *http.Client {
+	if !d.IsRemote() {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: &http.Transport{Dial: d.Dial}}
+}
+
+// Close stops proxying ports exposed by ExposePort
+func (d *DockerDaemon) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for port, ln := range d.proxies {
+		ln.Close()
+		delete(d.proxies, port)
+	}
+}
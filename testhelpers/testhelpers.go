@@ -7,10 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
-	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -20,7 +17,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/dgodd/dockerdial"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
@@ -71,6 +67,13 @@ func AssertContains(t *testing.T, actual, expected string) {
 	}
 }
 
+func AssertNotContains(t *testing.T, actual, unexpected string) {
+	t.Helper()
+	if strings.Contains(actual, unexpected) {
+		t.Fatalf("Expected: '%s' not to contain '%s'", actual, unexpected)
+	}
+}
+
 func AssertSliceContains(t *testing.T, slice []string, value string) {
 	t.Helper()
 	for _, s := range slice {
@@ -131,37 +134,6 @@ func dockerCli(t *testing.T) *docker.Client {
 	return dockerCliVal
 }
 
-func proxyDockerHostPort(dockerCli *docker.Client, port string) error {
-	ln, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return err
-	}
-
-	go func() {
-		// TODO exit somehow.
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			go func(conn net.Conn) {
-				defer conn.Close()
-				c, err := dockerdial.Dial("tcp", "localhost:"+port)
-				if err != nil {
-					log.Println(err)
-					return
-				}
-				defer c.Close()
-
-				go io.Copy(c, conn)
-				io.Copy(conn, c)
-			}(conn)
-		}
-	}()
-	return nil
-}
-
 var runRegistryName, runRegistryPort string
 var runRegistryOnce sync.Once
 
@@ -190,13 +162,10 @@ func RunRegistry(t *testing.T, seedRegistry bool) (localPort string) {
 		AssertNil(t, err)
 		runRegistryPort = inspect.NetworkSettings.Ports["5000/tcp"][0].HostPort
 
-		if os.Getenv("DOCKER_HOST") != "" {
-			err := proxyDockerHostPort(dockerCli(t), runRegistryPort)
-			AssertNil(t, err)
-		}
+		Daemon().ExposePort(t, runRegistryPort)
 
 		Eventually(t, func() bool {
-			txt, err := HttpGetE(fmt.Sprintf("http://localhost:%s/v2/", runRegistryPort))
+			txt, err := HttpGetE(fmt.Sprintf("http://%s/v2/", Daemon().Addr(runRegistryPort)))
 			return err == nil && txt != ""
 		}, 100*time.Millisecond, 10*time.Second)
 
@@ -250,6 +219,7 @@ func StopRegistry(t *testing.T) {
 		dockerCli(t).ContainerKill(context.Background(), runRegistryName, "SIGKILL")
 		dockerCli(t).ContainerRemove(context.TODO(), runRegistryName, dockertypes.ContainerRemoveOptions{Force: true})
 	}
+	Daemon().Close()
 }
 
 var getBuildImageOnce sync.Once
@@ -441,15 +411,7 @@ func HttpGet(t *testing.T, url string) string {
 }
 
 func HttpGetE(url string) (string, error) {
-	var client *http.Client
-	if os.Getenv("DOCKER_HOST") == "" {
-		client = http.DefaultClient
-	} else {
-		tr := &http.Transport{Dial: dockerdial.Dial}
-		client = &http.Client{Transport: tr}
-	}
-
-	resp, err := client.Get(url)
+	resp, err := Daemon().HTTPClient().Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -480,10 +442,10 @@ func CopyWorkspaceToDocker(t *testing.T, srcPath, destVolume string) {
 	AssertNil(t, err)
 	defer dockerCli(t).ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
 
-	tr, errChan := (&fs.FS{}).CreateTarReader(srcPath, "/workspace", 1000, 1000)
+	tr := (&fs.FS{}).CreateTarReader(srcPath, "/workspace", 1000, 1000)
 	err = dockerCli(t).CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{})
 	AssertNil(t, err)
-	AssertNil(t, <-errChan)
+	AssertNil(t, tr.Close())
 }
 
 func ReadFromDocker(t *testing.T, volume, path string) string {
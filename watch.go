@@ -0,0 +1,119 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/pack/style"
+)
+
+// Watch runs the build, then runs it again whenever files in the app dir change, until ctx is done.
+// The app dir is polled every interval; changes are built once no more were made for an interval,
+// so saving several files at once triggers a single build. Failed builds are logged, not returned.
+func (b *BuildConfig) Watch(ctx context.Context, interval time.Duration) error {
+	if b.AppReader != nil {
+		return errors.New("cannot watch an app read from stdin")
+	}
+
+	last, err := b.appSnapshot()
+	if err != nil {
+		return err
+	}
+	for {
+		b.build(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		b.Logger.Info("Watching %s for changes", style.Symbol(b.AppDir))
+
+		for changed := false; ; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+			current, err := b.appSnapshot()
+			if err != nil {
+				return err
+			}
+			if !sameSnapshot(last, current) {
+				changed = true
+				last = current
+				continue
+			}
+			if changed {
+				break
+			}
+		}
+		b.Logger.Info("Change detected, rebuilding %s", style.Symbol(b.RepoName))
+	}
+}
+
+func (b *BuildConfig) build(ctx context.Context) {
+	if err := b.RunContext(ctx); err != nil {
+		if ctx.Err() == nil {
+			b.Logger.Error(err.Error())
+		}
+		return
+	}
+	b.Logger.Info("Successfully built image %s", style.Symbol(b.RepoName))
+}
+
+// appSnapshot returns the size, mode and modification time of every file that would be copied into the build
+func (b *BuildConfig) appSnapshot() (map[string]string, error) {
+	fi, err := os.Stat(b.AppDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return map[string]string{filepath.Base(b.AppDir): fileSignature(fi)}, nil
+	}
+
+	include, err := b.appFilter(b.AppDir)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]string{}
+	err = filepath.Walk(b.AppDir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(b.AppDir, file)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if include != nil && relPath != "." && !include(relPath, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.IsDir() {
+			snapshot[relPath] = fileSignature(fi)
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+func fileSignature(fi os.FileInfo) string {
+	return fmt.Sprintf("%d:%s:%d", fi.Size(), fi.Mode(), fi.ModTime().UnixNano())
+}
+
+func sameSnapshot(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package pack_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	h "github.com/buildpack/pack/testhelpers"
+)
+
+func TestWatch(t *testing.T) {
+	color.NoColor = true
+	spec.Run(t, "watch", testWatch, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// syncBuffer is written by the watch loop while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+func testWatch(t *testing.T, when spec.G, it spec.S) {
+	var (
+		appDir string
+		out    *syncBuffer
+	)
+
+	it.Before(func() {
+		var err error
+		appDir, err = ioutil.TempDir("", "pack.watch.")
+		h.AssertNil(t, err)
+		h.AssertNil(t, ioutil.WriteFile(filepath.Join(appDir, "app.js"), []byte("v1"), 0644))
+		out = &syncBuffer{}
+	})
+
+	it.After(func() {
+		os.RemoveAll(appDir)
+	})
+
+	when("#Watch", func() {
+		it("rebuilds when a file in the app dir changes", func() {
+			// a dry run builds without creating containers
+			config := &pack.BuildConfig{
+				AppDir:   appDir,
+				RepoName: "some/app",
+				DryRun:   true,
+				Logger:   logging.NewLogger(out, out, false, false),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- config.Watch(ctx, 10*time.Millisecond) }()
+
+			waitFor := func(text string) {
+				for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+					if strings.Contains(out.String(), text) {
+						return
+					}
+				}
+				t.Fatalf("expected output to contain %q, got %q", text, out.String())
+			}
+			waitFor("Watching")
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(appDir, "new.js"), []byte("v2"), 0644))
+			waitFor("Change detected, rebuilding 'some/app'")
+
+			cancel()
+			h.AssertNil(t, <-done)
+		})
+	})
+}